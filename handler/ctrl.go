@@ -67,7 +67,11 @@ func (ch *ControllerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 func (ch *ControllerHandler) ServeContextHTTP(ctxt *web.Context) {
-	if ctrlmgr := ch.GetControlManager(ctxt); ctrlmgr != nil {
+	serveControlManager(ctxt, ch.GetControlManager(ctxt))
+}
+
+func serveControlManager(ctxt *web.Context, ctrlmgr *controller.ControlManager) {
+	if ctrlmgr != nil {
 		ctrlmgr.Prepare()
 		state, vw := ctrlmgr.Execute()
 		if state != -1 && vw == nil {
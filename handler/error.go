@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"sync"
 	"text/template"
 	"time"
 )
@@ -31,11 +34,38 @@ var (
 	InternalServerErrorHandler = http.HandlerFunc(InternalServerError)
 	ForbiddenHandler           = http.HandlerFunc(Forbidden)
 	templateCache              *template.Template
+	errorFS                    = os.DirFS(templateFolder)
+	errorMutex                 = sync.RWMutex{}
 )
 
 func init() {
-	if st, err := os.Stat(templateFolder + "0.html"); err == nil && !st.IsDir() {
-		templateCache, _ = template.ParseFiles(templateFolder + "0.html")
+	loadErrorTemplate()
+}
+
+// SetErrorFS sets the filesystem of the error pages to the directory dir of fsys (./errors/ of the OS filesystem by default),
+// e.g. an embed.FS or a util.OverlayFS of one; the page of a status is {status}.html, and 0.html is the template of the others
+func SetErrorFS(fsys fs.FS, dir string) (err error) {
+	if dir != "" && dir != "." {
+		if fsys, err = fs.Sub(fsys, dir); err != nil {
+			return
+		}
+	}
+
+	errorMutex.Lock()
+	errorFS = fsys
+	errorMutex.Unlock()
+
+	loadErrorTemplate()
+	return
+}
+
+func loadErrorTemplate() {
+	errorMutex.Lock()
+	defer errorMutex.Unlock()
+
+	templateCache = nil
+	if st, err := fs.Stat(errorFS, "0.html"); err == nil && !st.IsDir() {
+		templateCache, _ = template.ParseFS(errorFS, "0.html")
 	}
 	if templateCache == nil {
 		templateCache = template.New("0.html")
@@ -74,8 +104,21 @@ func ErrorHTML(w http.ResponseWriter, r *http.Request, status int) {
 		}
 	}()
 
-	if f, err := os.Open(templateFolder + strconv.Itoa(status) + ".html"); err == nil {
+	errorMutex.RLock()
+	fsys, tmpl := errorFS, templateCache
+	errorMutex.RUnlock()
+
+	f, err := fsys.Open(strconv.Itoa(status) + ".html")
+	if err == nil {
 		defer f.Close()
+	}
+
+	var content io.ReadSeeker
+	if err == nil {
+		content, err = readSeeker(f)
+	}
+
+	if err == nil {
 
 		// we must not send the last-modified header of the file
 		// since we do not know the real last-modified of the URI requested (not the error file)
@@ -83,10 +126,10 @@ func ErrorHTML(w http.ResponseWriter, r *http.Request, status int) {
 		w.WriteHeader(status)
 		// the name "error.html" is not really needed
 		// since it is only used to sniff content-type which we already provide
-		http.ServeContent(w, r, "error.html", time.Time{}, f)
+		http.ServeContent(w, r, "error.html", time.Time{}, content)
 	} else {
 		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
 		w.WriteHeader(status)
-		templateCache.Execute(w, map[string]interface{}{"statuscode": status, "statustext": http.StatusText(status)})
+		tmpl.Execute(w, map[string]interface{}{"statuscode": status, "statustext": http.StatusText(status)})
 	}
 }
@@ -0,0 +1,253 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/zaolab/sunnified/mvc/controller"
+	"github.com/zaolab/sunnified/router"
+	"github.com/zaolab/sunnified/web"
+)
+
+// the conventional actions of a resource controller, i.e. the methods Index, Show, Create, Update, Delete, New and Edit
+const (
+	ResourceActionIndex  = "index"
+	ResourceActionShow   = "show"
+	ResourceActionCreate = "create"
+	ResourceActionUpdate = "update"
+	ResourceActionDelete = "delete"
+	ResourceActionNew    = "new"
+	ResourceActionEdit   = "edit"
+)
+
+// ResourceIDName is the path var holding the id of the resource, e.g. PData["id"] of GET /posts/12
+const ResourceIDName = "id"
+
+type resourceRoute int
+
+const (
+	resourceCollection resourceRoute = iota
+	resourceMember
+	resourceNew
+	resourceEdit
+)
+
+// resourceActions are the actions of each route by request method
+var resourceActions = map[resourceRoute]map[controller.ReqMethod]string{
+	resourceCollection: {
+		controller.ReqMethodGet:  ResourceActionIndex,
+		controller.ReqMethodPost: ResourceActionCreate,
+	},
+	resourceMember: {
+		controller.ReqMethodGet:    ResourceActionShow,
+		controller.ReqMethodPut:    ResourceActionUpdate,
+		controller.ReqMethodPatch:  ResourceActionUpdate,
+		controller.ReqMethodDelete: ResourceActionDelete,
+	},
+	resourceNew: {
+		controller.ReqMethodGet: ResourceActionNew,
+	},
+	resourceEdit: {
+		controller.ReqMethodGet: ResourceActionEdit,
+	},
+}
+
+// NewRESTResource routes the conventional actions of the controller under prefix/name:
+//
+//	GET       /posts            Index
+//	GET       /posts/new        New
+//	POST      /posts            Create
+//	GET       /posts/{id}       Show
+//	GET       /posts/{id}/edit  Edit
+//	PUT/PATCH /posts/{id}       Update
+//	DELETE    /posts/{id}       Delete
+//
+// Only the routes of the actions the controller has are added. A POST to /posts/{id} is served as the method
+// given by X-HTTP-Method-Override (or the form value of the same name), for clients which can only POST.
+func NewRESTResource(rt router.Router, prefix, name string, controlmeta *controller.Meta) *RESTResource {
+	res := &RESTResource{
+		Param:       singular(path.Base(name)) + "_" + ResourceIDName,
+		router:      rt,
+		path:        strings.TrimRight(prefix, "/") + "/" + strings.Trim(name, "/"),
+		controlmeta: controlmeta,
+	}
+
+	res.handle(res.path, resourceCollection)
+	res.handle(res.path+"/new", resourceNew)
+	res.handle(res.path+"/{"+ResourceIDName+"}", resourceMember)
+	res.handle(res.path+"/{"+ResourceIDName+"}/edit", resourceEdit)
+
+	if len(res.routes) == 0 {
+		panic(fmt.Sprintf("controller %s of resource %s has none of the resource actions", controlmeta.RType(), res.path))
+	}
+
+	return res
+}
+
+// RESTResource is a resource controller routed by NewRESTResource
+type RESTResource struct {
+	// Param is the name of the path var holding the id of the resource in the paths of its nested resources,
+	// which is the singular of the name followed by _id (e.g. post_id of /posts/{post_id}/comments);
+	// it can be set (e.g. for an irregular plural) before the nested resources are added
+	Param string
+	// Controllers is the group whose container and bind source the controllers of the nested resources
+	// are parsed with, see controller.Group.MakeControllerMeta
+	Controllers *controller.Group
+	router      router.Router
+	path        string
+	controlmeta *controller.Meta
+	routes      []ResourceRoute
+	nested      []*RESTResource
+}
+
+// ResourceRoute is a route of a RESTResource, e.g. GET /posts/{id} of the show action
+type ResourceRoute struct {
+	Method     string
+	Path       string
+	Action     string
+	Controller *controller.Meta
+}
+
+// Nested routes the controller as a resource under a member of this resource, e.g. /posts/{post_id}/comments,
+// where the id of the parent is available to the controller as PData[Param]
+func (res *RESTResource) Nested(name string, cinterface interface{}) *RESTResource {
+	var cm *controller.Meta
+	if res.Controllers != nil {
+		cm, _, _, _ = res.Controllers.MakeControllerMeta(cinterface)
+	} else {
+		cm, _, _, _ = controller.MakeControllerMeta(cinterface)
+	}
+
+	nested := NewRESTResource(res.router, res.path+"/{"+res.Param+"}", name, cm)
+	nested.Controllers = res.Controllers
+	res.nested = append(res.nested, nested)
+	return nested
+}
+
+// Routes returns the routes of the resource followed by those of its nested resources
+func (res *RESTResource) Routes() []ResourceRoute {
+	routes := make([]ResourceRoute, len(res.routes))
+	copy(routes, res.routes)
+
+	for _, nested := range res.nested {
+		routes = append(routes, nested.Routes()...)
+	}
+
+	return routes
+}
+
+func (res *RESTResource) Path() string {
+	return res.path
+}
+
+func (res *RESTResource) ControllerMeta() *controller.Meta {
+	return res.controlmeta
+}
+
+func (res *RESTResource) handle(p string, route resourceRoute) {
+	var (
+		h       = &RESTResourceHandler{controlmeta: res.controlmeta, route: route}
+		methods = h.Methods()
+	)
+
+	if len(methods) == 0 {
+		return
+	}
+
+	for _, m := range methods {
+		res.routes = append(res.routes, ResourceRoute{
+			Method:     m,
+			Path:       p,
+			Action:     h.action(controller.ReqMethodFromString(m)),
+			Controller: res.controlmeta,
+		})
+	}
+
+	if route == resourceMember && (h.action(controller.ReqMethodPut) != "" || h.action(controller.ReqMethodDelete) != "") {
+		// for X-HTTP-Method-Override
+		methods = append(methods, "POST")
+	}
+
+	res.router.Handle(p, h, methods...)
+}
+
+// RESTResourceHandler serves one of the routes of a RESTResource
+type RESTResourceHandler struct {
+	controlmeta *controller.Meta
+	route       resourceRoute
+}
+
+// Methods returns the request methods the route serves, which are the ones the controller has actions for
+func (h *RESTResourceHandler) Methods() (methods []string) {
+	for _, m := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+		if h.action(controller.ReqMethodFromString(m)) != "" {
+			methods = append(methods, m)
+		}
+	}
+
+	return
+}
+
+// action returns the action of the request method, empty if the controller does not have it
+func (h *RESTResourceHandler) action(reqmeth controller.ReqMethod) string {
+	action := resourceActions[h.route][reqmeth]
+	if action == "" {
+		return ""
+	}
+
+	if reqmeth == controller.ReqMethodPatch && h.controlmeta.Action(action, reqmeth) == nil {
+		reqmeth = controller.ReqMethodPut
+	}
+
+	if h.controlmeta.Action(action, reqmeth) == nil {
+		return ""
+	}
+
+	return action
+}
+
+func (h *RESTResourceHandler) ServeOptions(w http.ResponseWriter, r *http.Request, origin map[string]string) {
+	router.ServeOptions(h.Methods(), w, r, origin)
+}
+
+func (h *RESTResourceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.ServeContextHTTP(web.NewContext(w, r))
+}
+
+func (h *RESTResourceHandler) ServeContextHTTP(ctxt *web.Context) {
+	serveControlManager(ctxt, h.GetControlManager(ctxt))
+}
+
+// GetControlManager returns the manager of the action of the request method,
+// which is in the state of 405 if the route has no action for the method
+func (h *RESTResourceHandler) GetControlManager(ctxt *web.Context) *controller.ControlManager {
+	action := h.action(controller.GetXReqMethod(ctxt))
+	cm := controller.NewControlManager(ctxt, h.controlmeta, action)
+
+	if action == "" {
+		ctxt.SetHeader("Allow", router.AllowHeader(h.Methods()))
+		cm.SetState(http.StatusMethodNotAllowed)
+	}
+
+	return cm
+}
+
+// singular returns the singular of an English plural in the simple cases, e.g. posts, categories and boxes;
+// a name which is not a plural of these (e.g. status, analysis or news) is kept as it is
+func singular(name string) string {
+	switch lname := strings.ToLower(name); {
+	case lname == "news", strings.HasSuffix(lname, "us"), strings.HasSuffix(lname, "is"), strings.HasSuffix(lname, "ss"):
+		return name
+	case strings.HasSuffix(lname, "ies") && len(name) > 3:
+		return name[:len(name)-3] + "y"
+	case strings.HasSuffix(lname, "sses"), strings.HasSuffix(lname, "xes"),
+		strings.HasSuffix(lname, "ches"), strings.HasSuffix(lname, "shes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(lname, "s"):
+		return name[:len(name)-1]
+	}
+
+	return name
+}
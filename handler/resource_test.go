@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/controller"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/router"
+	"github.com/zaolab/sunnified/web"
+)
+
+type statusCtrl struct{}
+
+func (c *statusCtrl) GETIndex() mvc.VM     { return mvc.VM{} }
+func (c *statusCtrl) GETShow() mvc.VM      { return mvc.VM{} }
+func (c *statusCtrl) PUTUpdate() mvc.VM    { return mvc.VM{} }
+func (c *statusCtrl) DELETEDelete() mvc.VM { return mvc.VM{} }
+
+type commentCtrl struct{}
+
+func (c *commentCtrl) GETIndex(pdata web.PData) mvc.View {
+	return view.NewJSONView(mvc.VM{"status": pdata["status_id"]})
+}
+
+func (c *commentCtrl) POSTCreate() mvc.VM { return mvc.VM{} }
+
+func resourceRoutes(res *RESTResource) (routes []string) {
+	for _, r := range res.Routes() {
+		routes = append(routes, r.Method+" "+r.Path+" "+r.Action)
+	}
+	return
+}
+
+func TestRESTResourceRoutes(t *testing.T) {
+	for _, c := range []struct {
+		name, param string
+		routes      []string
+	}{
+		{"status", "", []string{
+			"GET /status index",
+			"GET /status/{id} show",
+			"PUT /status/{id} update",
+			"PATCH /status/{id} update",
+			"DELETE /status/{id} delete",
+			"GET /status/{status_id}/comments index",
+			"POST /status/{status_id}/comments create",
+		}},
+		{"/api/news/", "", []string{
+			"GET /api/news index",
+			"GET /api/news/{id} show",
+			"PUT /api/news/{id} update",
+			"PATCH /api/news/{id} update",
+			"DELETE /api/news/{id} delete",
+			"GET /api/news/{news_id}/comments index",
+			"POST /api/news/{news_id}/comments create",
+		}},
+		{"posts", "", []string{
+			"GET /posts index",
+			"GET /posts/{id} show",
+			"PUT /posts/{id} update",
+			"PATCH /posts/{id} update",
+			"DELETE /posts/{id} delete",
+			"GET /posts/{post_id}/comments index",
+			"POST /posts/{post_id}/comments create",
+		}},
+		{"people", "person_id", []string{
+			"GET /people index",
+			"GET /people/{id} show",
+			"PUT /people/{id} update",
+			"PATCH /people/{id} update",
+			"DELETE /people/{id} delete",
+			"GET /people/{person_id}/comments index",
+			"POST /people/{person_id}/comments create",
+		}},
+	} {
+		cm, _, _, _ := controller.MakeControllerMeta(&statusCtrl{})
+		res := NewRESTResource(router.NewSunnyRouter(), "", c.name, cm)
+		if c.param != "" {
+			res.Param = c.param
+		}
+		res.Nested("comments", &commentCtrl{})
+
+		if routes := resourceRoutes(res); !reflect.DeepEqual(routes, c.routes) {
+			t.Errorf("%s: got %q", c.name, routes)
+		}
+	}
+}
+
+func TestNestedResourceParam(t *testing.T) {
+	rt := router.NewSunnyRouter()
+	cm, _, _, _ := controller.MakeControllerMeta(&statusCtrl{})
+	NewRESTResource(rt, "", "status", cm).Nested("comments", &commentCtrl{})
+
+	w := httptest.NewRecorder()
+	rt.ServeHTTP(w, httptest.NewRequest("GET", "/status/5/comments", nil))
+
+	if w.Code != 200 || !strings.Contains(w.Body.String(), `"status":"5"`) {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestSingular(t *testing.T) {
+	for name, want := range map[string]string{
+		"posts":      "post",
+		"categories": "category",
+		"boxes":      "box",
+		"addresses":  "address",
+		"views":      "view",
+		"status":     "status",
+		"news":       "news",
+		"analysis":   "analysis",
+		"Users":      "User",
+	} {
+		if got := singular(name); got != want {
+			t.Errorf("%s: got %s", name, got)
+		}
+	}
+}
+
+type noResourceCtrl struct{}
+
+func (c *noResourceCtrl) GETList() mvc.VM { return mvc.VM{} }
+
+func TestRESTResourceInvalid(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(string); !strings.Contains(err, "none of the resource actions") {
+			t.Errorf("got %v", err)
+		}
+	}()
+
+	cm, _, _, _ := controller.MakeControllerMeta(&noResourceCtrl{})
+	NewRESTResource(router.NewSunnyRouter(), "", "lists", cm)
+}
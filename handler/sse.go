@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/router"
+	"github.com/zaolab/sunnified/web"
+)
+
+// SSEHandler streams the events of its source to each request as Server-Sent Events,
+// e.g. the live updates of a dashboard without a controller
+type SSEHandler struct {
+	Source *view.EventSource
+	// Retry is the time the clients wait before reconnecting, the default of the client if it is 0
+	Retry time.Duration
+}
+
+func NewSSEHandler(source *view.EventSource) *SSEHandler {
+	return &SSEHandler{Source: source}
+}
+
+func (sh *SSEHandler) ServeOptions(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
+	w.Header().Set("Allow", router.AllowHeader([]string{"GET", "HEAD"}))
+}
+
+func (sh *SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	sh.ServeContextHTTP(web.NewContext(w, r))
+}
+
+func (sh *SSEHandler) ServeContextHTTP(ctxt *web.Context) {
+	if meth := ctxt.Method(); meth != "GET" && meth != "HEAD" {
+		ctxt.SetHeader("Allow", router.AllowHeader([]string{"GET", "HEAD"}))
+		ErrorHTML(ctxt.Response, ctxt.Request, http.StatusMethodNotAllowed)
+		return
+	}
+
+	sv := &view.SSEView{Source: sh.Source, Retry: sh.Retry}
+	if err := sv.Publish(ctxt); err != nil {
+		log.Println(err)
+	}
+}
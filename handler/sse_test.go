@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc/view"
+)
+
+func TestSSEHandlerAllow(t *testing.T) {
+	sh := NewSSEHandler(view.NewEventSource(0))
+
+	w := httptest.NewRecorder()
+	sh.ServeHTTP(w, httptest.NewRequest("POST", "/events", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("POST: got %d, Allow %q", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = httptest.NewRecorder()
+	sh.ServeOptions(w, httptest.NewRequest("OPTIONS", "/events", nil), nil)
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, OPTIONS" {
+		t.Errorf("OPTIONS: got Allow %q", allow)
+	}
+}
@@ -1,9 +1,12 @@
 package handler
 
 import (
+	"bytes"
 	"compress/gzip"
 	"crypto/md5"
 	"fmt"
+	"io"
+	"io/fs"
 	"mime"
 	"net/http"
 	"os"
@@ -22,7 +25,8 @@ const typeDefault = "application/octet-stream"
 var separatorString = string(filepath.Separator)
 
 type StaticFileHandler struct {
-	BasePath    string // relative path from application or absolute path
+	BasePath    string // relative path from application or absolute path, or the directory in FS
+	FS          fs.FS  // filesystem of the files (e.g. an embed.FS), the OS filesystem if nil
 	BaseURL     string // relative path of domain
 	DefaultType string
 	Cache       int
@@ -42,6 +46,16 @@ func NewStaticFileHandlerPath(basepath string, baseurl string) *StaticFileHandle
 	}
 }
 
+// NewStaticFileHandlerFS returns a handler of the files in the directory basepath of fsys, e.g. an embed.FS
+// or a util.OverlayFS of one
+func NewStaticFileHandlerFS(fsys fs.FS, basepath string, baseurl string) *StaticFileHandler {
+	return &StaticFileHandler{
+		BasePath: basepath,
+		BaseURL:  baseurl,
+		FS:       fsys,
+	}
+}
+
 func (sh *StaticFileHandler) ServeOptions(w http.ResponseWriter, _ *http.Request, _ map[string]string) {
 	w.Header().Set("Allow", "HEAD GET OPTIONS")
 }
@@ -53,7 +67,7 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		basepath = sh.BasePath
 		header   = w.Header()
 		fullpath string
-		file     *os.File
+		file     fs.File
 		err      error
 		etaggz   string
 	)
@@ -67,7 +81,11 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		basepath = basepath + separatorString
 	}
 
-	fullpath = filepath.FromSlash(basepath + urlpath)
+	if sh.FS != nil {
+		fullpath = path.Join(sh.BasePath, urlpath)
+	} else {
+		fullpath = filepath.FromSlash(basepath + urlpath)
+	}
 
 	if sh.BaseURL != "" {
 		if !strings.HasPrefix(urlpath, sh.BaseURL) {
@@ -78,12 +96,16 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		urlpath = strings.Trim(urlpath[len(sh.BaseURL):], "/")
 	}
 
-	st, err := os.Stat(fullpath)
+	st, err := sh.stat(fullpath)
 
 	if err == nil {
 		if st.IsDir() {
-			fullpath = fullpath + separatorString + "index.html"
-			st, err = os.Stat(fullpath)
+			if sh.FS != nil {
+				fullpath = path.Join(fullpath, "index.html")
+			} else {
+				fullpath = fullpath + separatorString + "index.html"
+			}
+			st, err = sh.stat(fullpath)
 
 			if err != nil || st.IsDir() {
 				NotFound(w, r)
@@ -107,7 +129,7 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") && usegzip {
 		if sh.GzippedFile {
-			var stgz, err = os.Stat(gzpath)
+			var stgz, err = sh.stat(gzpath)
 
 			if err == nil && !stgz.IsDir() && (stgz.ModTime().After(modtime) || stgz.ModTime().Equal(modtime)) {
 				modtime = stgz.ModTime()
@@ -141,7 +163,7 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	etag := fmt.Sprintf(`"%x%s"`, md5ed, etaggz)
 	header.Set("ETag", etag)
 
-	file, err = os.Open(fullpath)
+	file, err = sh.open(fullpath)
 
 	if err != nil {
 		NotFound(w, r)
@@ -150,6 +172,13 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	defer file.Close()
 
+	content, err := readSeeker(file)
+
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+
 	if sh.Cache != 0 {
 		header.Set("Cache-Control", fmt.Sprintf("max-age=%d", sh.Cache))
 	} else {
@@ -160,8 +189,9 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		var gzfile *os.File
 		var gzw *resp.GzipResponseWriter
 
-		// serveContent will not write to response if client already has a copy of file making the .gz local file empty
-		if sh.GzippedFile && r.Method != "HEAD" && r.Header.Get("If-Modified-Since") == "" &&
+		// serveContent will not write to response if client already has a copy of file making the .gz local file empty;
+		// the .gz file is only kept for files of the OS filesystem, a fs.FS being read only
+		if sh.GzippedFile && sh.FS == nil && r.Method != "HEAD" && r.Header.Get("If-Modified-Since") == "" &&
 			r.Header.Get("If-None-Match") == "" && r.Header.Get("If-Range") == "" {
 
 			if gzfile, err = os.OpenFile(gzpath+".tmp", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644); err == nil {
@@ -190,5 +220,34 @@ func (sh *StaticFileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		header.Add("Vary", "Accept-Encoding")
 	}
 
-	http.ServeContent(w, r, path.Base(urlpath), modtime, file)
+	http.ServeContent(w, r, path.Base(urlpath), modtime, content)
+}
+
+func (sh *StaticFileHandler) stat(name string) (fs.FileInfo, error) {
+	if sh.FS != nil {
+		return fs.Stat(sh.FS, name)
+	}
+	return os.Stat(name)
+}
+
+func (sh *StaticFileHandler) open(name string) (fs.File, error) {
+	if sh.FS != nil {
+		return sh.FS.Open(name)
+	}
+	return os.Open(name)
+}
+
+// readSeeker returns the file as an io.ReadSeeker for http.ServeContent,
+// reading it into memory if it cannot seek (a fs.File need not)
+func readSeeker(f fs.File) (io.ReadSeeker, error) {
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	b, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
 }
@@ -3,6 +3,9 @@ package controller
 import (
 	"reflect"
 	"regexp"
+
+	"github.com/zaolab/sunnified/util/validate"
+	"github.com/zaolab/sunnified/web"
 )
 
 type DataType int
@@ -21,6 +24,10 @@ const (
 	DatatypeBoolSuffix = "_Bool"
 
 	FormValueTypeTagName = "value.type"
+	FormValueLayoutTagName = "layout"
+	UploadMaxSizeTagName = "upload.maxsize"
+	UploadExtTagName = "upload.ext"
+	UploadTypeTagName = "upload.type"
 	FormValueTypeLprefix = "form_"
 )
 
@@ -45,6 +52,13 @@ const (
 	DatatypeDateTime
 	DatatypeBool
 	DatatypeEmbedded
+	DatatypeValidation
+	DatatypeSlice
+	DatatypeMap
+	DatatypeUploadedFile
+	DatatypeInject
+	DatatypeView
+	DatatypeNext
 )
 
 type ActionMeta struct {
@@ -52,6 +66,8 @@ type ActionMeta struct {
 	rmeth   reflect.Method
 	reqmeth ReqMethod
 	args    []*ArgMeta
+	filters *actionFilters
+	invoker actionInvoker
 	ResultStyle
 }
 
@@ -59,7 +75,9 @@ type ResultStyle struct {
 	view   bool
 	vmap   bool
 	mapsi  bool
+	typed  bool
 	status bool
+	err    bool
 }
 
 type ArgMeta struct {
@@ -71,6 +89,10 @@ type FieldMeta struct {
 	tag       reflect.StructTag
 	anonymous bool
 	rex       *regexp.Regexp
+	rules     validate.Rules
+	index     int
+	feeds     []string
+	res       string
 }
 
 type DataMeta struct {
@@ -79,6 +101,10 @@ type DataMeta struct {
 	t      DataType
 	rtype  reflect.Type
 	fields []*FieldMeta
+	elem   *DataMeta
+	layout string
+	upload web.UploadRules
+	decode dataDecoder
 }
 
 func (am *ActionMeta) Name() string {
@@ -100,7 +126,7 @@ func (am *ActionMeta) Args() []*ArgMeta {
 }
 
 func (rs ResultStyle) IsNil() bool {
-	return !rs.view && !rs.vmap && !rs.mapsi
+	return !rs.view && !rs.vmap && !rs.mapsi && !rs.typed
 }
 
 func (rs ResultStyle) View() bool {
@@ -115,10 +141,20 @@ func (rs ResultStyle) MapSI() bool {
 	return rs.mapsi
 }
 
+// Typed returns whether the result is a value (e.g. a struct) to be encoded in the media type the request accepts
+func (rs ResultStyle) Typed() bool {
+	return rs.typed
+}
+
 func (rs ResultStyle) Status() bool {
 	return rs.status
 }
 
+// Err returns whether the last result is an error
+func (rs ResultStyle) Err() bool {
+	return rs.err
+}
+
 func (dm *DataMeta) Name() string {
 	return dm.name
 }
@@ -141,10 +177,32 @@ func (dm *DataMeta) Fields() []*FieldMeta {
 	return out
 }
 
+// Elem returns the meta of the elements of a slice or map
+func (dm *DataMeta) Elem() *DataMeta {
+	return dm.elem
+}
+
+// Layout returns the time layout given by the layout tag, if any
+func (dm *DataMeta) Layout() string {
+	return dm.layout
+}
+
+// UploadRules returns the rules given by the upload tags of an uploaded file field
+func (dm *DataMeta) UploadRules() web.UploadRules {
+	return dm.upload
+}
+
 func (fm *FieldMeta) Rexexp() *regexp.Regexp {
 	return fm.rex
 }
 
+// Rules returns the rules of the validate tag, including the pattern of the regexp tag
+func (fm *FieldMeta) Rules() validate.Rules {
+	out := make(validate.Rules, len(fm.rules))
+	copy(out, fm.rules)
+	return out
+}
+
 func (fm *FieldMeta) Anonymous() bool {
 	return fm.anonymous
 }
@@ -0,0 +1,433 @@
+package controller
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// BindSource is the set of request data that arguments and fields are bound from
+type BindSource int
+
+const (
+	// BindPath binds the path vars (PData)
+	BindPath BindSource = 1 << iota
+	// BindBody binds the values of the request body, i.e. form posts, multipart forms and JSON bodies
+	BindBody
+	// BindQuery binds the values of the query string
+	BindQuery
+
+	BindAll = BindPath | BindBody | BindQuery
+)
+
+// BindSourcer is implemented by controllers whose arguments and fields are bound from more than the path vars,
+// e.g. the request body of form posts and JSON; BindSource_ is called once when the controller is added,
+// on a zero value of the controller, and takes precedence over the bind source of the controller group
+type BindSourcer interface {
+	BindSource_() BindSource
+}
+
+// bindNode is the request data arranged by the parts of the keys,
+// e.g. items[0][name], items[0].name and the items.0.name of a flattened JSON body
+// all end up in the name node under the 0 node under the items node.
+// Names are matched case insensitively, as are the field names they are bound to.
+type bindNode struct {
+	name     string
+	path     string
+	values   []string
+	children map[string]*bindNode
+	// data holds the first value of each child by its lower case name
+	data web.PData
+}
+
+func newBindTree(form url.Values, pdata web.PData) *bindNode {
+	root := &bindNode{}
+
+	for k, v := range form {
+		if segs := web.SplitFormKey(k); len(segs) > 0 {
+			root.add(segs, v)
+		}
+	}
+
+	// a path var takes precedence over a form value of the same name
+	for k, v := range pdata {
+		lk := strings.ToLower(k)
+		root.childOrNew(k, lk).values = []string{v}
+		root.data[lk] = v
+	}
+
+	return root
+}
+
+func (n *bindNode) add(segs []string, values []string) {
+	parent := n
+
+	for _, seg := range segs {
+		parent = n
+		n = n.childOrNew(seg, strings.ToLower(seg))
+	}
+
+	if lname := strings.ToLower(segs[len(segs)-1]); len(values) > 0 {
+		if _, exists := parent.data[lname]; !exists {
+			parent.data[lname] = values[0]
+		}
+	}
+
+	n.values = append(n.values, values...)
+}
+
+func (n *bindNode) childOrNew(name, lname string) *bindNode {
+	if n.children == nil {
+		n.children = make(map[string]*bindNode)
+		n.data = make(web.PData)
+	}
+
+	child, exists := n.children[lname]
+	if !exists {
+		child = &bindNode{name: name, path: n.key(lname)}
+		n.children[lname] = child
+	}
+
+	return child
+}
+
+func (n *bindNode) child(lname string) *bindNode {
+	return n.children[lname]
+}
+
+// key returns the full name of a child, used as the field name of validation errors
+func (n *bindNode) key(lname string) string {
+	if n.path == "" {
+		return lname
+	}
+	return n.path + "." + lname
+}
+
+// indices returns the names of the children that are indices, in the order of the indices
+func (n *bindNode) indices() []string {
+	var (
+		ints  []int
+		names = make(map[int]string)
+	)
+
+	for name := range n.children {
+		if i, err := strconv.Atoi(name); err == nil && i >= 0 {
+			ints = append(ints, i)
+			names[i] = name
+		}
+	}
+
+	sort.Ints(ints)
+
+	out := make([]string, len(ints))
+	for i, index := range ints {
+		out[i] = names[index]
+	}
+
+	return out
+}
+
+// items returns the keys and data of the scalar elements of a slice,
+// which are either repeated values (ids=1&ids=2) or indexed children (ids[0]=1&ids[1]=2)
+func (n *bindNode) items() (keys []string, d web.PData) {
+	if len(n.values) == 0 {
+		return n.indices(), n.data
+	}
+
+	keys = make([]string, len(n.values))
+	d = make(web.PData, len(n.values))
+
+	for i, v := range n.values {
+		keys[i] = strconv.Itoa(i)
+		d[keys[i]] = v
+	}
+
+	return
+}
+
+func isCompositeType(t DataType) bool {
+	switch t {
+	case DatatypeStruct, DatatypeEmbedded, DatatypeSlice, DatatypeMap:
+		return true
+	}
+	return false
+}
+
+// bindComposite binds a struct, slice or map from the node;
+// pointers are allocated as the node holds at least some of the data
+func bindComposite(dm *DataMeta, vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+	var (
+		rtype = dm.RType()
+		etype = rtype
+		value reflect.Value
+	)
+
+	if rtype.Kind() == reflect.Ptr {
+		etype = rtype.Elem()
+	}
+
+	switch dm.T() {
+	case DatatypeStruct, DatatypeEmbedded:
+		value = reflect.New(etype).Elem()
+		for _, field := range dm.fields {
+			if fvalue := getFieldValue(field, vmap, n, vd); fvalue.IsValid() {
+				value.Field(field.index).Set(fvalue)
+			}
+		}
+	case DatatypeSlice:
+		value = bindSlice(dm.elem, etype, vmap, n, vd)
+	case DatatypeMap:
+		value = bindMap(dm.elem, etype, vmap, n, vd)
+	default:
+		return reflect.Value{}
+	}
+
+	if etype != rtype {
+		return value.Addr()
+	}
+
+	return value
+}
+
+func bindSlice(elem *DataMeta, stype reflect.Type, vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+	if isCompositeType(elem.T()) {
+		indices := n.indices()
+		slice := reflect.MakeSlice(stype, 0, len(indices))
+
+		for _, index := range indices {
+			slice = reflect.Append(slice, bindComposite(elem, vmap, n.children[index], vd))
+		}
+
+		return slice
+	}
+
+	keys, d := n.items()
+	slice := reflect.MakeSlice(stype, 0, len(keys))
+
+	for _, key := range keys {
+		slice = reflect.Append(slice, bindScalar(elem, d, key, n.key(key), vd))
+	}
+
+	return slice
+}
+
+func bindMap(elem *DataMeta, mtype reflect.Type, vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+	var (
+		m     = reflect.MakeMapWithSize(mtype, len(n.children))
+		ktype = mtype.Key()
+	)
+
+	for lname, child := range n.children {
+		key, ok := mapKey(ktype, child.name)
+		if !ok {
+			vd.Add(n.key(lname), ValidationCodeType, n.key(lname)+" is not a valid key")
+			continue
+		}
+
+		if isCompositeType(elem.T()) {
+			m.SetMapIndex(key, bindComposite(elem, vmap, child, vd))
+		} else {
+			m.SetMapIndex(key, bindScalar(elem, n.data, lname, n.key(lname), vd))
+		}
+	}
+
+	return m
+}
+
+// mapKey converts the name to a key of the type, integers being parsed with the size of the type
+// so that e.g. 300 is not a key of a map[int8]T instead of wrapping around
+func mapKey(ktype reflect.Type, name string) (key reflect.Value, ok bool) {
+	key = reflect.New(ktype).Elem()
+
+	switch kind := ktype.Kind(); {
+	case kind == reflect.String:
+		key.SetString(name)
+	case isIntKind(kind):
+		i, err := strconv.ParseInt(name, 10, ktype.Bits())
+		if err != nil {
+			return key, false
+		}
+		key.SetInt(i)
+	case isUintKind(kind):
+		u, err := strconv.ParseUint(name, 10, ktype.Bits())
+		if err != nil {
+			return key, false
+		}
+		key.SetUint(u)
+	default:
+		return key, false
+	}
+
+	return key, true
+}
+
+// bindScalar converts d[key] to the type of dm, recording a value that cannot be converted in vd under vkey.
+// A pointer is left nil if the value is not given at all.
+func bindScalar(dm *DataMeta, d web.PData, key, vkey string, vd *Validation) reflect.Value {
+	var (
+		val       interface{}
+		err       error
+		_, exists = d[key]
+		rtype     = dm.RType()
+		etype     = rtype
+	)
+
+	if rtype != nil && rtype.Kind() == reflect.Ptr {
+		if etype = rtype.Elem(); !exists {
+			return reflect.Zero(rtype)
+		}
+	}
+
+	switch dm.T() {
+	case DatatypeString:
+		val, err = d.String(key)
+	case DatatypeInt, DatatypeInt64:
+		// parsed with the size of the target, so e.g. 300 is an error for an int8 instead of wrapping around
+		var s string
+		if s, err = d.String(key); err == nil {
+			val, err = strconv.ParseInt(s, 10, etype.Bits())
+		} else {
+			val = int64(0)
+		}
+	case DatatypeFloat:
+		val, err = d.Float32(key)
+	case DatatypeFloat64:
+		val, err = d.Float64(key)
+	case DatatypeBool:
+		var s string
+		if s, err = d.String(key); err == nil {
+			val, err = strconv.ParseBool(s)
+		} else {
+			val = false
+		}
+	case DatatypeEmail:
+		val, err = d.Email(key)
+	case DatatypeURL:
+		val, err = d.Url(key)
+	case DatatypeDate, DatatypeDateTime:
+		if dm.layout != "" {
+			var (
+				s string
+				t time.Time
+			)
+			if s, err = d.String(key); err == nil {
+				t, err = time.Parse(dm.layout, s)
+			}
+			val = t
+		} else if dm.T() == DatatypeDate {
+			val, err = d.Date(key)
+		} else {
+			val, err = d.DateTime(key)
+		}
+	case DatatypeTime:
+		val, err = d.Time(key)
+	default:
+		return reflect.Value{}
+	}
+
+	value := reflect.ValueOf(val)
+
+	if err != nil && exists {
+		switch dm.T() {
+		case DatatypeEmail:
+			vd.Add(vkey, ValidationCodeEmail, vkey+" is not a valid email address")
+		case DatatypeURL:
+			vd.Add(vkey, ValidationCodeURL, vkey+" is not a valid url")
+		default:
+			vd.Add(vkey, ValidationCodeType, vkey+" is not a valid value")
+		}
+
+		// the getters may return a partially parsed value along with the error
+		if etype != rtype {
+			return reflect.Zero(rtype)
+		}
+		value = reflect.Zero(value.Type())
+	}
+
+	if etype != nil && value.Type() != etype && value.Type().ConvertibleTo(etype) {
+		value = value.Convert(etype)
+	}
+
+	if etype != rtype {
+		ptr := reflect.New(etype)
+		ptr.Elem().Set(value)
+		value = ptr
+	}
+
+	return value
+}
+
+// bindUploads binds the files uploaded under key to a *web.UploadedFile (the first file accepted)
+// or a []*web.UploadedFile; files failing the upload rules, the size limit or the scan
+// (if Context.FileScanner is set) are recorded in vd and left out
+func bindUploads(dm *DataMeta, vmap map[string]reflect.Value, key string, vd *Validation) reflect.Value {
+	var (
+		ctxt, _  = vmap["context"].Interface().(*web.Context)
+		rtype    = dm.RType()
+		fmeta    = dm
+		accepted []*web.UploadedFile
+	)
+
+	if dm.T() == DatatypeSlice {
+		fmeta = dm.elem
+	}
+
+	if ctxt == nil {
+		return reflect.Zero(rtype)
+	}
+
+	for _, file := range ctxt.UploadedFiles(key) {
+		err := file.Check(fmeta.upload, ctxt.MaxFileSize)
+		if err == nil && ctxt.FileScanner != nil {
+			err = file.Scan(ctxt.FileScanner)
+		}
+
+		if uerr, ok := err.(web.UploadError); ok {
+			vkey := key
+			if vkey == "" {
+				vkey = web.NormalizeFormKey(file.Field)
+			}
+			vd.Add(vkey, uerr.Code, vkey+" "+uerr.Message)
+			continue
+		}
+
+		accepted = append(accepted, file)
+
+		if fmeta == dm {
+			break
+		}
+	}
+
+	if fmeta != dm {
+		slice := reflect.MakeSlice(rtype, 0, len(accepted))
+		for _, file := range accepted {
+			slice = reflect.Append(slice, reflect.ValueOf(file))
+		}
+		return slice
+	}
+
+	if len(accepted) == 0 {
+		return reflect.Zero(rtype)
+	}
+
+	return reflect.ValueOf(accepted[0])
+}
+
+// injectValue returns the service of the type from the request's container, the type is known to be provided
+// (see MakeControllerMeta) so a failure to construct it panics; the zero value is used outside of an app's request
+func injectValue(dm *DataMeta, vmap map[string]reflect.Value) reflect.Value {
+	if ctxt, _ := vmap["context"].Interface().(*web.Context); ctxt != nil && ctxt.Services != nil {
+		value, err := ctxt.Services.Resolve(dm.RType())
+		if err != nil {
+			panic(err)
+		}
+		return value
+	}
+
+	return reflect.Zero(dm.RType())
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/web"
+)
+
+type bindAddress struct {
+	Form_City string
+	Form_Zip  int
+}
+
+type bindItem struct {
+	Form_Name string
+	Form_Qty  int8
+}
+
+type bindCtrl struct {
+	Form_Address *bindAddress
+	Form_Items   []bindItem
+	Form_IDs     []int
+	Form_Tags    map[string]string
+	Form_Scores  map[int]int16
+	Form_Levels  map[int8]string
+	Form_Ranks   map[uint8]int
+	Form_Qty     int8
+	Form_Count   *int32
+	Form_Note    *string
+}
+
+func (c *bindCtrl) BindSource_() BindSource { return BindAll }
+
+func (c *bindCtrl) POSTSave(vd *Validation) mvc.View {
+	return view.NewJSONView(mvc.VM{"ctrl": c, "errors": vd.Map()})
+}
+
+// bindJSONCtrl takes the bind source of its group
+type bindJSONCtrl struct {
+	Form_Address bindAddress
+	Form_Items   []bindItem
+	Form_IDs     []int
+}
+
+func (c *bindJSONCtrl) POSTSave() mvc.View {
+	return view.NewJSONView(mvc.VM{"ctrl": c})
+}
+
+func TestBindComposite(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&bindCtrl{})
+
+	body := strings.Join([]string{
+		"address[city]=Paris", "Address.Zip=75001",
+		"items[1][name]=b", "items[1][qty]=1000", "items[0].name=a", "items[0][qty]=-2",
+		"ids=1", "ids=2", "ids=x",
+		"tags[Color]=red", "tags.Size=L",
+		"scores[3]=9", "scores[x]=1", "scores[4]=40000",
+		"qty=300", "count=2147483647",
+		"levels[300]=a", "levels[-1]=b", "ranks[7]=1", "ranks[-1]=2", "ranks[256]=3",
+	}, "&")
+
+	r := httptest.NewRequest("POST", "/save", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	ctxt := web.NewContext(httptest.NewRecorder(), r)
+	ctxt.ParseRequestData()
+	ctxt.WaitRequestData()
+
+	_, vw := NewControlManager(ctxt, cm, "save").PrepareAndExecute()
+	jv, _ := vw.(view.JSONView)
+	ctrl, _ := jv["ctrl"].(*bindCtrl)
+
+	if ctrl == nil {
+		t.Fatalf("got %v", vw)
+	}
+
+	if ctrl.Form_Address == nil || *ctrl.Form_Address != (bindAddress{"Paris", 75001}) {
+		t.Errorf("address: %+v", ctrl.Form_Address)
+	}
+	if !reflect.DeepEqual(ctrl.Form_Items, []bindItem{{"a", -2}, {"b", 0}}) {
+		t.Errorf("items: %+v", ctrl.Form_Items)
+	}
+	if !reflect.DeepEqual(ctrl.Form_IDs, []int{1, 2, 0}) {
+		t.Errorf("ids: %v", ctrl.Form_IDs)
+	}
+	// map keys keep their case
+	if !reflect.DeepEqual(ctrl.Form_Tags, map[string]string{"Color": "red", "Size": "L"}) {
+		t.Errorf("tags: %v", ctrl.Form_Tags)
+	}
+	if !reflect.DeepEqual(ctrl.Form_Scores, map[int]int16{3: 9, 4: 0}) {
+		t.Errorf("scores: %v", ctrl.Form_Scores)
+	}
+	// keys are parsed with the size of their type
+	if !reflect.DeepEqual(ctrl.Form_Levels, map[int8]string{-1: "b"}) || !reflect.DeepEqual(ctrl.Form_Ranks, map[uint8]int{7: 1}) {
+		t.Errorf("keys: %v %v", ctrl.Form_Levels, ctrl.Form_Ranks)
+	}
+	if ctrl.Form_Qty != 0 || ctrl.Form_Count == nil || *ctrl.Form_Count != 2147483647 || ctrl.Form_Note != nil {
+		t.Errorf("scalars: %d %v %v", ctrl.Form_Qty, ctrl.Form_Count, ctrl.Form_Note)
+	}
+
+	errs, _ := jv["errors"].(map[string][]ValidationError)
+
+	var fields []string
+	for field, ferrs := range errs {
+		if len(ferrs) != 1 || ferrs[0].Code != ValidationCodeType {
+			t.Errorf("%s: %v", field, ferrs)
+		}
+		fields = append(fields, field)
+	}
+
+	if want := []string{"ids.2", "items.1.qty", "qty", "scores.4", "scores.x", "levels.300", "ranks.-1", "ranks.256"}; !sameStrings(fields, want) {
+		t.Errorf("errors: got %v, want %v", fields, want)
+	}
+}
+
+func TestBindJSON(t *testing.T) {
+	body := `{"address":{"city":"Paris","zip":75001},"items":[{"name":"a","qty":2},{"name":"b"}],"ids":[1,2]}`
+
+	for _, c := range []struct {
+		name  string
+		src   BindSource
+		bound bool
+	}{
+		{"group default", 0, false},
+		{"group body", BindBody, true},
+	} {
+		g := NewControllerGroup()
+		if c.src != 0 {
+			g.SetBindSource(c.src)
+		}
+		cm := g.Controller(g.AddController(&bindJSONCtrl{}))
+
+		r := httptest.NewRequest("POST", "/save?ids=3", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+
+		ctxt := web.NewContext(httptest.NewRecorder(), r)
+		ctxt.ParseRequestData()
+		ctxt.WaitRequestData()
+
+		_, vw := NewControlManager(ctxt, cm, "save").PrepareAndExecute()
+		jv, _ := vw.(view.JSONView)
+		ctrl, _ := jv["ctrl"].(*bindJSONCtrl)
+
+		want := &bindJSONCtrl{}
+		if c.bound {
+			want = &bindJSONCtrl{bindAddress{"Paris", 75001}, []bindItem{{"a", 2}, {"b", 0}}, []int{1, 2}}
+		}
+
+		if !reflect.DeepEqual(ctrl, want) {
+			t.Errorf("%s: got %+v", c.name, ctrl)
+		}
+	}
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	seen := make(map[string]int)
+	for _, s := range a {
+		seen[s]++
+	}
+	for _, s := range b {
+		if seen[s]--; seen[s] < 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -4,11 +4,14 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"reflect"
 	"strings"
 
 	"github.com/zaolab/sunnified/mvc"
 	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/router"
+	"github.com/zaolab/sunnified/util/validate"
 	"github.com/zaolab/sunnified/web"
 )
 
@@ -19,6 +22,7 @@ var ErrParseStruct = errors.New("Sunnified Parser error")
 
 const StructValueFeedTag = "sunnified.feed"
 const StructValueResTag = "sunnified.res"
+const StructValueInjectTag = "sunnified.inject"
 
 type StructValueFeeder interface {
 	FeedStructValue(*web.Context, *FieldMeta, reflect.Value) (reflect.Value, error)
@@ -38,6 +42,9 @@ func NewControlManager(context *web.Context, cm *Meta, action string) *ControlMa
 		context:     context,
 		controlmeta: cm,
 		action:      action,
+		validation:  NewValidation(),
+		vmode:       DefaultValidationMode,
+		bindsrc:     cm.BindSource(),
 	}
 }
 
@@ -50,6 +57,10 @@ type ControlManager struct {
 	executed    bool
 	state       int
 	vw          mvc.View
+	validation  *Validation
+	vmode       ValidationMode
+	bindsrc     BindSource
+	bindtree    *bindNode
 }
 
 func (c *ControlManager) SetControllerMeta(cm *Meta) (ok bool) {
@@ -162,8 +173,9 @@ func (c *ControlManager) Prepare() error {
 		switch c.controlmeta.T() {
 		case ContypeConstructor:
 			results := c.control.Call(getArgSlice(c.controlmeta.Args(),
-				getVMap(c.context),
-				c.context.PData))
+				c.getVMap(),
+				c.bindTree(),
+				c.validation))
 			c.control = results[0]
 
 			if c.control.Kind() == reflect.Interface {
@@ -184,27 +196,17 @@ func (c *ControlManager) Prepare() error {
 				c.state = state
 			}
 		case ContypeStruct, ContypeScontroller:
-			fields := c.controlmeta.Fields()
 			tmpcontrol := reflect.Indirect(c.control)
+			vmap, n := c.getVMap(), c.bindTree()
 
-			for _, field := range fields {
-				value := getDataValue(&field.DataMeta,
-					getVMap(c.context),
-					c.context.PData)
+			for _, field := range c.controlmeta.fields {
+				value := getFieldValue(field, vmap, n, c.validation)
 
 				// allows middleware resources to make changes to value based on tag
 				// this can be useful to csrf where non csrf verified values are filtered
-				if res := field.Tag().Get(StructValueFeedTag); res != "" {
-					var reses []string
-
-					if strings.Contains(res, ",") {
-						reses = strings.Split(res, ",")
-					} else {
-						reses = []string{res}
-					}
-
-					for _, r := range reses {
-						rinterface := c.context.Resource(strings.TrimSpace(r))
+				if len(field.feeds) > 0 {
+					for _, r := range field.feeds {
+						rinterface := c.context.Resource(r)
 
 						if rinterface != nil {
 							if parser, ok := rinterface.(StructValueFeeder); ok {
@@ -222,8 +224,8 @@ func (c *ControlManager) Prepare() error {
 							return ErrParseStruct
 						}
 					}
-				} else if res := field.Tag().Get(StructValueResTag); res != "" {
-					rinterface := c.context.Resource(strings.TrimSpace(res))
+				} else if field.res != "" {
+					rinterface := c.context.Resource(field.res)
 
 					if rinterface != nil {
 						value = reflect.ValueOf(rinterface)
@@ -231,7 +233,7 @@ func (c *ControlManager) Prepare() error {
 				}
 
 				if value.IsValid() {
-					tmpcontrol.FieldByName(field.Name()).Set(value)
+					tmpcontrol.Field(field.index).Set(value)
 				}
 			}
 
@@ -257,67 +259,65 @@ func (c *ControlManager) Execute() (state int, vw mvc.View) {
 			c.state = 200
 		}
 
-		var results []reflect.Value
-		var rstyle = c.controlmeta.ResultStyle
+		var (
+			results []reflect.Value
+			rstyle  = c.controlmeta.ResultStyle
+			handled bool
+		)
 
 		if c.state >= http.StatusOK && c.state < http.StatusMultipleChoices {
 			switch c.controlmeta.T() {
 			case ContypeFunc:
-				results = c.control.Call(getArgSlice(c.controlmeta.Args(),
-					getVMap(c.context),
-					c.context.PData))
+				args := getArgSlice(c.controlmeta.Args(), c.getVMap(), c.bindTree(), c.validation)
+				if c.replyValidation(false) {
+					c.executed = true
+					state = -1
+					return
+				}
+				results = c.control.Call(args)
+				vw, handled = c.actionResult(rstyle, results)
 			default:
 				actmeta := c.ActionMeta()
 				if actmeta != nil {
-					meth := c.control.MethodByName(actmeta.Name())
-					results = meth.Call(getArgSlice(actmeta.Args(),
-						getVMap(c.context),
-						c.context.PData))
-					rstyle = actmeta.ResultStyle
+					vw, handled = c.executeAction(actmeta)
+				} else if c.controlmeta.HasAction(c.action) {
+					// the action exists, just not for the requested method
+					c.context.SetHeader("Allow", router.AllowHeader(c.AvailableMethodsList()))
+					c.state = http.StatusMethodNotAllowed
+					state = c.state
+					return
 				} else {
 					c.state = 404
 					state = c.state
 					return
 				}
 			}
+		} else {
+			vw, handled = c.actionResult(rstyle, results)
 		}
 
-		if rstyle.Status() {
-			c.state = int(results[1].Int())
-		}
-
-		if rstyle.View() || rstyle.Vmap() || rstyle.MapSI() {
+		if handled {
+			// if state returned is -1, it means the controller has handled the response
+			state = -1
+			vw = nil
+		} else {
 			// for a consistent error page, error should be returned instead and allow sunny server itself
 			// to render the error page
 			state = c.state
 
 			if state == 200 || state == 0 {
-				if rstyle.View() {
-					if !results[0].IsNil() && results[0].IsValid() {
-						c.vw = (results[0].Interface()).(mvc.View)
-					}
-				} else {
-					var vmap mvc.VM
-
-					if results[0].IsNil() || !results[0].IsValid() {
-						vmap = mvc.VM{}
-					} else if rstyle.Vmap() {
-						vmap = results[0].Interface().(mvc.VM)
-					} else {
-						vmap = mvc.VM(results[0].Interface().(map[string]interface{}))
-					}
-
-					c.vw = view.NewResultView(vmap)
-				}
-
-				vw = c.vw
+				c.vw = vw
 				if vw == nil {
 					state = -1
+				} else {
+					if dview, ok := vw.(mvc.DataView); ok {
+						dview.SetData("Validation", c.validation)
+					}
+					c.setLayout(vw)
 				}
+			} else {
+				vw = nil
 			}
-		} else {
-			// if state returned is -1, it means the controller has handled the response
-			state = -1
 		}
 
 		c.executed = true
@@ -328,6 +328,66 @@ func (c *ControlManager) Execute() (state int, vw mvc.View) {
 	return
 }
 
+// setLayout sets the layout of the view to the one the controller chooses for the action (see mvc.Layouter),
+// unless the action has set one already
+func (c *ControlManager) setLayout(vw mvc.View) {
+	if lview, ok := vw.(mvc.LayoutView); ok && lview.Layout() == "" {
+		if layouter, ok := c.control.Interface().(mvc.Layouter); ok {
+			lview.SetLayout(layouter.Layout_(c.action))
+		}
+	}
+}
+
+// actionResult returns the view of the results of an action, setting the state if the action returns one;
+// handled is true if the action does not return a view, i.e. it has handled the response itself
+func (c *ControlManager) actionResult(rstyle ResultStyle, results []reflect.Value) (vw mvc.View, handled bool) {
+	if rstyle.Status() {
+		c.state = int(results[1].Int())
+	}
+
+	if rstyle.IsNil() {
+		return nil, true
+	}
+
+	if rstyle.Err() && len(results) == 2 {
+		if errv := results[1]; errv.IsValid() && !errv.IsNil() {
+			return c.actionError(rstyle, errv.Interface().(error))
+		}
+	}
+
+	if rstyle.Typed() && c.state == 0 {
+		// a status left unset is a 200, as it is for the other results
+		c.state = http.StatusOK
+	}
+
+	if rstyle.Typed() && c.state >= http.StatusOK && c.state < http.StatusMultipleChoices {
+		// the view replies with the status instead (e.g. 201 of a create action)
+		return c.typedResult(results[0]), false
+	}
+
+	if c.state == 200 || c.state == 0 {
+		if rstyle.View() {
+			if results[0].IsValid() && !results[0].IsNil() {
+				vw = (results[0].Interface()).(mvc.View)
+			}
+		} else {
+			var vmap mvc.VM
+
+			if !results[0].IsValid() || results[0].IsNil() {
+				vmap = mvc.VM{}
+			} else if rstyle.Vmap() {
+				vmap = results[0].Interface().(mvc.VM)
+			} else {
+				vmap = mvc.VM(results[0].Interface().(map[string]interface{}))
+			}
+
+			vw = view.NewResultView(vmap)
+		}
+	}
+
+	return
+}
+
 func (c *ControlManager) PublishView() (err error) {
 	if !c.prepared {
 		err = ErrUnprepared
@@ -362,75 +422,190 @@ func getVMap(context *web.Context) map[string]reflect.Value {
 	}
 }
 
-func getArgSlice(args []*ArgMeta, vmap map[string]reflect.Value, d web.PData) (values []reflect.Value) {
+func (c *ControlManager) getVMap() (vmap map[string]reflect.Value) {
+	vmap = getVMap(c.context)
+	vmap["validation"] = reflect.ValueOf(c.validation)
+	return
+}
+
+// SetBindSource sets the request data that arguments and fields are bound from,
+// it must be called before Prepare
+func (c *ControlManager) SetBindSource(src BindSource) {
+	c.bindsrc = src
+	c.bindtree = nil
+}
+
+func (c *ControlManager) BindSource() BindSource {
+	return c.bindsrc
+}
+
+// bindTree returns the data that arguments and fields are bound from,
+// which is the path data and the request values allowed by the bind source;
+// a path var takes precedence over a form value of the same name
+func (c *ControlManager) bindTree() *bindNode {
+	if c.bindtree == nil {
+		var (
+			form  url.Values
+			pdata web.PData
+			r     = c.context.Request
+		)
+
+		if r != nil && c.bindsrc&(BindBody|BindQuery) != 0 {
+			c.context.WaitRequestData()
+
+			switch body, query := c.bindsrc&BindBody != 0, c.bindsrc&BindQuery != 0; {
+			case body && query:
+				form = r.Form
+			case body:
+				form = r.PostForm
+			case query:
+				form = r.URL.Query()
+			}
+		}
+
+		if c.bindsrc&BindPath != 0 {
+			pdata = c.context.PData
+		}
+
+		c.bindtree = newBindTree(form, pdata)
+	}
+
+	return c.bindtree
+}
+
+func getArgSlice(args []*ArgMeta, vmap map[string]reflect.Value, n *bindNode, vd *Validation) (values []reflect.Value) {
 	values = make([]reflect.Value, len(args))
 
 	for i, arg := range args {
-		values[i] = getDataValue(&arg.DataMeta, vmap, d)
+		values[i] = getDataValue(&arg.DataMeta, vmap, n, vd)
 	}
 
 	return
 }
 
-func getDataValue(arg *DataMeta, vmap map[string]reflect.Value, d web.PData) (value reflect.Value) {
+// getFieldValue is getDataValue with the validate (and regexp) tag of the field checked beforehand;
+// a value that fails is recorded in vd and the field is left with its zero value
+func getFieldValue(field *FieldMeta, vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+	if len(field.rules) > 0 {
+		var (
+			lname = field.LName()
+			rtype = field.RType()
+			kind  = rtype.Kind()
+			errs  []validate.RuleError
+		)
+
+		if kind == reflect.Ptr {
+			kind = rtype.Elem().Kind()
+		}
+
+		if isCompositeType(field.T()) {
+			// only the presence of a struct, slice or map can be checked
+			if field.rules.Has("required") && n.child(lname) == nil {
+				errs = field.rules.Validate("", kind, nil)
+			}
+		} else {
+			errs = field.rules.Validate(n.data[lname], kind, func(name string) string {
+				return n.data[strings.ToLower(name)]
+			})
+		}
+
+		if len(errs) > 0 {
+			key := n.key(lname)
+			for _, err := range errs {
+				vd.Add(key, err.Rule, key+" "+err.Message)
+			}
+			return reflect.Zero(rtype)
+		}
+	}
+
+	return getDataValue(&field.DataMeta, vmap, n, vd)
+}
+
+func getDataValue(arg *DataMeta, vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+	if arg.decode != nil {
+		return arg.decode(vmap, n, vd)
+	}
+	return compileDecoder(arg)(vmap, n, vd)
+}
+
+// dataDecoder returns the value of an argument or field for a request
+type dataDecoder func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value
+
+// compileDecoder returns the decoder of the data type,
+// so the type does not have to be looked into again for every request
+func compileDecoder(arg *DataMeta) dataDecoder {
 	switch arg.T() {
 	case DatatypeWebContext:
-		value = vmap["context"]
+		return vmapDecoder("context", arg.RType())
 	case DatatypeRequest:
-		value = vmap["r"]
+		return vmapDecoder("r", arg.RType())
 	case DatatypeResponseWriter:
-		value = vmap["w"]
+		return vmapDecoder("w", arg.RType())
 	case DatatypeUpath:
-		value = vmap["upath"]
+		return vmapDecoder("upath", arg.RType())
 	case DatatypeUpathSlice:
-		value = vmap["upath_slice"]
+		return vmapDecoder("upath_slice", arg.RType())
 	case DatatypePdata:
-		value = vmap["pdata"]
+		return vmapDecoder("pdata", arg.RType())
 	case DatatypePdataMap:
-		value = vmap["pdata_map"]
-	case DatatypeString:
-		val, _ := d.String(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeInt:
-		val, _ := d.Int(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeInt64:
-		val, _ := d.Int64(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeFloat:
-		val, _ := d.Float32(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeFloat64:
-		val, _ := d.Float64(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeEmail:
-		val, _ := d.Email(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeURL:
-		val, _ := d.Url(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeDate:
-		val, _ := d.Date(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeTime:
-		val, _ := d.Time(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeDateTime:
-		val, _ := d.DateTime(arg.LName())
-		value = reflect.ValueOf(val)
-	case DatatypeStruct, DatatypeEmbedded:
-		fields := arg.Fields()
-		model := reflect.New(arg.RType())
-		modelval := model.Elem()
-		for _, field := range fields {
-			modelval.FieldByName(field.Name()).Set(getDataValue(&field.DataMeta, vmap, d))
+		return vmapDecoder("pdata_map", arg.RType())
+	case DatatypeValidation:
+		return vmapDecoder("validation", arg.RType())
+	case DatatypeView:
+		// the view and next are only given to filters
+		return vmapDecoder("view", arg.RType())
+	case DatatypeNext:
+		return vmapDecoder("next", arg.RType())
+	case DatatypeEmbedded:
+		return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+			return bindComposite(arg, vmap, n, vd)
 		}
-		if arg.RType().Kind() == reflect.Ptr {
-			value = model
-		} else {
-			value = modelval
+	case DatatypeStruct:
+		// a struct binds from its own node (e.g. address.city) if there is one,
+		// otherwise its fields are taken from the same level as itself
+		return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+			if child := n.child(arg.LName()); child != nil && len(child.children) > 0 {
+				return bindComposite(arg, vmap, child, vd)
+			}
+			return bindComposite(arg, vmap, n, vd)
+		}
+	case DatatypeInject:
+		return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+			return injectValue(arg, vmap)
+		}
+	case DatatypeSlice, DatatypeMap:
+		if arg.T() != DatatypeSlice || arg.elem.T() != DatatypeUploadedFile {
+			zero := reflect.Zero(arg.RType())
+			return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+				if child := n.child(arg.LName()); child != nil {
+					return bindComposite(arg, vmap, child, vd)
+				}
+				return zero
+			}
+		}
+		fallthrough
+	case DatatypeUploadedFile:
+		return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+			return bindUploads(arg, vmap, n.key(arg.LName()), vd)
+		}
+	default:
+		return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+			return bindScalar(arg, n.data, arg.LName(), n.key(arg.LName()), vd)
 		}
 	}
+}
 
-	return
+// vmapDecoder returns the value of the request under the key, or the zero value if there is none
+func vmapDecoder(key string, rtype reflect.Type) dataDecoder {
+	var zero reflect.Value
+	if rtype != nil {
+		zero = reflect.Zero(rtype)
+	}
+
+	return func(vmap map[string]reflect.Value, n *bindNode, vd *Validation) reflect.Value {
+		if value, exists := vmap[key]; exists && value.IsValid() {
+			return value
+		}
+		return zero
+	}
 }
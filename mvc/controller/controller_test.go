@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+type methodCtrl struct{}
+
+func (c *methodCtrl) GETList() mvc.VM  { return mvc.VM{} }
+func (c *methodCtrl) POSTList() mvc.VM { return mvc.VM{} }
+
+func TestMethodNotAllowed(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&methodCtrl{})
+
+	for _, c := range []struct {
+		method, action string
+		state          int
+		allow          string
+	}{
+		{"DELETE", "list", http.StatusMethodNotAllowed, "GET, POST, HEAD, OPTIONS"},
+		{"GET", "missing", http.StatusNotFound, ""},
+	} {
+		w := httptest.NewRecorder()
+		ctxt := web.NewContext(w, httptest.NewRequest(c.method, "/"+c.action, nil))
+
+		ctrlmgr := NewControlManager(ctxt, cm, c.action)
+		ctrlmgr.Prepare()
+
+		if state, _ := ctrlmgr.Execute(); state != c.state || w.Header().Get("Allow") != c.allow {
+			t.Errorf("%s %s: got %d, Allow %q", c.method, c.action, state, w.Header().Get("Allow"))
+		}
+	}
+}
+
+func TestInvalidController(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(string); err != "controller string has to be a struct, a pointer to a struct or a func" {
+			t.Errorf("got %v", err)
+		}
+	}()
+
+	MakeControllerMeta("index")
+}
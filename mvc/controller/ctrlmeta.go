@@ -25,7 +25,7 @@ const (
 type ReqMethod uint16
 
 const (
-	// the first four must be get, post, put and delete (the order is less impt)
+	// the first five must be get, post, put, delete and patch (the order is less impt)
 	// since ActionMap.Add() depends on it to work correctly
 	ReqMethodGet ReqMethod = 1 << iota
 	ReqMethodPost
@@ -40,7 +40,12 @@ const (
 )
 
 func GetReqMethod(r *http.Request) ReqMethod {
-	switch r.Method {
+	return ReqMethodFromString(r.Method)
+}
+
+// ReqMethodFromString returns the ReqMethod of the upper case name of a method, 0 if it is unknown
+func ReqMethodFromString(method string) ReqMethod {
+	switch method {
 	case "GET":
 		return ReqMethodGet
 	case "POST":
@@ -70,6 +75,8 @@ func GetXReqMethod(ctxt *web.Context) ReqMethod {
 		return ReqMethodPut
 	case "DELETE":
 		return ReqMethodDelete
+	case "PATCH":
+		return ReqMethodPatch
 	default:
 	}
 
@@ -87,6 +94,7 @@ type Meta struct {
 	args    []*ArgMeta
 	fields  []*FieldMeta
 	t       Type
+	bindsrc BindSource
 	ResultStyle
 }
 
@@ -94,7 +102,7 @@ func (a ActionMap) Add(name string, am *ActionMeta) {
 	if _, exists := a[name]; !exists {
 		a[name] = make(map[ReqMethod]*ActionMeta)
 	}
-	for i := uint16(0); i < 4; i++ {
+	for i := uint16(0); i < 5; i++ {
 		reqtype := ReqMethod(1 << i)
 		if (am.reqmeth & reqtype) == reqtype {
 			a[name][reqtype] = am
@@ -129,7 +137,13 @@ func (a ActionMap) GetReqMethList(name string) (rml []string) {
 	if actions, exists := a[name]; exists {
 		rml = make([]string, 0, len(actions))
 
-		for rmeth := range actions {
+		// iterate through the bits instead of the map so that the list is always in the same order
+		for i := uint16(0); i < 7; i++ {
+			rmeth := ReqMethod(1 << i)
+			if _, exists := actions[rmeth]; !exists {
+				continue
+			}
+
 			switch rmeth {
 			case ReqMethodGet:
 				rml = append(rml, "GET")
@@ -172,8 +186,14 @@ func (cm *Meta) Action(name string, reqtype ReqMethod) *ActionMeta {
 	return cm.meths.Get(name, reqtype)
 }
 
+// ActionFromRequest returns the action of the request method (or the method given by X-HTTP-Method-Override);
+// a PATCH request is served by the PUT action if there is no PATCH action
 func (cm Meta) ActionFromRequest(name string, ctxt *web.Context) *ActionMeta {
-	return cm.Action(name, GetXReqMethod(ctxt))
+	reqmeth := GetXReqMethod(ctxt)
+	if am := cm.Action(name, reqmeth); am != nil || reqmeth != ReqMethodPatch {
+		return am
+	}
+	return cm.Action(name, ReqMethodPut)
 }
 
 func (cm Meta) ActionAvailableMethods(name string) ReqMethod {
@@ -236,6 +256,15 @@ func (cm *Meta) Fields() []*FieldMeta {
 	return out
 }
 
+// BindSource returns the request data that the arguments and fields of the controller are bound from,
+// which is the path vars only unless the controller or its group (see Group.SetBindSource) opts in more
+func (cm *Meta) BindSource() BindSource {
+	if cm.bindsrc == 0 {
+		return BindPath
+	}
+	return cm.bindsrc
+}
+
 func (cm *Meta) T() Type {
 	return cm.t
 }
@@ -0,0 +1,322 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/util/validate"
+)
+
+// the filters that apply to every action of a controller
+const (
+	FilterBefore = "Before_"
+	FilterAfter  = "After_"
+	FilterAround = "Around_"
+)
+
+var (
+	typeFilterer = reflect.TypeOf((*Filterer)(nil)).Elem()
+	typeNext     = reflect.TypeOf((Next)(nil))
+)
+
+// Next runs what an around filter wraps (the inner filters and the action),
+// returning the view it results in
+type Next func() mvc.View
+
+// Filter binds filter methods to the actions of a controller;
+// Before, After and Around are the names of the methods, which have to be exported and should end with '_'
+// so they are not taken as actions (e.g. RequireLogin_).
+// The filter applies to all actions if Only is empty, except those in Except.
+//
+// A filter method takes arguments the same way an action does, in addition to
+// mvc.View (the view returned by the action, for after filters) and Next (for around filters).
+// It may return a web.StatusCode and/or an mvc.View:
+// a status other than 0 or 200 from a before filter stops the action from being executed,
+// a view from an after or around filter replaces the view of the action.
+// Redirecting from a before filter also stops the action.
+type Filter struct {
+	Before string
+	After  string
+	Around string
+	Only   []string
+	Except []string
+}
+
+// Filterer is implemented by controllers with filters bound to specific actions;
+// Filters_ is called once when the controller is added, on a zero value of the controller
+type Filterer interface {
+	Filters_() []Filter
+}
+
+// appliesTo matches the action by either its name (e.g. save) or its method name (e.g. PostSave)
+func (f Filter) appliesTo(action, method string) bool {
+	if len(f.Only) > 0 && !validate.IsInFold(action, f.Only...) && !validate.IsInFold(method, f.Only...) {
+		return false
+	}
+	return !validate.IsInFold(action, f.Except...) && !validate.IsInFold(method, f.Except...)
+}
+
+type FilterMeta struct {
+	name    string
+	rmeth   reflect.Method
+	args    []*ArgMeta
+	viewout int
+	statout int
+}
+
+func (fm *FilterMeta) Name() string {
+	return fm.name
+}
+
+func (fm *FilterMeta) Args() []*ArgMeta {
+	out := make([]*ArgMeta, len(fm.args))
+	copy(out, fm.args)
+	return out
+}
+
+type actionFilters struct {
+	before []*FilterMeta
+	around []*FilterMeta
+	after  []*FilterMeta
+}
+
+func (am *ActionMeta) Filters() (before, around, after []*FilterMeta) {
+	if am.filters != nil {
+		before, around, after = am.filters.before, am.filters.around, am.filters.after
+	}
+	return
+}
+
+func parseFilterMeta(rawtype reflect.Type, name string, isprovided provided) *FilterMeta {
+	meth, exists := rawtype.MethodByName(name)
+	if !exists {
+		panic(fmt.Sprintf("filter %s of controller %s not found", name, rawtype))
+	}
+
+	fm := &FilterMeta{
+		name:    name,
+		rmeth:   meth,
+		args:    parseArgsMeta(meth.Type, true, isprovided),
+		viewout: -1,
+		statout: -1,
+	}
+
+	for i, numout := 0, meth.Type.NumOut(); i < numout; i++ {
+		switch out := meth.Type.Out(i); {
+		case out == typeStatusCode:
+			fm.statout = i
+		case out == typeMVCView || out.Implements(typeMVCView):
+			fm.viewout = i
+		}
+	}
+
+	return fm
+}
+
+// parseFilters resolves the filters of every action of the controller,
+// the controller wide Before_ and Around_ are the outermost and After_ is the last to run
+func parseFilters(cm *Meta, rawtype reflect.Type, isprovided provided) {
+	var (
+		filters []Filter
+		metas   = make(map[string]*FilterMeta)
+		getmeta = func(name string) *FilterMeta {
+			if fm, exists := metas[name]; exists {
+				return fm
+			}
+			fm := parseFilterMeta(rawtype, name, isprovided)
+			metas[name] = fm
+			return fm
+		}
+	)
+
+	for _, name := range []string{FilterBefore, FilterAround} {
+		if _, exists := rawtype.MethodByName(name); exists {
+			if name == FilterBefore {
+				filters = append(filters, Filter{Before: name})
+			} else {
+				filters = append(filters, Filter{Around: name})
+			}
+		}
+	}
+
+	if rawtype.Implements(typeFilterer) {
+		filters = append(filters, zeroController(rawtype).Interface().(Filterer).Filters_()...)
+	}
+
+	if _, exists := rawtype.MethodByName(FilterAfter); exists {
+		filters = append(filters, Filter{After: FilterAfter})
+	}
+
+	if len(filters) == 0 {
+		return
+	}
+
+	for action, ameths := range cm.meths {
+		for _, ameta := range ameths {
+			if ameta.filters != nil {
+				// the same meta is shared by the request methods of the action
+				continue
+			}
+
+			af := &actionFilters{}
+
+			for _, f := range filters {
+				if !f.appliesTo(action, ameta.name) {
+					continue
+				}
+				if f.Before != "" {
+					af.before = append(af.before, getmeta(f.Before))
+				}
+				if f.Around != "" {
+					af.around = append(af.around, getmeta(f.Around))
+				}
+				if f.After != "" {
+					af.after = append(af.after, getmeta(f.After))
+				}
+			}
+
+			ameta.filters = af
+		}
+	}
+}
+
+// callFilter calls the filter method, returning its view and status if it returns them
+func (c *ControlManager) callFilter(fm *FilterMeta, vmap map[string]reflect.Value, n *bindNode) (vw mvc.View, hasview bool, status int, hasstatus bool) {
+	args := make([]reflect.Value, len(fm.args)+1)
+	args[0] = receiverOf(fm.rmeth, c.control)
+
+	for i, arg := range fm.args {
+		args[i+1] = getDataValue(&arg.DataMeta, vmap, n, c.validation)
+	}
+
+	results := fm.rmeth.Func.Call(args)
+
+	if fm.viewout >= 0 {
+		hasview = true
+		if result := results[fm.viewout]; result.IsValid() && !(result.Kind() != reflect.Struct && result.IsNil()) {
+			vw = result.Interface().(mvc.View)
+		}
+	}
+	if fm.statout >= 0 {
+		hasstatus = true
+		status = int(results[fm.statout].Int())
+	}
+
+	return
+}
+
+// executeAction runs the action wrapped by its filters;
+// handled is true if the response has been handled by the action or a filter itself
+func (c *ControlManager) executeAction(actmeta *ActionMeta) (vw mvc.View, handled bool) {
+	var (
+		before, around, after = actmeta.Filters()
+		vmap                  = c.getVMap()
+		n                     = c.bindTree()
+	)
+
+	for _, fm := range before {
+		if !c.runBefore(fm, vmap, n) {
+			return nil, c.state == -1
+		}
+	}
+
+	args := actmeta.bindArgs(c.control, vmap, n, c.validation)
+	if c.replyValidation(actmeta.typed && !actmeta.takesValidation()) {
+		return nil, true
+	}
+
+	handled = true
+
+	next := Next(func() mvc.View {
+		results := actmeta.invoke(args)
+		avw, ahandled := c.actionResult(actmeta.ResultStyle, results)
+		handled = ahandled
+		return avw
+	})
+
+	// the first around declared is the outermost
+	for i := len(around) - 1; i >= 0; i-- {
+		next = c.around(around[i], vmap, n, next, &handled)
+	}
+
+	vw = next()
+
+	for _, fm := range after {
+		var hasview bool
+		if vw, hasview = c.runAfter(fm, vmap, n, vw); hasview {
+			handled = false
+		}
+	}
+
+	return
+}
+
+// runBefore runs a before filter, returning false if it stops the action
+func (c *ControlManager) runBefore(fm *FilterMeta, vmap map[string]reflect.Value, n *bindNode) bool {
+	_, _, status, hasstatus := c.callFilter(fm, vmap, n)
+
+	if c.context.IsRedirecting() {
+		c.state = -1
+	} else if c.context.HasErrorCode() {
+		c.state = c.context.ErrorCode()
+	} else if hasstatus && status != 0 && status != http.StatusOK {
+		c.state = status
+	} else {
+		return true
+	}
+
+	return false
+}
+
+// runAfter runs an after filter with the view, returning the view replaced by the filter (if it returns one)
+func (c *ControlManager) runAfter(fm *FilterMeta, vmap map[string]reflect.Value, n *bindNode, vw mvc.View) (mvc.View, bool) {
+	vmap["view"] = reflect.ValueOf(&vw).Elem()
+	defer delete(vmap, "view")
+
+	fvw, hasview, status, hasstatus := c.callFilter(fm, vmap, n)
+
+	if hasstatus && status != 0 {
+		c.state = status
+	}
+
+	if hasview {
+		return fvw, true
+	}
+
+	return vw, false
+}
+
+// around wraps next with the around filter; the view of next is used if the filter does not return one
+func (c *ControlManager) around(fm *FilterMeta, vmap map[string]reflect.Value, n *bindNode, next Next, handled *bool) Next {
+	return func() mvc.View {
+		var (
+			inner  mvc.View
+			called bool
+		)
+
+		vmap["next"] = reflect.ValueOf(Next(func() mvc.View {
+			inner, called = next(), true
+			return inner
+		}))
+		defer delete(vmap, "next")
+
+		fvw, hasview, status, hasstatus := c.callFilter(fm, vmap, n)
+
+		if hasstatus && status != 0 {
+			c.state = status
+		}
+
+		if hasview {
+			*handled = false
+			return fvw
+		} else if !called {
+			// the filter has not let the action run, so unless it returns an error status
+			// it must have handled the response itself
+			*handled = !hasstatus || status == 0 || status == http.StatusOK
+		}
+
+		return inner
+	}
+}
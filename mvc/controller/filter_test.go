@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+type filterCtrl struct{}
+
+func (c *filterCtrl) Filters_() []Filter {
+	return []Filter{
+		{Before: "Auth_", Only: []string{"SAVE"}},
+		{After: "Stamp_", Except: []string{"postsave"}},
+	}
+}
+
+func (c *filterCtrl) Auth_(ctxt *web.Context) web.StatusCode {
+	if ctxt.Request.Header.Get("X-Auth") == "" {
+		return 403
+	}
+	return 0
+}
+
+func (c *filterCtrl) Stamp_(ctxt *web.Context) {
+	ctxt.SetHeader("X-Stamp", "1")
+}
+
+func (c *filterCtrl) GETList() mvc.VM  { return mvc.VM{} }
+func (c *filterCtrl) POSTSave() mvc.VM { return mvc.VM{} }
+
+func TestFilterAppliesTo(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&filterCtrl{})
+
+	for _, c := range []struct {
+		method, action string
+		auth           bool
+		state          int
+		stamp          string
+	}{
+		{"GET", "list", false, 200, "1"},
+		{"POST", "save", false, 403, ""},
+		{"POST", "save", true, 200, ""},
+	} {
+		r := httptest.NewRequest(c.method, "/"+c.action, nil)
+		if c.auth {
+			r.Header.Set("X-Auth", "1")
+		}
+		ctxt := web.NewContext(httptest.NewRecorder(), r)
+
+		state, _ := NewControlManager(ctxt, cm, c.action).PrepareAndExecute()
+
+		if state != c.state || ctxt.Response.Header().Get("X-Stamp") != c.stamp {
+			t.Errorf("%s %s: got %d, X-Stamp %q", c.method, c.action, state, ctxt.Response.Header().Get("X-Stamp"))
+		}
+	}
+}
@@ -1,14 +1,19 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/util/di"
+	"github.com/zaolab/sunnified/util/validate"
 	"github.com/zaolab/sunnified/web"
 )
 
@@ -24,9 +29,14 @@ var (
 	typeUpath              = reflect.TypeOf(web.UPath{})
 	typePdata              = reflect.TypeOf(web.PData{})
 	typeStatusCode         = reflect.TypeOf((web.StatusCode)(0))
+	typeError              = reflect.TypeOf((*error)(nil)).Elem()
 	typeTimeTime           = reflect.TypeOf((*time.Time)(nil))
 	typeTimeDuration       = reflect.TypeOf((*time.Duration)(nil))
 	typeWebContext         = reflect.TypeOf((*web.Context)(nil))
+	typeValidation         = reflect.TypeOf((*Validation)(nil))
+	typeUploadedFile       = reflect.TypeOf((*web.UploadedFile)(nil))
+	typeSliceUploadedFile  = reflect.TypeOf([]*web.UploadedFile{})
+	typeBindSourcer        = reflect.TypeOf((*BindSourcer)(nil)).Elem()
 
 	lenArgtypeStringSuffix   = len(DatatypeStringSuffix)
 	lenArgtypeBoolSuffix     = len(DatatypeBoolSuffix)
@@ -61,10 +71,44 @@ func GetDefaultControllerGroup() *Group {
 type Group struct {
 	details  map[string]map[string]*Meta
 	modules  map[string]string
+	services *di.Container
+	bindsrc  BindSource
 	detmutex sync.RWMutex
 	modmutex sync.RWMutex
 }
 
+// SetContainer sets the container of the services injected into the controllers added after it
+func (cg *Group) SetContainer(services *di.Container) {
+	cg.detmutex.Lock()
+	defer cg.detmutex.Unlock()
+	cg.services = services
+}
+
+// SetBindSource sets the request data that the controllers added after it are bound from,
+// unless they are BindSourcers; a new group binds the path vars only
+func (cg *Group) SetBindSource(src BindSource) {
+	cg.detmutex.Lock()
+	defer cg.detmutex.Unlock()
+	cg.bindsrc = src
+}
+
+// MakeControllerMeta parses the controller with the container and the bind source of the group,
+// without adding it to the group
+func (cg *Group) MakeControllerMeta(cinterface interface{}) (cm *Meta, ctrlname, mod, modfull string) {
+	cg.detmutex.RLock()
+	services, bindsrc := cg.services, cg.bindsrc
+	cg.detmutex.RUnlock()
+
+	return cg.makeControllerMeta(cinterface, services, bindsrc)
+}
+
+func (cg *Group) makeControllerMeta(cinterface interface{}, services *di.Container, bindsrc BindSource) (cm *Meta, ctrlname, mod, modfull string) {
+	if cm, ctrlname, mod, modfull = MakeControllerMeta(cinterface, services); cm.bindsrc == 0 {
+		cm.bindsrc = bindsrc
+	}
+	return
+}
+
 func (cg *Group) HasModule(mod string) bool {
 	cg.detmutex.RLock()
 	defer cg.detmutex.RUnlock()
@@ -73,6 +117,20 @@ func (cg *Group) HasModule(mod string) bool {
 	return exists
 }
 
+// Modules returns the names of the modules of the group, in order
+func (cg *Group) Modules() (mods []string) {
+	cg.detmutex.RLock()
+	defer cg.detmutex.RUnlock()
+
+	mods = make([]string, 0, len(cg.details))
+	for mod := range cg.details {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+
+	return
+}
+
 func (cg *Group) Module(mod string) (m map[string]*Meta) {
 	cg.detmutex.RLock()
 	defer cg.detmutex.RUnlock()
@@ -122,7 +180,7 @@ func (cg *Group) AddController(cinterface interface{}) (string, string) {
 	cg.detmutex.Lock()
 	defer cg.detmutex.Unlock()
 
-	cm, controller, alias, modname := MakeControllerMeta(cinterface)
+	cm, controller, alias, modname := cg.makeControllerMeta(cinterface, cg.services, cg.bindsrc)
 
 	if cg.details[alias] == nil {
 		cg.details[alias] = make(map[string]*Meta)
@@ -176,7 +234,33 @@ func AddController(cinterface interface{}) (string, string) {
 	return group.AddController(cinterface)
 }
 
-func MakeControllerMeta(cinterface interface{}) (cm *Meta, ctrlname, mod, modfull string) {
+// MakeControllerMeta parses the controller; the arguments and sunnified.inject fields
+// which are not bound from the request have to be of a type provided by one of the services,
+// otherwise it panics with di.ErrNotProvided
+func MakeControllerMeta(cinterface interface{}, services ...*di.Container) (cm *Meta, ctrlname, mod, modfull string) {
+	return makeControllerMeta(cinterface, func(t reflect.Type) bool {
+		for _, c := range services {
+			if c != nil && c.Has(t) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// zeroController returns a zero value of the controller type, a new one if it is a pointer,
+// on which the methods describing the controller (e.g. Filters_) are called
+func zeroController(rawtype reflect.Type) reflect.Value {
+	if rawtype.Kind() == reflect.Ptr {
+		return reflect.New(rawtype.Elem())
+	}
+	return reflect.Zero(rawtype)
+}
+
+// provided reports whether the type can be injected
+type provided func(reflect.Type) bool
+
+func makeControllerMeta(cinterface interface{}, isprovided provided) (cm *Meta, ctrlname, mod, modfull string) {
 	var (
 		reqmeth ReqMethod
 		ownname string
@@ -211,7 +295,7 @@ func MakeControllerMeta(cinterface interface{}) (cm *Meta, ctrlname, mod, modful
 	switch rtype.Kind() {
 	case reflect.Func:
 		cm.t = ContypeFunc
-		cm.args = parseArgsMeta(rtype, false)
+		cm.args = parseArgsMeta(rtype, false, isprovided)
 
 		var isconstruct bool
 		if cm.ResultStyle, isconstruct = parseResultStyle(rtype, true); isconstruct {
@@ -228,6 +312,13 @@ func MakeControllerMeta(cinterface interface{}) (cm *Meta, ctrlname, mod, modful
 		}
 
 		cm.fields = parseFieldsMeta(rtype)
+		checkInjected(rtype, cm.fields, isprovided)
+
+		if rawtype.Implements(typeBindSourcer) {
+			cm.bindsrc = zeroController(rawtype).Interface().(BindSourcer).BindSource_()
+		}
+	default:
+		panic(fmt.Sprintf("controller %s has to be a struct, a pointer to a struct or a func", rawtype))
 	}
 
 	for i, count := 0, rawtype.NumMethod(); i < count; i++ {
@@ -253,11 +344,19 @@ func MakeControllerMeta(cinterface interface{}) (cm *Meta, ctrlname, mod, modful
 		if ameta.ResultStyle, _ = parseResultStyle(meth.Type, false); ameta.ResultStyle.IsNil() {
 			continue
 		}
+		// a method returning a plain value is only a typed action when it is named after a request method,
+		// so that helpers like LoadUser() User are not routed
+		if ameta.typed && reqmeth == ReqMethodCommon {
+			continue
+		}
 
-		ameta.args = parseArgsMeta(meth.Type, rawtype.Kind() == reflect.Ptr)
+		ameta.args = parseArgsMeta(meth.Type, rawtype.Kind() == reflect.Ptr, isprovided)
+		ameta.invoker = newActionInvoker(rawtype, meth)
 		cm.meths.Add(action, ameta)
 	}
 
+	parseFilters(cm, rawtype, isprovided)
+
 	return
 }
 
@@ -271,6 +370,17 @@ func parseResultStyle(rtype reflect.Type, iscontrol bool) (rs ResultStyle, iscon
 			outkind = out.Elem().Kind()
 		}
 
+		// check for status or error output
+		if numout == 2 {
+			out := rtype.Out(1)
+
+			if out.Kind() == reflect.Int && out == typeStatusCode {
+				rs.status = true
+			} else if out == typeError {
+				rs.err = true
+			}
+		}
+
 		if outkind == reflect.Struct {
 			if out.Implements(typeMVCView) {
 				rs.view = true
@@ -281,6 +391,8 @@ func parseResultStyle(rtype reflect.Type, iscontrol bool) (rs ResultStyle, iscon
 				rtype = out
 				isconstruct = true
 				rs, _ = parseResultStyle(rtype, false)
+			} else if !iscontrol && (numout == 1 || rs.status || rs.err) {
+				rs.typed = true
 			}
 		} else if out == typeMVCView {
 			rs.view = true
@@ -292,22 +404,17 @@ func parseResultStyle(rtype reflect.Type, iscontrol bool) (rs ResultStyle, iscon
 			rs.mapsi = true
 		} else if out.Implements(typeMVCView) {
 			rs.view = true
-		}
-
-		// check for status output
-		if numout == 2 {
-			out := rtype.Out(1)
-
-			if out.Kind() == reflect.Int && out == typeStatusCode {
-				rs.status = true
-			}
+		} else if !iscontrol && (outkind == reflect.Slice || outkind == reflect.Map) && out.Kind() != reflect.Ptr &&
+			(numout == 1 || rs.status || rs.err) {
+			// a slice or map of values (e.g. []Item) as a typed result
+			rs.typed = true
 		}
 	}
 
 	return
 }
 
-func parseArgsMeta(rtype reflect.Type, isptr bool) (args []*ArgMeta) {
+func parseArgsMeta(rtype reflect.Type, isptr bool, isprovided provided) (args []*ArgMeta) {
 	start := 0
 	if isptr {
 		start = 1
@@ -347,6 +454,15 @@ func parseArgsMeta(rtype reflect.Type, isptr bool) (args []*ArgMeta) {
 			argmeta.t = DatatypePdata
 		case arg == typeMapStringString:
 			argmeta.t = DatatypePdataMap
+		case arg == typeValidation:
+			argmeta.t = DatatypeValidation
+		case arg == typeMVCView:
+			argmeta.t = DatatypeView
+		case arg == typeNext:
+			argmeta.t = DatatypeNext
+		case arg == typeUploadedFile, arg == typeSliceUploadedFile, arg == typeUploadedFile.Elem():
+			// an argument has no name, so it takes the files of all names
+			parseDataType(&argmeta.DataMeta, arg, "", false, nil)
 		case argkind == reflect.Struct:
 			switch {
 			case strings.HasSuffix(argname, DatatypeDateSuffix):
@@ -361,6 +477,7 @@ func parseArgsMeta(rtype reflect.Type, isptr bool) (args []*ArgMeta) {
 			default:
 				argmeta.t = DatatypeStruct
 				argmeta.fields = parseFieldsMeta(arg)
+				checkInjected(arg, argmeta.fields, isprovided)
 			}
 		case argkind == reflect.String:
 			switch {
@@ -390,6 +507,18 @@ func parseArgsMeta(rtype reflect.Type, isptr bool) (args []*ArgMeta) {
 			argmeta.t = DatatypeBool
 			argmeta.lname = argmeta.lname[:namelen-lenArgtypeBoolSuffix]
 		}
+
+		// anything else (e.g. a pointer or an interface) is taken from the services of the request,
+		// an argument which is neither bound nor provided could not be given a value
+		if argmeta.t == 0 {
+			if !isprovided(arg) {
+				panic(fmt.Errorf("%w: %s, argument %d of %s", di.ErrNotProvided, arg, i, rtype))
+			}
+			argmeta.t = DatatypeInject
+		}
+
+		argmeta.decode = compileDecoder(&argmeta.DataMeta)
+
 		args[argi] = argmeta
 		argi++
 	}
@@ -397,23 +526,45 @@ func parseArgsMeta(rtype reflect.Type, isptr bool) (args []*ArgMeta) {
 	return
 }
 
+// checkInjected panics if a sunnified.inject field of the struct (or of its embedded structs) is not provided
+func checkInjected(rtype reflect.Type, fields []*FieldMeta, isprovided provided) {
+	for _, field := range fields {
+		if field.t == DatatypeInject && !isprovided(field.rtype) {
+			panic(fmt.Errorf("%w: %s, field %s of %s", di.ErrNotProvided, field.rtype, field.name, rtype))
+		}
+		if field.anonymous {
+			checkInjected(field.rtype, field.fields, isprovided)
+		}
+	}
+}
+
 func parseFieldsMeta(rtype reflect.Type) (fields []*FieldMeta) {
+	return parseFieldsMetaOf(rtype, nil)
+}
+
+// parseFieldsMetaOf keeps the struct types being parsed in parents,
+// so that a type containing itself (e.g. through a slice) does not recurse endlessly
+func parseFieldsMetaOf(rtype reflect.Type, parents []reflect.Type) (fields []*FieldMeta) {
+	for _, parent := range parents {
+		if parent == rtype {
+			return
+		}
+	}
+	parents = append(parents, rtype)
+
 	numfields := rtype.NumField()
 	fields = make([]*FieldMeta, 0, numfields)
 
 	for i := 0; i < numfields; i++ {
 		field := rtype.Field(i)
-		fieldtype := field.Type
-		fname, fkind := field.Name, fieldtype.Kind()
-		if fkind == reflect.Ptr {
-			fkind = fieldtype.Elem().Kind()
-		}
+		fname := field.Name
 
 		// TODO: optimise/refactor this crap
 		lname := strings.ToLower(fname)
 		isForm := strings.HasPrefix(lname, FormValueTypeLprefix)
-		isAccepted := field.Type == typeWebContext || field.Type == typeResponseWriter || field.Type == typeRequest || field.Anonymous || field.Tag.Get(FormValueTypeTagName) != ""
-		isParser := field.Tag.Get(StructValueFeedTag) != "" || field.Tag.Get(StructValueResTag) != ""
+		isAccepted := field.Type == typeWebContext || field.Type == typeResponseWriter || field.Type == typeRequest || field.Type == typeValidation || field.Type == typeUploadedFile || field.Type == typeUploadedFile.Elem() || field.Type == typeSliceUploadedFile || field.Anonymous || field.Tag.Get(FormValueTypeTagName) != "" || field.Tag.Get(validate.TagName) != ""
+		_, isInject := field.Tag.Lookup(StructValueInjectTag)
+		isParser := field.Tag.Get(StructValueFeedTag) != "" || field.Tag.Get(StructValueResTag) != "" || isInject
 
 		// only exported fields (where PkgPath == "") can have their values set
 		// and fields which have a prefix of form_ or has a type tag
@@ -431,62 +582,35 @@ func parseFieldsMeta(rtype reflect.Type) (fields []*FieldMeta) {
 			},
 			tag:       field.Tag,
 			anonymous: field.Anonymous,
+			index:     i,
 		}
 
-		if isForm || isAccepted {
-			switch fkind {
-			case reflect.Struct:
-				switch field.Type {
-				case typeWebContext:
-					fmeta.t = DatatypeWebContext
-				case typeResponseWriter:
-					fmeta.t = DatatypeResponseWriter
-				case typeRequest:
-					fmeta.t = DatatypeRequest
-				case typeTimeTime:
-					fmeta.rtype = typeTimeTime
-					if field.Tag.Get(FormValueTypeTagName) == "date" {
-						fmeta.t = DatatypeDate
-					} else {
-						fmeta.t = DatatypeDateTime
-					}
-				case typeTimeDuration:
-					fmeta.rtype = typeTimeDuration
-					fmeta.t = DatatypeTime
-				default:
-					if field.Anonymous {
-						fmeta.t = DatatypeEmbedded
-					} else {
-						fmeta.t = DatatypeStruct
-					}
-					fmeta.fields = parseFieldsMeta(field.Type)
-				}
-			case reflect.String:
-				switch field.Tag.Get(FormValueTypeTagName) {
-				case "email":
-					fmeta.t = DatatypeEmail
-				case "url":
-					fmeta.t = DatatypeURL
-				default:
-					fmeta.t = DatatypeString
-				}
-			case reflect.Int:
-				fmeta.t = DatatypeInt
-			case reflect.Int64:
-				fmeta.t = DatatypeInt64
-			case reflect.Float32:
-				fmeta.t = DatatypeFloat
-			case reflect.Float64:
-				fmeta.t = DatatypeFloat64
-			case reflect.Bool:
-				fmeta.t = DatatypeBool
+		if feed := field.Tag.Get(StructValueFeedTag); feed != "" {
+			for _, res := range strings.Split(feed, ",") {
+				fmeta.feeds = append(fmeta.feeds, strings.TrimSpace(res))
 			}
+		} else {
+			fmeta.res = strings.TrimSpace(field.Tag.Get(StructValueResTag))
 		}
 
+		if isInject {
+			fmeta.t = DatatypeInject
+		} else if isForm || isAccepted {
+			parseDataType(&fmeta.DataMeta, field.Type, field.Tag, field.Anonymous, parents)
+		}
+
+		rules, err := validate.FieldRules(rtype, field)
+		if err != nil {
+			panic(err)
+		}
+		fmeta.rules = rules
+
 		if rex := field.Tag.Get("regexp"); rex != "" {
 			fmeta.rex = regexp.MustCompile(rex)
 		}
 
+		fmeta.decode = compileDecoder(&fmeta.DataMeta)
+
 		// since some are not exported, we gotten use append instead of directly assigning to i
 		fields = append(fields, fmeta)
 	}
@@ -494,6 +618,144 @@ func parseFieldsMeta(rtype reflect.Type) (fields []*FieldMeta) {
 	return
 }
 
+// parseDataType sets the data type of a field (or of the elements of a slice or map field);
+// pointers are typed by what they point to and are bound only when the value is given
+func parseDataType(dm *DataMeta, rtype reflect.Type, tag reflect.StructTag, anonymous bool, parents []reflect.Type) {
+	switch rtype {
+	case typeValidation:
+		dm.t = DatatypeValidation
+		return
+	case typeWebContext:
+		dm.t = DatatypeWebContext
+		return
+	case typeResponseWriter:
+		dm.t = DatatypeResponseWriter
+		return
+	case typeRequest:
+		dm.t = DatatypeRequest
+		return
+	case typeUploadedFile:
+		dm.t = DatatypeUploadedFile
+		dm.upload = parseUploadRules(tag)
+		return
+	case typeUploadedFile.Elem():
+		// the file keeps its sniffed type and scan result, which a copy would not share
+		owner := "an action"
+		if len(parents) > 0 {
+			owner = parents[len(parents)-1].String()
+		}
+		panic(fmt.Sprintf("%s of %s has to be a *web.UploadedFile", dm.name, owner))
+	}
+
+	etype := rtype
+	if etype.Kind() == reflect.Ptr {
+		etype = etype.Elem()
+	}
+
+	switch etype {
+	case typeTimeTime.Elem():
+		dm.layout = tag.Get(FormValueLayoutTagName)
+		if tag.Get(FormValueTypeTagName) == "date" {
+			dm.t = DatatypeDate
+		} else {
+			dm.t = DatatypeDateTime
+		}
+		return
+	case typeTimeDuration.Elem():
+		dm.t = DatatypeTime
+		return
+	}
+
+	switch etype.Kind() {
+	case reflect.Struct:
+		// the fields of a struct that are not bound (e.g. those decoded from a JSON body by their json tags)
+		// are still validated, so their tags are checked along with the rest
+		if err := validate.CheckStruct(etype); err != nil {
+			panic(err)
+		}
+
+		if anonymous {
+			dm.t = DatatypeEmbedded
+		} else {
+			dm.t = DatatypeStruct
+		}
+		dm.fields = parseFieldsMetaOf(etype, parents)
+	case reflect.Slice, reflect.Map:
+		if etype.Kind() == reflect.Map {
+			if kkind := etype.Key().Kind(); kkind != reflect.String && !isIntKind(kkind) && !isUintKind(kkind) {
+				return
+			}
+			dm.t = DatatypeMap
+		} else {
+			dm.t = DatatypeSlice
+		}
+
+		dm.elem = &DataMeta{rtype: etype.Elem()}
+		parseDataType(dm.elem, etype.Elem(), tag, false, parents)
+
+		// uploaded files are only bound to a slice
+		if dm.elem.t == 0 || (dm.t == DatatypeMap && dm.elem.t == DatatypeUploadedFile) {
+			dm.t, dm.elem = 0, nil
+		}
+	case reflect.String:
+		switch tag.Get(FormValueTypeTagName) {
+		case "email":
+			dm.t = DatatypeEmail
+		case "url":
+			dm.t = DatatypeURL
+		default:
+			dm.t = DatatypeString
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		dm.t = DatatypeInt
+	case reflect.Int64:
+		dm.t = DatatypeInt64
+	case reflect.Float32:
+		dm.t = DatatypeFloat
+	case reflect.Float64:
+		dm.t = DatatypeFloat64
+	case reflect.Bool:
+		dm.t = DatatypeBool
+	}
+}
+
+func parseUploadRules(tag reflect.StructTag) (rules web.UploadRules) {
+	if maxsize := tag.Get(UploadMaxSizeTagName); maxsize != "" {
+		rules.MaxSize, _ = strconv.ParseInt(maxsize, 10, 64)
+	}
+
+	for _, ext := range strings.FieldsFunc(tag.Get(UploadExtTagName), isTagListSep) {
+		if ext[0] != '.' {
+			ext = "." + ext
+		}
+		rules.Exts = append(rules.Exts, strings.ToLower(ext))
+	}
+
+	rules.Types = strings.FieldsFunc(tag.Get(UploadTypeTagName), isTagListSep)
+
+	return
+}
+
+func isTagListSep(r rune) bool {
+	return r == ',' || r == ' '
+}
+
+func isIntKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
 func parseReqMethod(name string) (alias string, reqmeth ReqMethod) {
 	alias = strings.ToLower(name)
 	reqmeth = ReqMethodCommon
@@ -511,6 +773,9 @@ func parseReqMethod(name string) (alias string, reqmeth ReqMethod) {
 	case strings.HasPrefix(name, "DELETE"):
 		reqmeth = ReqMethodDelete
 		alias = alias[6:]
+	case strings.HasPrefix(name, "PATCH"):
+		reqmeth = ReqMethodPatch
+		alias = alias[5:]
 	}
 
 	if alias == "" {
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/util/di"
+	"github.com/zaolab/sunnified/web"
+)
+
+type greeter struct{ name string }
+
+type injectCtrl struct {
+	Greeter *greeter `sunnified.inject:""`
+}
+
+func (c *injectCtrl) GETHello(g *greeter) mvc.View {
+	return view.NewJSONView(mvc.VM{"field": c.Greeter, "arg": g})
+}
+
+type unprovidedArgCtrl struct{}
+
+func (c *unprovidedArgCtrl) GETHello(g *greeter) mvc.VM { return mvc.VM{} }
+
+type unboundArgCtrl struct{}
+
+func (c *unboundArgCtrl) GETHello(count int) mvc.VM { return mvc.VM{} }
+
+type unprovidedFieldCtrl struct {
+	Greeter *greeter `sunnified.inject:""`
+}
+
+func (c *unprovidedFieldCtrl) GETHello() mvc.VM { return mvc.VM{} }
+
+func TestInject(t *testing.T) {
+	services := di.NewContainer()
+	services.Value(&greeter{"sunny"})
+	cm, _, _, _ := MakeControllerMeta(&injectCtrl{}, services)
+
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/hello", nil))
+	ctxt.Services = services.NewScope()
+	defer ctxt.Services.Close()
+
+	_, vw := NewControlManager(ctxt, cm, "hello").PrepareAndExecute()
+	jv, _ := vw.(view.JSONView)
+	field, _ := jv["field"].(*greeter)
+	arg, _ := jv["arg"].(*greeter)
+
+	if field == nil || field.name != "sunny" || arg != field {
+		t.Errorf("got %v", jv)
+	}
+}
+
+func TestInjectNotProvided(t *testing.T) {
+	notProvided := func(f func()) (err error) {
+		defer func() {
+			err, _ = recover().(error)
+		}()
+		f()
+		return
+	}
+
+	for _, c := range []struct {
+		name     string
+		ctrl     interface{}
+		services []*di.Container
+	}{
+		{"argument", &unprovidedArgCtrl{}, []*di.Container{di.NewContainer()}},
+		{"field", &unprovidedFieldCtrl{}, []*di.Container{di.NewContainer()}},
+		{"no container", &unprovidedArgCtrl{}, nil},
+		{"unbound argument", &unboundArgCtrl{}, []*di.Container{di.NewContainer()}},
+	} {
+		if err := notProvided(func() { MakeControllerMeta(c.ctrl, c.services...) }); !errors.Is(err, di.ErrNotProvided) {
+			t.Errorf("%s: got %v", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var typeActionInvoker = reflect.TypeOf((*ActionInvoker)(nil)).Elem()
+
+// ActionInvoker is implemented by the code WriteInvoker generates for a controller,
+// which calls its actions directly instead of by reflection.
+// InvokeAction_ returns false for a method it does not know of (e.g. one added after the code is generated),
+// which is then called by reflection.
+type ActionInvoker interface {
+	InvokeAction_(method string, args []reflect.Value) (results []reflect.Value, ok bool)
+}
+
+// actionInvoker calls an action with its receiver as the first of the args
+type actionInvoker func(args []reflect.Value) []reflect.Value
+
+// newActionInvoker returns the invoker of the method, which keeps the method found when the controller is added
+// instead of looking it up by name for every request; the method is still called by reflection,
+// unless the controller implements ActionInvoker (see WriteInvoker), whose InvokeAction_ calls it directly
+func newActionInvoker(rawtype reflect.Type, meth reflect.Method) actionInvoker {
+	fn := meth.Func
+
+	if !rawtype.Implements(typeActionInvoker) {
+		return fn.Call
+	}
+
+	return func(args []reflect.Value) []reflect.Value {
+		if results, ok := args[0].Interface().(ActionInvoker).InvokeAction_(meth.Name, args[1:]); ok {
+			return results
+		}
+		return fn.Call(args)
+	}
+}
+
+// bindArgs returns the receiver followed by the arguments of the action bound for the request;
+// the struct arguments of a typed action are decoded from the request body if it is not a form (e.g. JSON)
+func (am *ActionMeta) bindArgs(recv reflect.Value, vmap map[string]reflect.Value, n *bindNode, vd *Validation) []reflect.Value {
+	var (
+		args = make([]reflect.Value, len(am.args)+1)
+		body map[string]interface{}
+	)
+
+	args[0] = receiverOf(am.rmeth, recv)
+
+	if am.typed {
+		body = requestBodyData(vmap)
+	}
+
+	for i, arg := range am.args {
+		if body != nil && arg.T() == DatatypeStruct {
+			args[i+1] = decodeBody(arg, body, vd)
+		} else {
+			args[i+1] = getDataValue(&arg.DataMeta, vmap, n, vd)
+		}
+	}
+
+	return args
+}
+
+func (am *ActionMeta) invoke(args []reflect.Value) []reflect.Value {
+	if am.invoker == nil {
+		return am.rmeth.Func.Call(args)
+	}
+	return am.invoker(args)
+}
+
+// receiverOf returns recv as the receiver type of the method, i.e. dereferenced for a value receiver
+func receiverOf(meth reflect.Method, recv reflect.Value) reflect.Value {
+	if rtype := meth.Type.In(0); recv.Type() != rtype {
+		if recv.Kind() == reflect.Ptr {
+			return recv.Elem()
+		} else if recv.CanAddr() {
+			return recv.Addr()
+		}
+	}
+	return recv
+}
+
+// WriteInvoker writes the Go source of the InvokeAction_ methods of the controllers (which have to be of the package),
+// e.g. from a program run by go generate:
+//
+//	controller.WriteInvoker(file, "shop", &shop.Cart{}, &shop.Order{})
+//
+// Actions with arguments or results of types which cannot be named (e.g. an unnamed func type) are left out
+// and called by reflection. The code has to be generated again whenever an action changes.
+func WriteInvoker(w io.Writer, pkgname string, controllers ...interface{}) error {
+	var (
+		body    bytes.Buffer
+		imports = map[string]string{"reflect": "reflect"}
+	)
+
+	for _, cinterface := range controllers {
+		// the invokers do not resolve the services, so any type is taken as provided
+		cm, _, _, _ := makeControllerMeta(cinterface, func(reflect.Type) bool { return true })
+		rawtype := cm.RType()
+
+		if err := writeInvoker(&body, rawtype, cm.meths, imports); err != nil {
+			return err
+		}
+	}
+
+	paths := make([]string, 0, len(imports))
+	for path := range imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var src bytes.Buffer
+
+	fmt.Fprintf(&src, "// Code generated by controller.WriteInvoker; DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkgname)
+	for _, path := range paths {
+		fmt.Fprintf(&src, "\t%q\n", path)
+	}
+	fmt.Fprint(&src, ")\n\n")
+	body.WriteTo(&src)
+
+	out, err := format.Source(src.Bytes())
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(out)
+	return err
+}
+
+func writeInvoker(w *bytes.Buffer, rawtype reflect.Type, meths ActionMap, imports map[string]string) error {
+	var (
+		recv    = rawtype.Name()
+		pkgpath = rawtype.PkgPath()
+		names   []string
+		metas   = make(map[string]*ActionMeta)
+	)
+
+	if rawtype.Kind() == reflect.Ptr {
+		recv, pkgpath = "*"+rawtype.Elem().Name(), rawtype.Elem().PkgPath()
+	}
+
+	if recv == "" || recv == "*" {
+		return errors.New("controller: an invoker can only be generated for a named type")
+	}
+
+	for _, ameths := range meths {
+		for _, ameta := range ameths {
+			if _, exists := metas[ameta.name]; !exists {
+				metas[ameta.name] = ameta
+				names = append(names, ameta.name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "func (c %s) InvokeAction_(method string, args []reflect.Value) ([]reflect.Value, bool) {\n\tswitch method {\n", recv)
+
+	for _, name := range names {
+		var (
+			mtype    = metas[name].rmeth.Type
+			ins      = make([]string, 0, mtype.NumIn()-1)
+			outs     = make([]string, mtype.NumOut())
+			mimports = make(map[string]string)
+			ok       = !mtype.IsVariadic()
+		)
+
+		for i := 1; i < mtype.NumIn() && ok; i++ {
+			var t string
+			if t, ok = typeExpr(mtype.In(i), pkgpath, mimports); ok {
+				ins = append(ins, t)
+			}
+		}
+
+		// the packages are only imported if the action is written
+		if !ok || !mergeImports(imports, mimports) {
+			continue
+		}
+
+		fmt.Fprintf(w, "\tcase %q:\n", name)
+
+		args := make([]string, len(ins))
+		for i, t := range ins {
+			args[i] = fmt.Sprintf("a%d", i)
+			fmt.Fprintf(w, "\t\ta%d, _ := args[%d].Interface().(%s)\n", i, i, t)
+		}
+
+		results := make([]string, len(outs))
+		for i := range outs {
+			outs[i] = fmt.Sprintf("r%d", i)
+			// the results keep their declared types, e.g. a nil mvc.View stays a valid reflect.Value
+			results[i] = fmt.Sprintf("reflect.ValueOf(&r%d).Elem()", i)
+		}
+
+		fmt.Fprintf(w, "\t\t%s := c.%s(%s)\n", strings.Join(outs, ", "), name, strings.Join(args, ", "))
+		fmt.Fprintf(w, "\t\treturn []reflect.Value{%s}, true\n", strings.Join(results, ", "))
+	}
+
+	fmt.Fprint(w, "\t}\n\treturn nil, false\n}\n\n")
+
+	return nil
+}
+
+// typeExpr returns the Go expression of the type as written in the package of pkgpath,
+// adding the packages it needs to imports
+func typeExpr(t reflect.Type, pkgpath string, imports map[string]string) (string, bool) {
+	if name := t.Name(); name != "" {
+		path := t.PkgPath()
+
+		switch {
+		case strings.ContainsAny(name, "[]"):
+			// an instantiated generic type
+			return "", false
+		case path == "" || path == pkgpath:
+			return name, true
+		case !ast.IsExported(name):
+			// an unexported type of another package cannot be named by the generated code
+			return "", false
+		}
+
+		pkg := strings.TrimSuffix(t.String(), "."+name)
+		if !mergeImports(imports, map[string]string{path: pkg}) {
+			return "", false
+		}
+
+		return pkg + "." + name, true
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		elem, ok := typeExpr(t.Elem(), pkgpath, imports)
+		if !ok {
+			return "", false
+		}
+
+		switch t.Kind() {
+		case reflect.Ptr:
+			return "*" + elem, true
+		case reflect.Slice:
+			return "[]" + elem, true
+		}
+		return fmt.Sprintf("[%d]%s", t.Len(), elem), true
+	case reflect.Map:
+		key, ok := typeExpr(t.Key(), pkgpath, imports)
+		if !ok {
+			return "", false
+		}
+		elem, ok := typeExpr(t.Elem(), pkgpath, imports)
+		if !ok {
+			return "", false
+		}
+		return "map[" + key + "]" + elem, true
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return "interface{}", true
+		}
+	}
+
+	return "", false
+}
+
+// mergeImports adds the packages of src to dst, unless one of them has the same name as another package in dst
+func mergeImports(dst, src map[string]string) bool {
+	for path, pkg := range src {
+		for dpath, dpkg := range dst {
+			if dpkg == pkg && dpath != path {
+				return false
+			}
+		}
+	}
+
+	for path, pkg := range src {
+		dst[path] = pkg
+	}
+
+	return true
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/web"
+)
+
+type benchQuery struct {
+	Form_ID   int
+	Form_Name string
+}
+
+type benchCtrl struct{}
+
+func (c *benchCtrl) Item(ctxt *web.Context, pd web.PData, q benchQuery) mvc.View {
+	return view.NewJSONView(mvc.VM{"id": q.Form_ID, "name": q.Form_Name})
+}
+
+// benchGenCtrl is benchCtrl with the code WriteInvoker generates for it
+type benchGenCtrl struct {
+	benchCtrl
+}
+
+func (c *benchGenCtrl) InvokeAction_(method string, args []reflect.Value) ([]reflect.Value, bool) {
+	switch method {
+	case "Item":
+		a0, _ := args[0].Interface().(*web.Context)
+		a1, _ := args[1].Interface().(web.PData)
+		a2, _ := args[2].Interface().(benchQuery)
+		r0 := c.Item(a0, a1, a2)
+		return []reflect.Value{reflect.ValueOf(&r0).Elem()}, true
+	}
+	return nil, false
+}
+
+func newBenchManager(cm *Meta) *ControlManager {
+	r := httptest.NewRequest("GET", "/item?id=12&name=pen", nil)
+	ctxt := web.NewContext(httptest.NewRecorder(), r)
+	ctxt.ParseRequestData()
+	mgr := NewControlManager(ctxt, cm, "item")
+	mgr.SetBindSource(BindQuery)
+	return mgr
+}
+
+func TestWriteInvoker(t *testing.T) {
+	var buf bytes.Buffer
+
+	if err := WriteInvoker(&buf, "controller", &benchCtrl{}); err != nil {
+		t.Fatal(err)
+	}
+
+	src := buf.String()
+
+	for _, want := range []string{
+		`"github.com/zaolab/sunnified/web"`,
+		`func (c *benchCtrl) InvokeAction_(method string, args []reflect.Value) ([]reflect.Value, bool) {`,
+		`case "Item":`,
+		`a2, _ := args[2].Interface().(benchQuery)`,
+		`r0 := c.Item(a0, a1, a2)`,
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated code does not contain %s:\n%s", want, src)
+		}
+	}
+}
+
+func TestTypeExpr(t *testing.T) {
+	const pkgpath = "github.com/zaolab/sunnified/mvc/controller"
+
+	for _, c := range []struct {
+		value interface{}
+		expr  string
+		ok    bool
+	}{
+		{benchQuery{}, "benchQuery", true},
+		{&web.Context{}, "*web.Context", true},
+		{[]string{}, "[]string", true},
+		// *errors.errorString cannot be named outside of errors
+		{errors.New("x"), "", false},
+	} {
+		imports := make(map[string]string)
+		if expr, ok := typeExpr(reflect.TypeOf(c.value), pkgpath, imports); expr != c.expr || ok != c.ok {
+			t.Errorf("%T: got %q %v", c.value, expr, ok)
+		}
+	}
+}
+
+func TestGeneratedInvoker(t *testing.T) {
+	for _, ctrl := range []interface{}{&benchCtrl{}, &benchGenCtrl{}} {
+		cm, _, _, _ := MakeControllerMeta(ctrl)
+		mgr := newBenchManager(cm)
+
+		state, vw := mgr.PrepareAndExecute()
+		jv, _ := vw.(view.JSONView)
+
+		if state != 200 || jv["id"] != 12 || jv["name"] != "pen" {
+			t.Errorf("%T: unexpected result %d %v", ctrl, state, vw)
+		}
+	}
+}
+
+// BenchmarkInvokeMethodByName is how actions were called before their methods were kept in the ActionMeta
+func BenchmarkInvokeMethodByName(b *testing.B) {
+	cm, _, _, _ := MakeControllerMeta(&benchCtrl{})
+	mgr := newBenchManager(cm)
+	ameta := mgr.ActionMeta()
+	args := ameta.bindArgs(mgr.control, mgr.getVMap(), mgr.bindTree(), mgr.validation)[1:]
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		mgr.control.MethodByName(ameta.Name()).Call(args)
+	}
+}
+
+func BenchmarkInvokeReflect(b *testing.B) {
+	benchmarkInvoke(b, &benchCtrl{})
+}
+
+func BenchmarkInvokeGenerated(b *testing.B) {
+	benchmarkInvoke(b, &benchGenCtrl{})
+}
+
+func benchmarkInvoke(b *testing.B, ctrl interface{}) {
+	cm, _, _, _ := MakeControllerMeta(ctrl)
+	mgr := newBenchManager(cm)
+	ameta := mgr.ActionMeta()
+	args := ameta.bindArgs(mgr.control, mgr.getVMap(), mgr.bindTree(), mgr.validation)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ameta.invoke(args)
+	}
+}
+
+func BenchmarkExecuteReflect(b *testing.B) {
+	benchmarkExecute(b, &benchCtrl{})
+}
+
+func BenchmarkExecuteGenerated(b *testing.B) {
+	benchmarkExecute(b, &benchGenCtrl{})
+}
+
+func benchmarkExecute(b *testing.B, ctrl interface{}) {
+	cm, _, _, _ := MakeControllerMeta(ctrl)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		newBenchManager(cm).PrepareAndExecute()
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/zaolab/sunnified/mvc"
+)
+
+// TmplProblem is a template of an action which is missing or cannot be parsed
+type TmplProblem struct {
+	Module     string
+	Controller string
+	Action     string
+	Ext        string
+	Path       string
+	Err        error
+}
+
+func (tp TmplProblem) Error() string {
+	return fmt.Sprintf("%s.%s.%s (%s): %v", tp.Module, tp.Controller, tp.Action, tp.Path, tp.Err)
+}
+
+func (tp TmplProblem) Unwrap() error {
+	return tp.Err
+}
+
+// CheckTemplates parses the template of every action of the group rendered through a template
+// (i.e. returning mvc.VM or map[string]interface{}) for each of the extensions (.html if none),
+// with the themes (mvc.DefaultThemes if nil) and the layout the controller chooses (see mvc.Layouter);
+// the problems are of the templates which are missing (mvc.ErrTmplNotExist) or cannot be parsed.
+// It is meant to be called before the server starts or from a test, e.g.
+//
+//	if problems := group.CheckTemplates(themes, ".html", ".json"); len(problems) > 0 { ... }
+func (cg *Group) CheckTemplates(th *mvc.Themes, exts ...string) (problems []TmplProblem) {
+	if th == nil {
+		th = mvc.DefaultThemes
+	}
+	if len(exts) == 0 {
+		exts = []string{".html"}
+	}
+
+	for _, mod := range cg.Modules() {
+		ctrls := cg.Module(mod)
+		names := make([]string, 0, len(ctrls))
+
+		for name := range ctrls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			problems = append(problems, checkControllerTemplates(th, ctrls[name], exts)...)
+		}
+	}
+
+	return
+}
+
+func checkControllerTemplates(th *mvc.Themes, cm *Meta, exts []string) (problems []TmplProblem) {
+	var (
+		layouter mvc.Layouter
+		actions  = make(map[string]bool)
+	)
+
+	if cm.t == ContypeFunc {
+		actions["_"] = cm.Vmap() || cm.MapSI()
+	} else {
+		for action, meths := range cm.meths {
+			for _, am := range meths {
+				actions[action] = actions[action] || am.Vmap() || am.MapSI()
+			}
+		}
+
+		rtype := cm.rtype
+		if rtype.Kind() == reflect.Ptr {
+			rtype = rtype.Elem()
+		}
+		layouter, _ = reflect.New(rtype).Interface().(mvc.Layouter)
+	}
+
+	names := make([]string, 0, len(actions))
+	for action, tmpl := range actions {
+		if tmpl {
+			names = append(names, action)
+		}
+	}
+	sort.Strings(names)
+
+	for _, action := range names {
+		var layout string
+		if layouter != nil {
+			layout = layouter.Layout_(action)
+		}
+
+		for _, ext := range exts {
+			meta := mvc.Meta{mvc.MVCModule: cm.modname, mvc.MVCController: cm.name, mvc.MVCAction: action}
+
+			if p, err := th.CheckTmpl(meta, ext, layout); err != nil {
+				problems = append(problems, TmplProblem{
+					Module:     cm.modname,
+					Controller: cm.name,
+					Action:     action,
+					Ext:        ext,
+					Path:       p,
+					Err:        err,
+				})
+			}
+		}
+	}
+
+	return
+}
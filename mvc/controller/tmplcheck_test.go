@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+)
+
+type tmplCtrl struct{}
+
+func (c *tmplCtrl) GETList() mvc.VM {
+	return mvc.VM{}
+}
+
+func (c *tmplCtrl) GETShow() map[string]interface{} {
+	return nil
+}
+
+func (c *tmplCtrl) GETItem() typedItem {
+	return typedItem{}
+}
+
+func TestCheckTemplates(t *testing.T) {
+	cg := NewControllerGroup()
+	mod, ctrl := cg.AddController(&tmplCtrl{})
+
+	root := t.TempDir()
+	for p, content := range map[string]string{
+		"list.html": `list`,
+		"show.html": `{{if}}`,
+	} {
+		fp := filepath.Join(root, "default", "tmpl", mod, ctrl, p)
+		if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problems := cg.CheckTemplates(mvc.NewThemes(root, "default"), ".html", ".json")
+	if len(problems) != 3 {
+		t.Fatalf("problems: %v", problems)
+	}
+
+	// sorted by action, then in the order of the extensions; the typed action has no template
+	for i, want := range []struct {
+		action, ext string
+		missing     bool
+	}{
+		{"list", ".json", true},
+		{"show", ".html", false},
+		{"show", ".json", true},
+	} {
+		p := problems[i]
+		if p.Action != want.action || p.Ext != want.ext || errors.Is(p, mvc.ErrTmplNotExist) != want.missing {
+			t.Errorf("problem %d: %v", i, p)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package controller
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/util/validate"
+	"github.com/zaolab/sunnified/web"
+)
+
+// errorReply is the body of the reply to a typed action returning an error
+type errorReply struct {
+	XMLName xml.Name `json:"-" xml:"error"`
+	Status  int      `json:"status" xml:"status,attr"`
+	Message string   `json:"error" xml:",chardata"`
+}
+
+// takesValidation returns whether the action takes the *Validation as an argument,
+// otherwise a typed action (which has no view to show the errors in) is replied 422 regardless of the validation mode
+func (am *ActionMeta) takesValidation() bool {
+	for _, arg := range am.args {
+		if arg.T() == DatatypeValidation {
+			return true
+		}
+	}
+	return false
+}
+
+// requestBodyData returns the request body decoded by its content type (e.g. JSON, or one with a web.ContentParser),
+// nil if the body is not one of those (e.g. a form)
+func requestBodyData(vmap map[string]reflect.Value) map[string]interface{} {
+	if ctxt, ok := vmap["context"].Interface().(*web.Context); ok && ctxt != nil && ctxt.Request != nil {
+		return ctxt.RequestBodyData("")
+	}
+	return nil
+}
+
+// decodeBody decodes the request body data into a struct argument of a typed action the way encoding/json does
+// (i.e. by the json tags of its fields), and checks the data against the validate (and regexp) tags of the fields
+func decodeBody(arg *ArgMeta, data map[string]interface{}, vd *Validation) reflect.Value {
+	value := reflect.New(arg.RType())
+
+	b, err := json.Marshal(data)
+	if err == nil {
+		err = json.Unmarshal(b, value.Interface())
+	}
+
+	if err != nil {
+		if terr, ok := err.(*json.UnmarshalTypeError); ok && terr.Field != "" {
+			vd.Add(terr.Field, ValidationCodeType, terr.Field+" is not a valid value")
+		} else {
+			vd.Add(arg.LName(), ValidationCodeType, arg.LName()+" is not a valid value")
+		}
+		return reflect.Zero(arg.RType())
+	}
+
+	validateData(arg.RType(), data, "", vd)
+
+	return value.Elem()
+}
+
+// validateData checks the data a struct is decoded from against the validate (and regexp) tags of its fields,
+// so that e.g. required is about whether the value is given at all and not whether it is a zero value
+func validateData(t reflect.Type, data map[string]interface{}, prefix string, vd *Validation) {
+	for i, numfield := 0, t.NumField(); i < numfield; i++ {
+		var (
+			field     = t.Field(i)
+			name, opt = jsonName(field)
+			ftype     = field.Type
+		)
+
+		if !opt {
+			continue
+		}
+
+		if ftype.Kind() == reflect.Ptr {
+			ftype = ftype.Elem()
+		}
+
+		if field.Anonymous && name == "" && ftype.Kind() == reflect.Struct {
+			// the fields of an embedded struct are decoded at the same level
+			validateData(ftype, data, prefix, vd)
+			continue
+		} else if field.PkgPath != "" {
+			continue
+		} else if name == "" {
+			name = field.Name
+		}
+
+		var (
+			key        = prefix + name
+			raw, given = lookupFold(data, name)
+			rules, _   = validate.FieldRules(t, field)
+			errs       []validate.RuleError
+		)
+
+		switch kind := ftype.Kind(); {
+		case kind == reflect.Struct && ftype != typeTimeTime.Elem():
+			if sub, ok := raw.(map[string]interface{}); ok {
+				validateData(ftype, sub, key+".", vd)
+			}
+			fallthrough
+		case kind == reflect.Slice, kind == reflect.Map:
+			// only the presence of a struct, slice or map can be checked
+			if rules.Has("required") && (!given || raw == nil) {
+				errs = rules.Validate("", kind, nil)
+			}
+		case len(rules) > 0:
+			errs = rules.Validate(dataString(raw), kind, func(name string) string {
+				v, _ := lookupFold(data, name)
+				return dataString(v)
+			})
+		}
+
+		for _, err := range errs {
+			vd.Add(key, err.Rule, key+" "+err.Message)
+		}
+	}
+}
+
+// jsonName returns the name of the field in JSON, which is empty if the json tag does not name it,
+// and false if the field is left out of JSON
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	if comma := strings.Index(tag, ","); comma >= 0 {
+		tag = tag[:comma]
+	}
+
+	return tag, true
+}
+
+// lookupFold returns the value of the key, which is matched case-insensitively (as encoding/json does) if there is no exact match
+func lookupFold(data map[string]interface{}, key string) (interface{}, bool) {
+	if v, exists := data[key]; exists {
+		return v, true
+	}
+
+	for k, v := range data {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return nil, false
+}
+
+func dataString(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// typedResult returns the view encoding the result of a typed action in the media type the request accepts,
+// with the status of the action (if it returns one); a request that accepts none of the encoders is in the state of 406
+func (c *ControlManager) typedResult(result reflect.Value) mvc.View {
+	mediatype := view.NegotiateEncoder(c.context)
+	if mediatype == "" {
+		c.state = http.StatusNotAcceptable
+		return nil
+	}
+
+	var value interface{}
+	if result.IsValid() {
+		value = result.Interface()
+	}
+
+	ev := view.NewEncodedView(value, mediatype)
+	ev.Status, c.state = c.state, http.StatusOK
+
+	return ev
+}
+
+// actionError sets the state to the status of the error returned by an action,
+// which is the Code of a web.ContextError and 500 for any other error.
+// A typed action replies the error in the media type the request accepts,
+// with the message of a web.ContextError (the message of any other error is logged instead).
+func (c *ControlManager) actionError(rstyle ResultStyle, err error) (vw mvc.View, handled bool) {
+	cerr, iscerr := err.(web.ContextError)
+
+	if iscerr {
+		c.state = cerr.Code()
+	} else {
+		c.state = http.StatusInternalServerError
+		log.Println(err)
+	}
+
+	if !rstyle.Typed() {
+		return nil, false
+	}
+
+	mediatype := view.NegotiateEncoder(c.context)
+	if mediatype == "" {
+		return nil, false
+	}
+
+	reply := errorReply{Status: c.state, Message: http.StatusText(c.state)}
+	if iscerr {
+		reply.Message = cerr.Error()
+	}
+
+	ev := view.NewEncodedView(reply, mediatype)
+	ev.Status = c.state
+
+	if perr := ev.Publish(c.context); perr != nil {
+		return nil, false
+	}
+
+	return nil, true
+}
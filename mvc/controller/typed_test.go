@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+type typedAddress struct {
+	City string `json:"city" validate:"required"`
+}
+
+type typedItemReq struct {
+	Name    string       `json:"name" validate:"required,max=10"`
+	Qty     int          `json:"qty" validate:"min=1"`
+	Address typedAddress `json:"address"`
+}
+
+type typedItem struct {
+	ID   int    `json:"id" xml:"id,attr"`
+	Name string `json:"name" xml:"name"`
+}
+
+type typedNotFound struct{}
+
+func (typedNotFound) Error() string { return "item not found" }
+func (typedNotFound) Code() int     { return http.StatusNotFound }
+
+type typedCtrl struct{}
+
+func (c *typedCtrl) POSTCreate(req typedItemReq) (typedItem, web.StatusCode) {
+	return typedItem{ID: req.Qty, Name: req.Name + "@" + req.Address.City}, http.StatusCreated
+}
+
+func (c *typedCtrl) GETShow(pd web.PData) (*typedItem, error) {
+	if pd["id"] != "1" {
+		return nil, typedNotFound{}
+	}
+	return &typedItem{ID: 1, Name: "pen"}, nil
+}
+
+// GETDraft leaves the status unset
+func (c *typedCtrl) GETDraft() (typedItem, web.StatusCode) {
+	return typedItem{ID: 2, Name: "draft"}, 0
+}
+
+func serveTyped(r *http.Request, action string) *httptest.ResponseRecorder {
+	cm, _, _, _ := MakeControllerMeta(&typedCtrl{})
+	w := httptest.NewRecorder()
+	ctxt := web.NewContext(w, r)
+	ctxt.PData = web.PData{"id": r.URL.Query().Get("id")}
+	ctxt.ParseRequestData()
+	ctxt.WaitRequestData()
+
+	ctrlmgr := NewControlManager(ctxt, cm, action)
+	ctrlmgr.Prepare()
+
+	if state, vw := ctrlmgr.Execute(); state != -1 && vw == nil {
+		w.WriteHeader(state)
+	} else {
+		ctrlmgr.PublishView()
+	}
+
+	return w
+}
+
+func TestTypedActionJSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/item", strings.NewReader(`{"name":"pen","qty":3,"address":{"city":"sg"}}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := serveTyped(r, "create")
+	if w.Code != http.StatusCreated || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("got %d %s: %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	var item typedItem
+	if err := json.Unmarshal(w.Body.Bytes(), &item); err != nil || item.ID != 3 || item.Name != "pen@sg" {
+		t.Errorf("got %+v %v", item, err)
+	}
+}
+
+func TestTypedActionValidation(t *testing.T) {
+	r := httptest.NewRequest("POST", "/item", strings.NewReader(`{"name":"a pen too long","qty":0,"address":{}}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	w := serveTyped(r, "create")
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	var reply struct {
+		Errors map[string][]ValidationError `json:"errors"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &reply)
+
+	for _, field := range []string{"name", "qty", "address.city"} {
+		if len(reply.Errors[field]) == 0 {
+			t.Errorf("no error of %s: %s", field, w.Body.String())
+		}
+	}
+}
+
+func TestTypedActionAcceptAndError(t *testing.T) {
+	r := httptest.NewRequest("GET", "/item?id=1", nil)
+	r.Header.Set("Accept", "application/xml")
+
+	w := serveTyped(r, "show")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<typedItem id="1"><name>pen</name></typedItem>`) {
+		t.Errorf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/item?id=2", nil)
+	w = serveTyped(r, "show")
+	if w.Code != http.StatusNotFound || strings.TrimSpace(w.Body.String()) != `{"status":404,"error":"item not found"}` {
+		t.Errorf("got %d: %s", w.Code, w.Body.String())
+	}
+
+	r = httptest.NewRequest("GET", "/item?id=1", nil)
+	r.Header.Set("Accept", "image/png")
+	if w = serveTyped(r, "show"); w.Code != http.StatusNotAcceptable {
+		t.Errorf("got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestTypedActionZeroStatus(t *testing.T) {
+	w := serveTyped(httptest.NewRequest("GET", "/item/draft", nil), "draft")
+
+	var item typedItem
+	if err := json.Unmarshal(w.Body.Bytes(), &item); w.Code != http.StatusOK || err != nil || item.Name != "draft" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+}
+
+type typedSecret struct {
+	Key string
+}
+
+type typedHelperCtrl struct{}
+
+func (c *typedHelperCtrl) GETList() []typedItem { return nil }
+
+func (c *typedHelperCtrl) LoadSecret() typedSecret { return typedSecret{"key"} }
+
+func (c *typedHelperCtrl) Names() []string { return nil }
+
+func (c *typedHelperCtrl) Lookup() (*typedItem, error) { return nil, nil }
+
+func TestTypedActionOptIn(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&typedHelperCtrl{})
+
+	if !cm.HasAction("list") {
+		t.Error("list is not an action")
+	}
+	for _, name := range []string{"loadsecret", "names", "lookup"} {
+		if cm.HasAction(name) {
+			t.Errorf("%s is routed", name)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/util/av"
+	"github.com/zaolab/sunnified/web"
+)
+
+type countingScanner struct {
+	av.Scanner
+	scans int
+}
+
+func (s *countingScanner) ScanStream(r io.Reader) (av.Result, error) {
+	s.scans++
+	return av.Result{Status: true}, nil
+}
+
+type uploadCtrl struct {
+	Avatar *web.UploadedFile `upload.ext:".png"`
+}
+
+func (c *uploadCtrl) POSTSave(file *web.UploadedFile, vd *Validation) mvc.View {
+	return view.NewJSONView(mvc.VM{"field": c.Avatar, "arg": file, "errors": vd.Map()})
+}
+
+func uploadRequest(t *testing.T, filename string) *web.Context {
+	var (
+		body bytes.Buffer
+		mw   = multipart.NewWriter(&body)
+	)
+
+	w, _ := mw.CreateFormFile("avatar", filename)
+	io.WriteString(w, "content")
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/save", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	return web.NewContext(httptest.NewRecorder(), r)
+}
+
+func TestUploadBoundTwice(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&uploadCtrl{})
+
+	for _, c := range []struct {
+		filename string
+		field    bool
+		errors   int
+	}{
+		{"me.PNG", true, 0},
+		{"me.gif", false, 1},
+	} {
+		scanner := &countingScanner{}
+		ctxt := uploadRequest(t, c.filename)
+		ctxt.FileScanner = scanner
+
+		_, vw := NewControlManager(ctxt, cm, "save").PrepareAndExecute()
+		jv, _ := vw.(view.JSONView)
+		field, _ := jv["field"].(*web.UploadedFile)
+		arg, _ := jv["arg"].(*web.UploadedFile)
+		errs, _ := jv["errors"].(map[string][]ValidationError)
+
+		// the argument takes any file, the field only those passing its rules
+		if arg == nil || (field != nil) != c.field || (field != nil && field != arg) ||
+			scanner.scans != 1 || len(errs["avatar"]) != c.errors {
+			t.Errorf("%s: field %v, arg %v, %d scans, errors %v", c.filename, field, arg, scanner.scans, errs)
+		}
+	}
+}
+
+type uploadValueCtrl struct {
+	Form_Avatar web.UploadedFile
+}
+
+func (c *uploadValueCtrl) POSTSave() mvc.VM { return mvc.VM{} }
+
+func TestUploadValueType(t *testing.T) {
+	defer func() {
+		if err, _ := recover().(string); !strings.Contains(err, "*web.UploadedFile") {
+			t.Errorf("got %v", err)
+		}
+	}()
+
+	MakeControllerMeta(&uploadValueCtrl{})
+}
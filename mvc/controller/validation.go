@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/zaolab/sunnified/util/validate"
+)
+
+// the codes of the errors found by the binding itself,
+// errors of the validate tag use the name of the rule as the code (e.g. required, min, pattern)
+const (
+	ValidationCodeType  = validate.RuleType
+	ValidationCodeEmail = "email"
+	ValidationCodeURL   = "url"
+)
+
+// ValidationMode decides what happens to a request whose data fails validation during binding
+type ValidationMode int
+
+const (
+	// ValidationIgnore executes the action as usual, leaving the errors to the action (and the view)
+	ValidationIgnore ValidationMode = iota
+	// ValidationReplyAJAX replies AJAX requests with 422 and a JSON map of the errors
+	// without executing the action; other requests are executed as usual
+	ValidationReplyAJAX
+	// ValidationReply replies all requests with 422 and a JSON map of the errors
+	ValidationReply
+)
+
+// DefaultValidationMode is the mode used by new control managers
+var DefaultValidationMode = ValidationIgnore
+
+type ValidationError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (ve ValidationError) Error() string {
+	return ve.Message
+}
+
+func NewValidation() *Validation {
+	return &Validation{
+		errors: make(map[string][]ValidationError),
+	}
+}
+
+// Validation holds the errors found while binding the request data to the action arguments
+// and controller fields; it can be taken as an argument or field of type *controller.Validation
+// and is available to data views as .Validation
+type Validation struct {
+	errors map[string][]ValidationError
+}
+
+// Add records an error of the field, an error that is already recorded
+// (e.g. the same value bound to both a field and an argument) is not added again
+func (v *Validation) Add(field, code, message string) {
+	verr := ValidationError{Code: code, Message: message}
+
+	for _, err := range v.errors[field] {
+		if err == verr {
+			return
+		}
+	}
+
+	v.errors[field] = append(v.errors[field], verr)
+}
+
+func (v *Validation) HasErrors() bool {
+	return len(v.errors) > 0
+}
+
+func (v *Validation) Has(field string) bool {
+	return len(v.errors[field]) > 0
+}
+
+func (v *Validation) Errors(field string) []ValidationError {
+	out := make([]ValidationError, len(v.errors[field]))
+	copy(out, v.errors[field])
+	return out
+}
+
+// Error returns the message of the first error of the field, for use in templates
+func (v *Validation) Error(field string) string {
+	if errs := v.errors[field]; len(errs) > 0 {
+		return errs[0].Message
+	}
+	return ""
+}
+
+func (v *Validation) Fields() []string {
+	fields := make([]string, 0, len(v.errors))
+	for field := range v.errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func (v *Validation) Map() map[string][]ValidationError {
+	m := make(map[string][]ValidationError, len(v.errors))
+	for field := range v.errors {
+		m[field] = v.Errors(field)
+	}
+	return m
+}
+
+func (v *Validation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.errors)
+}
+
+func (v *Validation) String() string {
+	var msgs []string
+	for _, field := range v.Fields() {
+		for _, err := range v.errors[field] {
+			msgs = append(msgs, field+": "+err.Message)
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (c *ControlManager) Validation() *Validation {
+	return c.validation
+}
+
+func (c *ControlManager) SetValidationMode(mode ValidationMode) {
+	c.vmode = mode
+}
+
+func (c *ControlManager) ValidationMode() ValidationMode {
+	return c.vmode
+}
+
+// replyValidation sends the 422 reply if the validation mode requires it (or always is true),
+// returning true if the reply has been sent
+func (c *ControlManager) replyValidation(always bool) bool {
+	if !c.validation.HasErrors() ||
+		(!always && (c.vmode == ValidationIgnore ||
+			(c.vmode == ValidationReplyAJAX && !c.context.IsAJAX()))) {
+		return false
+	}
+
+	bb, err := json.Marshal(map[string]interface{}{"errors": c.validation})
+	if err != nil {
+		return false
+	}
+
+	c.context.SetHeader("Content-Type", "application/json; charset=utf-8")
+	c.context.Response.WriteHeader(http.StatusUnprocessableEntity)
+	c.context.Response.Write(bb)
+
+	return true
+}
@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/util/validate"
+	"github.com/zaolab/sunnified/web"
+)
+
+func TestValidation(t *testing.T) {
+	vd := NewValidation()
+
+	if vd.HasErrors() || vd.Has("age") || vd.Error("age") != "" {
+		t.Fatal("new validation has errors")
+	}
+
+	vd.Add("email", ValidationCodeEmail, "email is not a valid email address")
+	vd.Add("age", ValidationCodeType, "age is not a valid value")
+	vd.Add("age", "min", "age must be at least 18")
+	vd.Add("age", ValidationCodeType, "age is not a valid value")
+
+	if !vd.HasErrors() || !vd.Has("age") || vd.Has("name") {
+		t.Errorf("has: %v", vd.Map())
+	}
+	if fields := vd.Fields(); len(fields) != 2 || fields[0] != "age" || fields[1] != "email" {
+		t.Errorf("fields: %v", fields)
+	}
+	if errs := vd.Errors("age"); len(errs) != 2 || errs[0].Code != ValidationCodeType || errs[1].Code != "min" {
+		t.Errorf("errors of age: %v", errs)
+	}
+	if vd.Error("age") != "age is not a valid value" {
+		t.Errorf("error of age: %s", vd.Error("age"))
+	}
+	if got := vd.String(); got != "age: age is not a valid value; age: age must be at least 18; "+
+		"email: email is not a valid email address" {
+		t.Errorf("string: %s", got)
+	}
+
+	bb, _ := json.Marshal(vd)
+	if string(bb) != `{"age":[{"code":"type","message":"age is not a valid value"},`+
+		`{"code":"min","message":"age must be at least 18"}],`+
+		`"email":[{"code":"email","message":"email is not a valid email address"}]}` {
+		t.Errorf("json: %s", bb)
+	}
+
+	// Map returns a copy
+	vd.Map()["age"][0].Code = "changed"
+	if vd.Errors("age")[0].Code != ValidationCodeType {
+		t.Error("map is not a copy")
+	}
+}
+
+type signupCtrl struct {
+	Form_Age   int
+	Form_Email string `value.type:"email"`
+}
+
+func (c *signupCtrl) POSTSave(vd *Validation) mvc.View {
+	return view.NewJSONView(mvc.VM{"age": c.Form_Age, "email": c.Form_Email, "errors": vd.Fields()})
+}
+
+func TestValidationReply(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&signupCtrl{})
+
+	for _, c := range []struct {
+		name   string
+		mode   ValidationMode
+		src    BindSource
+		target string
+		body   string
+		ajax   bool
+		state  int
+		errors string
+	}{
+		{"ignored", ValidationIgnore, BindAll, "/save", "age=abc&email=x", true, 200, "age email"},
+		{"not ajax", ValidationReplyAJAX, BindAll, "/save", "age=abc&email=x", false, 200, "age email"},
+		{"ajax", ValidationReplyAJAX, BindAll, "/save", "age=abc&email=x", true, 422, "age email"},
+		{"reply", ValidationReply, BindAll, "/save", "age=abc", false, 422, "age"},
+		{"valid", ValidationReply, BindAll, "/save", "age=20&email=a@b.com", false, 200, ""},
+		{"query only", ValidationReply, BindBody, "/save?age=abc", "email=a@b.com", false, 200, ""},
+		{"path only", ValidationReply, BindPath, "/save?age=abc", "email=x", false, 200, ""},
+		{"query", ValidationReply, BindQuery, "/save?age=abc", "email=x", false, 422, "age"},
+	} {
+		r := httptest.NewRequest("POST", c.target, strings.NewReader(c.body))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if c.ajax {
+			r.Header.Set(web.HTTPXRequestedWith, "XMLHttpRequest")
+		}
+
+		w := httptest.NewRecorder()
+		ctxt := web.NewContext(w, r)
+		ctxt.ParseRequestData()
+		ctxt.WaitRequestData()
+
+		mgr := NewControlManager(ctxt, cm, "save")
+		mgr.SetValidationMode(c.mode)
+		mgr.SetBindSource(c.src)
+
+		state, vw := mgr.PrepareAndExecute()
+
+		if state == -1 {
+			var reply map[string]map[string][]ValidationError
+			json.Unmarshal(w.Body.Bytes(), &reply)
+
+			var fields []string
+			for field := range reply["errors"] {
+				fields = append(fields, field)
+			}
+
+			if w.Code != c.state || len(fields) != len(strings.Fields(c.errors)) ||
+				w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Errorf("%s: got %d %s", c.name, w.Code, w.Body.String())
+			}
+			continue
+		}
+
+		jv, _ := vw.(view.JSONView)
+		if state != c.state || jv == nil || strings.Join(jv["errors"].([]string), " ") != c.errors ||
+			(c.errors == "" && c.src&BindBody != 0 && jv["email"] != "a@b.com") {
+			t.Errorf("%s: got %d %v", c.name, state, vw)
+		}
+	}
+}
+
+type adultCtrl struct {
+	Form_Age int `validate:"required,min=18"`
+}
+
+func (c *adultCtrl) Check(vd *Validation) mvc.View {
+	return view.NewJSONView(mvc.VM{"age": c.Form_Age, "errors": vd.Map()})
+}
+
+func TestValidateTag(t *testing.T) {
+	cm, _, _, _ := MakeControllerMeta(&adultCtrl{})
+
+	for _, c := range []struct {
+		age  string
+		code string
+	}{
+		{"abc", ValidationCodeType},
+		{"300000000000000000000", ValidationCodeType},
+		{"17", "min"},
+		{"", "required"},
+		{"18", ""},
+	} {
+		ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/check", nil))
+		ctxt.PData = web.PData{"age": c.age}
+
+		_, vw := NewControlManager(ctxt, cm, "check").PrepareAndExecute()
+		jv, _ := vw.(view.JSONView)
+		errs, _ := jv["errors"].(map[string][]ValidationError)
+
+		if c.code == "" && len(errs) != 0 || c.code != "" && (len(errs["age"]) != 1 || errs["age"][0].Code != c.code) {
+			t.Errorf("%q: got %v", c.age, jv)
+		}
+	}
+}
+
+type badTagCtrl struct {
+	Form_Age int `validate:"min=abc"`
+}
+
+func (c *badTagCtrl) Check() mvc.VM { return nil }
+
+type badTagForm struct {
+	Age int `validate:"min=abc"`
+}
+
+func TestInvalidValidateTag(t *testing.T) {
+	tagError := func(f func()) (err error) {
+		defer func() {
+			err, _ = recover().(error)
+		}()
+		f()
+		return
+	}
+
+	cerr := tagError(func() { MakeControllerMeta(&badTagCtrl{}) })
+	verr := validate.CheckStruct(reflect.TypeOf(badTagForm{}))
+
+	var cterr, vterr *validate.TagError
+	if !errors.As(cerr, &cterr) || !errors.As(verr, &vterr) || cterr.Err != vterr.Err ||
+		cterr.Struct != "badTagCtrl" || !errors.Is(cerr, validate.ErrRuleParam) {
+		t.Errorf("got %v and %v", cerr, verr)
+	}
+}
@@ -0,0 +1,258 @@
+package mvc
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sync"
+	txtemplate "text/template"
+	"text/template/parse"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+var (
+	engines = map[string]ViewEngine{
+		".html": HTMLEngine{},
+		".txt":  TextEngine{},
+		".xml":  XMLEngine{Type: "application/xml; charset=utf-8"},
+		".md":   MarkdownEngine{},
+	}
+	emutex = sync.RWMutex{}
+)
+
+// ViewTmpl is a parsed template of a view engine
+type ViewTmpl interface {
+	Execute(w io.Writer, data interface{}) error
+}
+
+// ViewEngine parses the templates of the extensions it is registered for, see SetViewEngine
+type ViewEngine interface {
+	// Parse returns the template of the path parsed with the partials matching the patterns,
+	// read from fsys (the OS filesystem if it is nil), with the funcs of the view
+	Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (ViewTmpl, error)
+	// ContentType returns the content type of the pages of the extension,
+	// empty for the one of the extension (see view.GetContentType)
+	ContentType(ext string) string
+}
+
+// SetViewEngine sets the engine of the templates of the extension, e.g. ".md";
+// a nil engine removes it, the templates of an extension without an engine are html templates
+func SetViewEngine(ext string, engine ViewEngine) {
+	emutex.Lock()
+	defer emutex.Unlock()
+
+	if engine == nil {
+		delete(engines, ext)
+	} else {
+		engines[ext] = engine
+	}
+}
+
+// GetViewEngine returns the engine of the templates of the extension, HTMLEngine if it has none
+func GetViewEngine(ext string) ViewEngine {
+	emutex.RLock()
+	defer emutex.RUnlock()
+
+	if engine, exists := engines[ext]; exists {
+		return engine
+	}
+	return HTMLEngine{}
+}
+
+// GetViewTmpl returns the template of the action in the theme of the request parsed by the engine of the extension;
+// an html template is rendered through the layout (see GetLayoutHTMLTmpl), which other engines do not have
+func GetViewTmpl(ctxt *web.Context, names Meta, ext, layout string, fmap template.FuncMap) (t ViewTmpl, err error) {
+	engine := GetViewEngine(ext)
+
+	th := GetThemes(ctxt)
+	p, shares := th.Find(ctxt, names, ext)
+
+	if !fileExists(th.FS, p) {
+		return nil, ErrTmplNotExist
+	}
+
+	if isHTMLEngine(engine) {
+		var ht *template.Template
+		if ht, err = GetLayoutHTMLTmpl(ctxt, names, ext, layout, fmap); err != nil {
+			return nil, err
+		}
+		return ht, nil
+	}
+
+	return engine.Parse(th.FS, p, shares, fmap)
+}
+
+func isHTMLEngine(engine ViewEngine) bool {
+	_, ok := engine.(HTMLEngine)
+	return ok
+}
+
+// HTMLEngine parses Go html templates, those of the extensions without an engine of their own
+type HTMLEngine struct{}
+
+func (HTMLEngine) Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t ViewTmpl, err error) {
+	if !fileExists(fsys, p) {
+		return nil, ErrTmplNotExist
+	}
+
+	var ht *template.Template
+	if ht, err = loadHTMLTmpl(fsys, p, shares, fmap); err == nil {
+		t = ht
+	}
+	return
+}
+
+func (HTMLEngine) ContentType(ext string) string {
+	return ""
+}
+
+// TextEngine parses Go text templates, whose output is not escaped, e.g. of plain text pages
+type TextEngine struct {
+	// Type is the content type of the pages, the one of the extension if empty
+	Type string
+}
+
+func (te TextEngine) Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t ViewTmpl, err error) {
+	if !fileExists(fsys, p) {
+		return nil, ErrTmplNotExist
+	}
+
+	var tt *txtemplate.Template
+	if tt, err = loadTextTmpl(fsys, p, shares, txtemplate.FuncMap(fmap)); err == nil {
+		t = tt
+	}
+	return
+}
+
+func (te TextEngine) ContentType(ext string) string {
+	return te.Type
+}
+
+// XMLEngine parses Go text templates of XML pages (e.g. feeds or sitemaps),
+// in which the output of every action is escaped for XML, as html templates escape it for HTML
+type XMLEngine struct {
+	// Type is the content type of the pages, the one of the extension if empty
+	Type string
+}
+
+func (xe XMLEngine) Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t ViewTmpl, err error) {
+	if !fileExists(fsys, p) {
+		return nil, ErrTmplNotExist
+	}
+
+	var tt *txtemplate.Template
+	if tt, err = loadTextTmpl(fsys, p, shares, txtemplate.FuncMap(fmap)); err == nil {
+		tt, err = escapeXMLTmpl(tt)
+	}
+	if err == nil {
+		t = tt
+	}
+	return
+}
+
+func (xe XMLEngine) ContentType(ext string) string {
+	return xe.Type
+}
+
+// xmlEscaperName is the func appended to the pipelines of the actions of an xml template
+const xmlEscaperName = "_sunnified_xmlescaper"
+
+// escapeXMLTmpl appends the escaper to the actions of the template and those associated with it;
+// the parse trees are copied first, since those of a template from the cache are shared with its clones
+func escapeXMLTmpl(t *txtemplate.Template) (*txtemplate.Template, error) {
+	t = t.Funcs(txtemplate.FuncMap{xmlEscaperName: xmlEscaper})
+
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree == nil || tmpl.Tree.Root == nil {
+			continue
+		}
+
+		tree := tmpl.Tree.Copy()
+		escapeXMLNode(tree.Root)
+
+		if _, err := t.AddParseTree(tmpl.Name(), tree); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+func escapeXMLNode(node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n != nil {
+			for _, child := range n.Nodes {
+				escapeXMLNode(child)
+			}
+		}
+	case *parse.ActionNode:
+		// a declaration (e.g. {{$x := .Name}}) has no output
+		if len(n.Pipe.Decl) == 0 {
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+				NodeType: parse.NodeCommand,
+				Pos:      n.Pos,
+				Args:     []parse.Node{parse.NewIdentifier(xmlEscaperName).SetPos(n.Pos)},
+			})
+		}
+	case *parse.IfNode:
+		escapeXMLNode(n.List)
+		escapeXMLNode(n.ElseList)
+	case *parse.RangeNode:
+		escapeXMLNode(n.List)
+		escapeXMLNode(n.ElseList)
+	case *parse.WithNode:
+		escapeXMLNode(n.List)
+		escapeXMLNode(n.ElseList)
+	}
+}
+
+// xmlEscaper prints the values as a text template does and escapes them for XML, a missing value is empty
+func xmlEscaper(args ...interface{}) string {
+	if len(args) == 1 && args[0] == nil {
+		return ""
+	}
+	return txtemplate.HTMLEscapeString(fmt.Sprint(args...))
+}
+
+// MarkdownEngine executes Go text templates of Markdown and converts their output to HTML (see MarkdownToHTML),
+// in which HTML of the template or the data is escaped
+type MarkdownEngine struct{}
+
+func (MarkdownEngine) Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t ViewTmpl, err error) {
+	if !fileExists(fsys, p) {
+		return nil, ErrTmplNotExist
+	}
+
+	var tt *txtemplate.Template
+	if tt, err = loadTextTmpl(fsys, p, shares, txtemplate.FuncMap(fmap)); err == nil {
+		t = markdownTmpl{tt}
+	}
+	return
+}
+
+func (MarkdownEngine) ContentType(ext string) string {
+	return "text/html; charset=utf-8"
+}
+
+type markdownTmpl struct {
+	*txtemplate.Template
+}
+
+func (mt markdownTmpl) Execute(w io.Writer, data interface{}) error {
+	var buf bytes.Buffer
+	if err := mt.Template.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, MarkdownToHTML(buf.String()))
+	return err
+}
+
+// ViewContentType returns the content type of the pages of the extension given by its engine, empty if it has none
+func ViewContentType(ext string) string {
+	return GetViewEngine(ext).ContentType(ext)
+}
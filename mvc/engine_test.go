@@ -0,0 +1,116 @@
+package mvc
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"io/fs"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+type upperEngine struct{}
+
+type upperTmpl string
+
+func (t upperTmpl) Execute(w io.Writer, data interface{}) error {
+	_, err := io.WriteString(w, strings.ToUpper(string(t)))
+	return err
+}
+
+func (upperEngine) Parse(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (ViewTmpl, error) {
+	b, err := readFile(fsys, p)
+	return upperTmpl(b), err
+}
+
+func (upperEngine) ContentType(ext string) string {
+	return "text/x-upper"
+}
+
+func TestViewEngines(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.txt", `{{.Name}} & <co>`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.md", "# {{.Name}}\n\n*cheap* <b>")
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.up", `shout`)
+
+	SetViewEngine(".up", upperEngine{})
+	defer SetViewEngine(".up", nil)
+
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/shop/cart/list", nil))
+	ctxt.SetResource(ThemesResourceName, NewThemes(root, "default"))
+	names := Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}
+
+	for ext, want := range map[string]string{
+		".txt": "pen & <co>",
+		".md":  "<h1>pen</h1>\n<p><em>cheap</em> &lt;b&gt;</p>\n",
+		".up":  "SHOUT",
+	} {
+		tmpl, err := GetViewTmpl(ctxt, names, ext, "", nil)
+		if err != nil {
+			t.Fatal(ext, err)
+		}
+
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, VM{"Name": "pen"}); err != nil || buf.String() != want {
+			t.Errorf("%s: got %q %v", ext, buf.String(), err)
+		}
+	}
+
+	if ViewContentType(".up") != "text/x-upper" || ViewContentType(".md") != "text/html; charset=utf-8" {
+		t.Error("content types of the engines")
+	}
+
+	if _, err := GetViewTmpl(ctxt, names, ".json", "", nil); err != ErrTmplNotExist {
+		t.Errorf("missing template: %v", err)
+	}
+}
+
+func TestXMLEngine(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/_share_/item.xml", `{{define "item"}}<item>{{.}}</item>{{end}}`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/feed.xml", `<?xml version="1.0"?>`+
+		`<feed title="{{.Title}}">{{$n := .Title}}{{range .Items}}{{template "item" .}}{{end}}`+
+		`{{if .Missing}}x{{else}}{{.Missing}}{{end}}</feed>`)
+
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/shop/cart/feed", nil))
+	ctxt.SetResource(ThemesResourceName, NewThemes(root, "default"))
+	names := Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "feed"}
+	want := `<?xml version="1.0"?><feed title="&#34;A&#34; &amp; B">` +
+		`<item>&lt;pen&gt;</item><item>ink &amp; &#39;nib&#39;</item></feed>`
+
+	// the second template is from the cache, whose actions must not be escaped twice
+	for i := 0; i < 2; i++ {
+		tmpl, err := GetViewTmpl(ctxt, names, ".xml", "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		err = tmpl.Execute(&buf, VM{"Title": `"A" & B`, "Items": []string{"<pen>", "ink & 'nib'"}})
+		if err != nil || buf.String() != want {
+			t.Errorf("%d: got %q %v", i, buf.String(), err)
+		}
+	}
+
+	if ViewContentType(".xml") != "application/xml; charset=utf-8" {
+		t.Errorf("content type %q", ViewContentType(".xml"))
+	}
+}
+
+func TestMarkdownToHTML(t *testing.T) {
+	for src, want := range map[string]string{
+		"## Title ##":                   "<h2>Title</h2>\n",
+		"a **b** `<c>`\nd":              "<p>a <strong>b</strong> <code>&lt;c&gt;</code>\nd</p>\n",
+		"- one\n- two\n\n1. first":      "<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n<ol>\n<li>first</li>\n</ol>\n",
+		"```go\nx := <-c\n```":          "<pre><code class=\"language-go\">x := &lt;-c\n</code></pre>\n",
+		"> quoted\n\n---":               "<blockquote>\n<p>quoted</p>\n</blockquote>\n<hr>\n",
+		"[home](/) [x](javascript:a())": "<p><a href=\"/\">home</a> <a href=\"#\">x</a></p>\n",
+	} {
+		if got := MarkdownToHTML(src); got != want {
+			t.Errorf("%q: got %q", src, got)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mvc
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"regexp"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// NoLayout is the layout of a view rendered without a layout, even if its module has a default one
+const NoLayout = "-"
+
+// maxLayoutDepth is the most layouts that can extend one another, which stops a cycle of extends
+const maxLayoutDepth = 16
+
+var extendsrex = regexp.MustCompile(`^\s*\{\{-?\s*/\*\s*extends\s+"([^"]+)"\s*\*/\s*-?\}\}`)
+
+// LayoutView is implemented by views rendering their template through a layout,
+// e.g. for a middleware to choose the layout in the View phase
+type LayoutView interface {
+	SetLayout(string)
+	Layout() string
+}
+
+// Layouter is implemented by controllers choosing the layout of their actions,
+// an empty string leaves it to the default layout of the module (see Themes.SetLayout)
+type Layouter interface {
+	Layout_(action string) string
+}
+
+// SetLayout sets the default layout of the module, the layout of module "" is the default of all modules
+func (th *Themes) SetLayout(module, layout string) {
+	if th.Layouts == nil {
+		th.Layouts = make(map[string]string)
+	}
+	th.Layouts[module] = layout
+}
+
+// LayoutOf returns the default layout of the module, empty if it has none
+func (th *Themes) LayoutOf(module string) string {
+	if layout, exists := th.Layouts[module]; exists {
+		return layout
+	}
+	return th.Layouts[""]
+}
+
+// LayoutPath returns the path of a layout of the theme, e.g. themes/default/tmpl/_layout_/base.html
+func (th *Themes) LayoutPath(theme, layout, ext string) string {
+	return path.Join(th.Root, theme, th.TmplDir, th.LayoutDir, layout+ext)
+}
+
+// FindLayouts returns the paths of the layout and the layouts it extends (with {{/* extends "base" */}}
+// at the start of the layout), the outermost first; each is taken from the first theme of the chain which has it.
+// A layout of the extension no theme has is left out with the layouts it extends,
+// e.g. a JSON template of a module whose default layout is only in HTML.
+func (th *Themes) FindLayouts(ctxt *web.Context, layout, ext string) (paths []string, err error) {
+	return th.findLayoutsIn(th.Chain(ctxt), layout, ext)
+}
+
+func (th *Themes) findLayoutsIn(chain []string, layout, ext string) (paths []string, err error) {
+	for depth := 0; layout != "" && layout != NoLayout; depth++ {
+		if depth == maxLayoutDepth {
+			return nil, fmt.Errorf("mvc: layout %s extends more than %d layouts", layout, maxLayoutDepth)
+		}
+
+		var p string
+		for _, theme := range chain {
+			if lp := th.LayoutPath(theme, layout, ext); fileExists(th.FS, lp) {
+				p = lp
+				break
+			}
+		}
+
+		if p == "" {
+			break
+		}
+
+		b, err := readFile(th.FS, p)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append([]string{p}, paths...)
+		layout = ""
+
+		if m := extendsrex.FindSubmatch(b); m != nil {
+			layout = string(m[1])
+		}
+	}
+
+	return
+}
+
+// GetLayoutHTMLTmpl returns the template of the action in the theme of the request rendered through the layout,
+// the default layout of the module if it is empty; the template is the outermost layout,
+// with the blocks the action template (and the layouts it extends) define replaced
+func GetLayoutHTMLTmpl(ctxt *web.Context, names Meta, ext, layout string, fmap template.FuncMap) (t *template.Template, err error) {
+	th := GetThemes(ctxt)
+	return th.layoutHTMLTmpl(th.Chain(ctxt), names, ext, layout, fmap)
+}
+
+// layoutHTMLTmpl is GetLayoutHTMLTmpl looking for the templates in the themes of the chain
+func (th *Themes) layoutHTMLTmpl(chain []string, names Meta, ext, layout string, fmap template.FuncMap) (t *template.Template, err error) {
+	if layout == "" {
+		layout = th.LayoutOf(names[MVCModule])
+	}
+
+	layouts, err := th.findLayoutsIn(chain, layout, ext)
+	if err != nil {
+		return nil, err
+	}
+
+	p, shares := th.findIn(chain, names, ext)
+	if len(layouts) == 0 {
+		return loadHTMLTmpl(th.FS, p, shares, fmap)
+	}
+
+	key := newHTKey(th.FS, append(append([]string{p}, shares...), layouts...)...)
+
+	if t = getHTMLCache(key); t == nil {
+		if t, err = parseLayoutHTMLTmpl(th.FS, p, shares, layouts); err != nil {
+			return nil, err
+		}
+
+		setHTMLCache(key, t)
+		t, _ = t.Clone()
+	}
+
+	t = t.Funcs(fmap)
+	return
+}
+
+// parseLayoutHTMLTmpl parses the layouts from the outermost, then the partials and the action template,
+// so that each replaces the blocks of the ones before it
+func parseLayoutHTMLTmpl(fsys fs.FS, p string, shares, layouts []string) (t *template.Template, err error) {
+	t = template.New(layoutName(layouts[0])).Funcs(GetFuncMap())
+
+	for i, lp := range layouts {
+		b, err := readFile(fsys, lp)
+		if err != nil {
+			return nil, err
+		}
+
+		tt := t
+		if i > 0 {
+			tt = t.New(layoutName(lp))
+		}
+
+		if _, err = tt.Parse(string(b)); err != nil {
+			return nil, err
+		}
+	}
+
+	parseShares(t, fsys, shares)
+
+	b, err := readFile(fsys, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = t.New(path.Base(p)).Parse(string(b)); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// layoutName returns the name of the template of a layout, which is apart from the names of the action templates
+func layoutName(p string) string {
+	return "_layout_/" + path.Base(p)
+}
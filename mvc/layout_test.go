@@ -0,0 +1,56 @@
+package mvc
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func TestLayouts(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/_layout_/base.html", `<title>{{block "title" .}}site{{end}}</title>{{block "content" .}}{{end}}`)
+	writeThemeFile(t, root, "default/tmpl/_layout_/shop.html", `{{/* extends "base" */}}{{define "content"}}<nav/>{{block "main" .}}{{end}}{{end}}`)
+	writeThemeFile(t, root, "default/tmpl/_layout_/loop.html", `{{/* extends "loop" */}}`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.html", `{{define "title"}}cart{{end}}{{define "main"}}{{.N}} items{{end}}`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.json", `{"n":{{.N}}}`)
+
+	th := NewThemes(root, "default")
+	th.SetLayout("shop", "shop")
+
+	names := Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/shop/cart/list", nil))
+	ctxt.SetResource(ThemesResourceName, th)
+
+	for layout, want := range map[string]string{
+		"":       "<title>cart</title><nav/>3 items",
+		"base":   "<title>cart</title>",
+		NoLayout: "",
+	} {
+		tmpl, err := GetLayoutHTMLTmpl(ctxt, names, ".html", layout, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, VM{"N": 3}); err != nil || buf.String() != want {
+			t.Errorf("layout %q: got %q %v", layout, buf.String(), err)
+		}
+	}
+
+	// there is no JSON layout, so the JSON template renders on its own
+	tmpl, err := GetLayoutHTMLTmpl(ctxt, names, ".json", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, VM{"N": 3}); err != nil || buf.String() != `{"n":3}` {
+		t.Errorf("json: got %q %v", buf.String(), err)
+	}
+
+	if _, err = th.FindLayouts(ctxt, "loop", ".html"); err == nil {
+		t.Error("expected an error for a layout extending itself")
+	}
+}
@@ -0,0 +1,216 @@
+package mvc
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdheading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdulist   = regexp.MustCompile(`^\s{0,3}[-*+]\s+(.*)$`)
+	mdolist   = regexp.MustCompile(`^\s{0,3}\d{1,9}[.)]\s+(.*)$`)
+)
+
+// MarkdownToHTML converts the common subset of Markdown to HTML: headings, paragraphs, emphasis, links,
+// code spans, fenced code blocks, lists, block quotes and rules; HTML in the Markdown is escaped,
+// as are links to schemes other than http, https and mailto
+func MarkdownToHTML(src string) string {
+	var sb strings.Builder
+	markdownBlocks(&sb, strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n"))
+	return sb.String()
+}
+
+func markdownBlocks(sb *strings.Builder, lines []string) {
+	var para []string
+
+	endPara := func() {
+		if len(para) > 0 {
+			sb.WriteString("<p>")
+			for i, line := range para {
+				if i > 0 {
+					if strings.HasSuffix(para[i-1], "  ") {
+						sb.WriteString("<br>")
+					}
+					sb.WriteString("\n")
+				}
+				sb.WriteString(markdownInline(strings.TrimSpace(line)))
+			}
+			sb.WriteString("</p>\n")
+			para = nil
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "":
+			endPara()
+
+		case strings.HasPrefix(trimmed, "```"):
+			endPara()
+			lang := strings.TrimSpace(strings.TrimLeft(trimmed, "`"))
+
+			var code []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				code = append(code, lines[i])
+			}
+
+			if lang != "" {
+				sb.WriteString(`<pre><code class="language-` + html.EscapeString(strings.Fields(lang)[0]) + `">`)
+			} else {
+				sb.WriteString("<pre><code>")
+			}
+			if len(code) > 0 {
+				sb.WriteString(html.EscapeString(strings.Join(code, "\n")) + "\n")
+			}
+			sb.WriteString("</code></pre>\n")
+
+		case mdheading.MatchString(trimmed):
+			endPara()
+			m := mdheading.FindStringSubmatch(trimmed)
+			level := string(rune('0' + len(m[1])))
+			sb.WriteString("<h" + level + ">" + markdownInline(m[2]) + "</h" + level + ">\n")
+
+		case isMarkdownRule(trimmed):
+			endPara()
+			sb.WriteString("<hr>\n")
+
+		case strings.HasPrefix(trimmed, ">"):
+			endPara()
+
+			var quote []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				q := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quote = append(quote, strings.TrimPrefix(q, " "))
+			}
+			i--
+
+			sb.WriteString("<blockquote>\n")
+			markdownBlocks(sb, quote)
+			sb.WriteString("</blockquote>\n")
+
+		case mdulist.MatchString(line) || mdolist.MatchString(line):
+			endPara()
+
+			rex, tag := mdulist, "ul"
+			if !mdulist.MatchString(line) {
+				rex, tag = mdolist, "ol"
+			}
+
+			sb.WriteString("<" + tag + ">\n")
+			for i < len(lines) {
+				m := rex.FindStringSubmatch(lines[i])
+				if m == nil {
+					break
+				}
+
+				item := []string{m[1]}
+				// indented lines continue the item
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != "" &&
+					(strings.HasPrefix(lines[i], " ") || strings.HasPrefix(lines[i], "\t")) && !rex.MatchString(lines[i]); i++ {
+					item = append(item, strings.TrimSpace(lines[i]))
+				}
+
+				sb.WriteString("<li>" + markdownInline(strings.Join(item, "\n")) + "</li>\n")
+			}
+			sb.WriteString("</" + tag + ">\n")
+			i--
+
+		default:
+			para = append(para, line)
+		}
+	}
+
+	endPara()
+}
+
+func markdownInline(s string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); {
+		c := s[i]
+
+		switch {
+		case c == '\\' && i+1 < len(s) && strings.IndexByte("\\`*_{}[]()#+-.!>", s[i+1]) >= 0:
+			sb.WriteString(html.EscapeString(s[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			if end := strings.IndexByte(s[i+1:], '`'); end >= 0 {
+				sb.WriteString("<code>" + html.EscapeString(s[i+1:i+1+end]) + "</code>")
+				i += end + 2
+				continue
+			}
+
+		case c == '[':
+			if mid := strings.Index(s[i:], "]("); mid > 0 {
+				if end := closingParen(s[i+mid+2:]); end >= 0 {
+					text := s[i+1 : i+mid]
+					url := strings.TrimSpace(s[i+mid+2 : i+mid+2+end])
+					sb.WriteString(`<a href="` + html.EscapeString(markdownURL(url)) + `">` + markdownInline(text) + "</a>")
+					i += mid + 3 + end
+					continue
+				}
+			}
+
+		case c == '*' && strings.HasPrefix(s[i:], "**"):
+			if end := strings.Index(s[i+2:], "**"); end > 0 {
+				sb.WriteString("<strong>" + markdownInline(s[i+2:i+2+end]) + "</strong>")
+				i += end + 4
+				continue
+			}
+
+		case c == '*' && i+1 < len(s) && s[i+1] != ' ':
+			if end := strings.IndexByte(s[i+1:], '*'); end > 0 {
+				sb.WriteString("<em>" + markdownInline(s[i+1:i+1+end]) + "</em>")
+				i += end + 2
+				continue
+			}
+		}
+
+		sb.WriteString(html.EscapeString(s[i : i+1]))
+		i++
+	}
+
+	return sb.String()
+}
+
+// closingParen returns the index of the parenthesis closing the link URL at the start of s, -1 if there is none
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// isMarkdownRule returns whether the line is a rule, three or more of -, * or _ with optional spaces
+func isMarkdownRule(line string) bool {
+	rule := strings.Join(strings.Fields(line), "")
+	return len(rule) >= 3 && strings.IndexByte("-*_", rule[0]) >= 0 && strings.Count(rule, rule[:1]) == len(rule)
+}
+
+// markdownURL returns the URL of a link, # for a scheme other than http, https and mailto,
+// which could run a script (e.g. javascript:); a relative URL has no colon before its path
+func markdownURL(url string) string {
+	if i := strings.IndexAny(url, ":/?#"); i >= 0 && url[i] == ':' {
+		switch strings.ToLower(url[:i]) {
+		case "http", "https", "mailto":
+		default:
+			return "#"
+		}
+	}
+	return url
+}
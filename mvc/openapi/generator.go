@@ -0,0 +1,397 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zaolab/sunnified/handler"
+	"github.com/zaolab/sunnified/mvc/controller"
+	"github.com/zaolab/sunnified/mvc/view"
+)
+
+var (
+	pathvarrex   = regexp.MustCompile(`\{([^}:*]+)\*?(:[^}]*)?\}`)
+	typeJSONView = reflect.TypeOf(view.JSONView{})
+
+	// errorSchema is the schema of the reply of a typed action returning an error
+	errorSchema = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status": {Type: "integer", Format: "int32"},
+			"error":  {Type: "string"},
+		},
+		Required: []string{"status", "error"},
+	}
+)
+
+// Describer is implemented by controllers to describe their operations further,
+// e.g. the summary or the schema of the JSON an action responds with:
+//
+//	func (c *Cart) OpenAPI_(action, method string, op *openapi.Operation, g *openapi.Generator) {
+//		if action == "list" {
+//			op.Summary = "Lists the items in the cart"
+//			op.SetResponse("200", "OK", g.TypeSchema(reflect.TypeOf([]Item{})))
+//		}
+//	}
+type Describer interface {
+	OpenAPI_(action, method string, op *Operation, g *Generator)
+}
+
+func NewGenerator(title, version string) *Generator {
+	return &Generator{
+		info:    Info{Title: title, Version: version},
+		paths:   make(map[string]*PathItem),
+		types:   make(map[reflect.Type]string),
+		schemas: make(map[string]*Schema),
+	}
+}
+
+// Generator builds an OpenAPI document from the controllers and endpoints added to it,
+// it is also an http.Handler serving the document (as YAML if the path ends with .yaml or .yml,
+// or YAML is accepted over JSON), e.g. mounted with app.Handle("/openapi.json", g)
+type Generator struct {
+	mutex   sync.Mutex
+	info    Info
+	servers []Server
+	paths   map[string]*PathItem
+	types   map[reflect.Type]string
+	schemas map[string]*Schema
+}
+
+func (g *Generator) SetDescription(description string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.info.Description = description
+}
+
+func (g *Generator) AddServer(url, description string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.servers = append(g.servers, Server{URL: url, Description: description})
+}
+
+// AddGroup adds the actions of the controllers of the group as they are routed by the dynamic handler,
+// i.e. prefix/module/controller/action
+func (g *Generator) AddGroup(prefix string, group *controller.Group) {
+	for _, mod := range group.Modules() {
+		var (
+			ctrls = group.Module(mod)
+			names = make([]string, 0, len(ctrls))
+		)
+
+		for name := range ctrls {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			g.AddController(path.Join("/", prefix, mod, name), ctrls[name])
+		}
+	}
+}
+
+// AddController adds the actions of the controller routed under p, i.e. p/action
+// (the default action _ is routed at p itself)
+func (g *Generator) AddController(p string, cm *controller.Meta) {
+	meths := cm.Meths()
+	actions := make([]string, 0, len(meths))
+
+	for action := range meths {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+
+	for _, action := range actions {
+		ap := path.Join("/", p)
+		if action != "_" {
+			ap = path.Join(ap, action)
+		}
+
+		for _, method := range cm.ActionAvailableMethodsList(action) {
+			g.addAction(ap, method, action, cm, cm.Action(action, controller.ReqMethodFromString(method)))
+		}
+	}
+}
+
+// AddResource adds the routes of the resource and its nested resources
+func (g *Generator) AddResource(res *handler.RESTResource) {
+	for _, route := range res.Routes() {
+		reqmeth := controller.ReqMethodFromString(route.Method)
+		ameta := route.Controller.Action(route.Action, reqmeth)
+
+		if ameta == nil && reqmeth == controller.ReqMethodPatch {
+			ameta = route.Controller.Action(route.Action, controller.ReqMethodPut)
+		}
+
+		g.addAction(route.Path, route.Method, route.Action, route.Controller, ameta)
+	}
+}
+
+// Add adds an operation of an endpoint routed explicitly, e.g. one of a plain http.Handler;
+// p is the path as it is routed, e.g. /files/{name} or /files/{id:int}
+func (g *Generator) Add(method, p string, op *Operation) {
+	if op.Responses == nil {
+		op.SetResponse("200", "OK", nil)
+	}
+
+	p, vars := openAPIPath(p)
+	for _, name := range vars {
+		if op.Parameter(name, "path") == nil {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	item, exists := g.paths[p]
+	if !exists {
+		item = &PathItem{}
+		g.paths[p] = item
+	}
+
+	(*item)[strings.ToLower(method)] = op
+}
+
+func (g *Generator) addAction(p, method, action string, cm *controller.Meta, ameta *controller.ActionMeta) {
+	if ameta == nil {
+		return
+	}
+
+	op := &Operation{
+		Tags:        []string{cm.Module() + "." + cm.Name()},
+		OperationID: strings.ToLower(method) + "." + cm.Module() + "." + cm.Name(),
+	}
+
+	if action != "_" {
+		op.OperationID += "." + action
+	}
+
+	g.mutex.Lock()
+	g.addData(op, p, method, cm, ameta)
+	op.SetResponse("200", "OK", g.resultSchema(ameta))
+
+	if ameta.Err() {
+		op.SetResponse("default", "Error", errorSchema)
+	}
+	g.mutex.Unlock()
+
+	rtype := cm.RType()
+	if rtype.Kind() == reflect.Ptr {
+		rtype = rtype.Elem()
+	}
+
+	if describer, ok := reflect.New(rtype).Interface().(Describer); ok {
+		describer.OpenAPI_(action, method, op, g)
+	}
+
+	g.Add(method, p, op)
+}
+
+// addData adds the request data bound to the arguments of the action and the fields of the controller,
+// as query parameters for GET, HEAD and DELETE or as the request body otherwise
+func (g *Generator) addData(op *Operation, p, method string, cm *controller.Meta, ameta *controller.ActionMeta) {
+	var (
+		_, vars  = openAPIPath(p)
+		body     = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		inquery  = method == "GET" || method == "HEAD" || method == "DELETE"
+		upload   bool
+		jsonbody *Schema
+		add      = func(dm *controller.DataMeta, schema *Schema, required bool) {
+			name := dm.LName()
+
+			for _, v := range vars {
+				if strings.EqualFold(v, name) {
+					// given by the path
+					return
+				}
+			}
+
+			if hasUpload(dm) {
+				upload = true
+			}
+
+			if inquery {
+				param := &Parameter{Name: name, In: "query", Required: required, Schema: schema}
+				if isComposite(dm.T()) {
+					explode := true
+					param.Style, param.Explode = "deepObject", &explode
+				}
+				op.Parameters = append(op.Parameters, param)
+			} else {
+				body.Properties[name] = schema
+				if required {
+					body.Required = append(body.Required, name)
+				}
+			}
+		}
+	)
+
+	for _, field := range cm.Fields() {
+		if !isBound(field.T()) {
+			continue
+		} else if field.T() == controller.DatatypeEmbedded {
+			for _, efield := range field.Fields() {
+				if isBound(efield.T()) {
+					schema, required := FieldSchema(efield)
+					add(&efield.DataMeta, schema, required)
+				}
+			}
+			continue
+		}
+
+		schema, required := FieldSchema(field)
+		add(&field.DataMeta, schema, required)
+	}
+
+	for _, arg := range ameta.Args() {
+		switch {
+		case !isBound(arg.T()):
+			continue
+		case arg.T() == controller.DatatypeStruct && ameta.Typed() && !inquery:
+			// a JSON body is decoded into the struct argument of a typed action by its json tags
+			jsonbody = g.TypeSchema(arg.RType())
+			fallthrough
+		case arg.T() == controller.DatatypeStruct || arg.T() == controller.DatatypeEmbedded:
+			// a struct argument is bound from the same level as the other arguments
+			for _, field := range arg.Fields() {
+				if isBound(field.T()) {
+					schema, required := FieldSchema(field)
+					add(&field.DataMeta, schema, required)
+				}
+			}
+		case arg.T() == controller.DatatypeUploadedFile || (arg.T() == controller.DatatypeSlice && arg.Elem().T() == controller.DatatypeUploadedFile):
+			// an argument has no name, so it takes the files of all names
+			body.AdditionalProperties = DataSchema(&arg.DataMeta)
+			upload = true
+		default:
+			add(&arg.DataMeta, DataSchema(&arg.DataMeta), false)
+		}
+	}
+
+	if len(body.Properties) > 0 || body.AdditionalProperties != nil {
+		op.RequestBody = &RequestBody{Content: make(map[string]*MediaType)}
+
+		if upload {
+			op.RequestBody.Content["multipart/form-data"] = &MediaType{Schema: body}
+		} else {
+			op.RequestBody.Content["application/x-www-form-urlencoded"] = &MediaType{Schema: body}
+			op.RequestBody.Content["application/json"] = &MediaType{Schema: body}
+		}
+	}
+
+	if jsonbody != nil {
+		if op.RequestBody == nil {
+			op.RequestBody = &RequestBody{Content: make(map[string]*MediaType)}
+		}
+		op.RequestBody.Content["application/json"] = &MediaType{Schema: jsonbody}
+	}
+}
+
+// resultSchema returns the schema of the JSON the action responds with, as far as it can be known from its result;
+// the schema of its data can be given by the Describer of the controller
+func (g *Generator) resultSchema(ameta *controller.ActionMeta) *Schema {
+	mtype := ameta.RMeth().Type
+
+	if ameta.Typed() {
+		return g.TypeSchema(mtype.Out(0))
+	} else if ameta.Vmap() || ameta.MapSI() || mtype.Out(0) == typeJSONView {
+		return &Schema{Type: "object"}
+	}
+
+	// e.g. mvc.View, which can be of any type
+	return nil
+}
+
+// Document returns the document of what has been added
+func (g *Generator) Document() *Document {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    g.info,
+		Servers: g.servers,
+		Paths:   make(map[string]*PathItem, len(g.paths)),
+	}
+
+	for p, item := range g.paths {
+		doc.Paths[p] = item
+	}
+
+	if len(g.schemas) > 0 {
+		doc.Components = &Components{Schemas: make(map[string]*Schema, len(g.schemas))}
+		for name, schema := range g.schemas {
+			doc.Components.Schemas[name] = schema
+		}
+	}
+
+	return doc
+}
+
+func (g *Generator) JSON() ([]byte, error) {
+	return json.MarshalIndent(g.Document(), "", "  ")
+}
+
+func (g *Generator) YAML() ([]byte, error) {
+	b, err := json.Marshal(g.Document())
+	if err != nil {
+		return nil, err
+	}
+	return JSONToYAML(b)
+}
+
+func (g *Generator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		b     []byte
+		err   error
+		ctype string
+		ext   = path.Ext(r.URL.Path)
+		yaml  = ext == ".yaml" || ext == ".yml"
+	)
+
+	if !yaml && ext == "" {
+		accept := r.Header.Get("Accept")
+		yaml = strings.Contains(accept, "yaml") && !strings.Contains(accept, "json")
+	}
+
+	if yaml {
+		ctype = "application/yaml"
+		b, err = g.YAML()
+	} else {
+		ctype = "application/json"
+		b, err = g.JSON()
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ctype+"; charset=utf-8")
+	w.Write(b)
+}
+
+// openAPIPath converts a routed path to an OpenAPI path, e.g. /files/{id:int} to /files/{id},
+// returning the names of its vars
+func openAPIPath(p string) (string, []string) {
+	var vars []string
+
+	p = pathvarrex.ReplaceAllStringFunc(p, func(v string) string {
+		name := strings.TrimSpace(pathvarrex.FindStringSubmatch(v)[1])
+		vars = append(vars, name)
+		return "{" + name + "}"
+	})
+
+	if len(p) > 1 {
+		p = strings.TrimRight(p, "/")
+	}
+
+	return p, vars
+}
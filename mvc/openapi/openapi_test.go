@@ -0,0 +1,156 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/mvc/controller"
+	"github.com/zaolab/sunnified/mvc/view"
+	"github.com/zaolab/sunnified/web"
+)
+
+type Item struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags,omitempty"`
+	Child *Item    `json:"child"`
+}
+
+type itemForm struct {
+	Name string `validate:"required,max=20"`
+	Kind string `validate:"oneof=pen book"`
+}
+
+type (
+	Q_String  string
+	Limit_Int int
+)
+
+type Items struct{}
+
+func (c *Items) GETFind(q Q_String, limit Limit_Int) mvc.View {
+	return view.NewJSONView(mvc.VM{"items": []Item{}})
+}
+
+func (c *Items) POSTAdd(ctxt *web.Context, form itemForm) mvc.VM {
+	return mvc.VM{}
+}
+
+func (c *Items) OpenAPI_(action, method string, op *Operation, g *Generator) {
+	if action == "find" {
+		op.Summary = "Finds the items"
+		op.SetResponse("200", "OK", g.TypeSchema(reflect.TypeOf([]Item{})))
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	group := controller.NewControllerGroup()
+	group.AddController(&Items{})
+
+	g := NewGenerator("Items", "1.0")
+	g.AddGroup("/api", group)
+	g.Add("GET", "/files/{name:string}", &Operation{Summary: "Downloads the file"})
+
+	doc := g.Document()
+
+	var find, add *Operation
+	for p, item := range doc.Paths {
+		switch {
+		case strings.HasSuffix(p, "/items/find"):
+			find = (*item)["get"]
+		case strings.HasSuffix(p, "/items/add"):
+			add = (*item)["post"]
+		}
+	}
+
+	if find == nil || add == nil {
+		t.Fatalf("operations missing from %v", reflect.ValueOf(doc.Paths).MapKeys())
+	}
+
+	if find.Summary != "Finds the items" {
+		t.Errorf("summary of Describer not set: %q", find.Summary)
+	}
+	if p := find.Parameter("limit", "query"); p == nil || p.Schema.Type != "integer" {
+		t.Errorf("query parameter limit: %+v", p)
+	}
+	if s := find.Responses["200"].Content["application/json"].Schema; s.Type != "array" || s.Items.Ref != "#/components/schemas/Item" {
+		t.Errorf("response of find: %+v", s)
+	}
+
+	body := add.RequestBody.Content["application/json"].Schema
+	if body.Properties["name"] == nil || *body.Properties["name"].MaxLength != 20 || len(body.Required) != 1 {
+		t.Errorf("request body of add: %+v", body)
+	}
+	if len(body.Properties["kind"].Enum) != 2 {
+		t.Errorf("enum of kind: %+v", body.Properties["kind"])
+	}
+	if s := add.Responses["200"].Content["application/json"].Schema; s.Type != "object" {
+		t.Errorf("response of add: %+v", s)
+	}
+
+	files := (*doc.Paths["/files/{name}"])["get"]
+	if files == nil || files.Parameter("name", "path") == nil {
+		t.Errorf("path parameter of /files/{name}: %+v", files)
+	}
+
+	item := doc.Components.Schemas["Item"]
+	if item == nil || item.Properties["child"].Ref != "#/components/schemas/Item" ||
+		!reflect.DeepEqual(item.Required, []string{"id", "name"}) {
+		t.Errorf("schema of Item: %+v", item)
+	}
+}
+
+func TestGeneratorServeHTTP(t *testing.T) {
+	g := NewGenerator("Items", "1.0")
+	g.Add("GET", "/items", &Operation{Summary: "Lists: the items"})
+
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil || doc["openapi"] != Version {
+		t.Fatalf("JSON document: %v %s", err, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.yaml", nil))
+
+	want := `openapi: 3.0.3
+info:
+  title: Items
+  version: "1.0"
+paths:
+  /items:
+    get:
+      summary: "Lists: the items"
+      responses:
+        "200":
+          description: OK
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("YAML document:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestJSONToYAML(t *testing.T) {
+	b, err := JSONToYAML([]byte(`{"b":[{"x":1,"y":[]},"-a",null,true],"a":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `b:
+  - x: 1
+    "y": []
+  - "-a"
+  - null
+  - true
+a: {}
+`
+	if string(b) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b, want)
+	}
+}
@@ -0,0 +1,305 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zaolab/sunnified/mvc/controller"
+	"github.com/zaolab/sunnified/util/validate"
+)
+
+var (
+	typeTime          = reflect.TypeOf(time.Time{})
+	typeDuration      = reflect.TypeOf(time.Duration(0))
+	typeRawMessage    = reflect.TypeOf(json.RawMessage{})
+	typeJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// isBound returns whether the data is bound from the request data, as opposed to e.g. the *web.Context
+func isBound(t controller.DataType) bool {
+	switch t {
+	case controller.DatatypeString, controller.DatatypeInt, controller.DatatypeInt64,
+		controller.DatatypeFloat, controller.DatatypeFloat64, controller.DatatypeBool,
+		controller.DatatypeEmail, controller.DatatypeURL, controller.DatatypeDate,
+		controller.DatatypeTime, controller.DatatypeDateTime, controller.DatatypeStruct,
+		controller.DatatypeEmbedded, controller.DatatypeSlice, controller.DatatypeMap,
+		controller.DatatypeUploadedFile:
+		return true
+	}
+	return false
+}
+
+func isComposite(t controller.DataType) bool {
+	switch t {
+	case controller.DatatypeStruct, controller.DatatypeEmbedded, controller.DatatypeSlice, controller.DatatypeMap:
+		return true
+	}
+	return false
+}
+
+// hasUpload returns whether a file is uploaded to the data or any of its fields
+func hasUpload(dm *controller.DataMeta) bool {
+	switch dm.T() {
+	case controller.DatatypeUploadedFile:
+		return true
+	case controller.DatatypeSlice, controller.DatatypeMap:
+		return hasUpload(dm.Elem())
+	case controller.DatatypeStruct, controller.DatatypeEmbedded:
+		for _, field := range dm.Fields() {
+			if hasUpload(&field.DataMeta) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DataSchema returns the schema of the request data bound to an argument or field,
+// where the properties of a struct are named as they are bound (i.e. in lower case)
+func DataSchema(dm *controller.DataMeta) *Schema {
+	switch dm.T() {
+	case controller.DatatypeString:
+		return &Schema{Type: "string"}
+	case controller.DatatypeInt:
+		return &Schema{Type: "integer", Format: "int32"}
+	case controller.DatatypeInt64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case controller.DatatypeFloat:
+		return &Schema{Type: "number", Format: "float"}
+	case controller.DatatypeFloat64:
+		return &Schema{Type: "number", Format: "double"}
+	case controller.DatatypeBool:
+		return &Schema{Type: "boolean"}
+	case controller.DatatypeEmail:
+		return &Schema{Type: "string", Format: "email"}
+	case controller.DatatypeURL:
+		return &Schema{Type: "string", Format: "uri"}
+	case controller.DatatypeDate, controller.DatatypeDateTime:
+		if layout := dm.Layout(); layout != "" {
+			return &Schema{Type: "string", Description: "in the layout of " + layout}
+		} else if dm.T() == controller.DatatypeDate {
+			return &Schema{Type: "string", Format: "date"}
+		}
+		return &Schema{Type: "string", Format: "date-time"}
+	case controller.DatatypeTime:
+		return &Schema{Type: "string", Description: "a duration, e.g. 1h30m"}
+	case controller.DatatypeUploadedFile:
+		return &Schema{Type: "string", Format: "binary"}
+	case controller.DatatypeSlice:
+		return &Schema{Type: "array", Items: DataSchema(dm.Elem())}
+	case controller.DatatypeMap:
+		return &Schema{Type: "object", AdditionalProperties: DataSchema(dm.Elem())}
+	case controller.DatatypeStruct, controller.DatatypeEmbedded:
+		schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		addFieldSchemas(schema, dm.Fields())
+		return schema
+	}
+
+	return &Schema{}
+}
+
+func addFieldSchemas(schema *Schema, fields []*controller.FieldMeta) {
+	for _, field := range fields {
+		if !isBound(field.T()) {
+			continue
+		}
+
+		if field.T() == controller.DatatypeEmbedded {
+			// the fields of an embedded struct are bound at the same level
+			addFieldSchemas(schema, field.Fields())
+			continue
+		}
+
+		fschema, required := FieldSchema(field)
+		schema.Properties[field.LName()] = fschema
+
+		if required {
+			schema.Required = append(schema.Required, field.LName())
+		}
+	}
+}
+
+// FieldSchema returns the schema of a field with the rules of its validate (and regexp) tags,
+// and whether it is required
+func FieldSchema(field *controller.FieldMeta) (schema *Schema, required bool) {
+	schema = DataSchema(&field.DataMeta)
+	required = applyRules(schema, field.Rules())
+	return
+}
+
+// applyRules adds the rules the schema can express to it, returning whether the value is required
+func applyRules(schema *Schema, rules validate.Rules) (required bool) {
+	numeric := schema.Type == "integer" || schema.Type == "number"
+
+	for _, rule := range rules {
+		switch rule.Name {
+		case "required":
+			required = true
+		case "min", "max":
+			f, err := strconv.ParseFloat(rule.Param, 64)
+			if err != nil {
+				continue
+			}
+
+			switch {
+			case numeric && rule.Name == "min":
+				schema.Minimum = &f
+			case numeric:
+				schema.Maximum = &f
+			case rule.Name == "min":
+				n := int(f)
+				schema.MinLength = &n
+			default:
+				n := int(f)
+				schema.MaxLength = &n
+			}
+		case "oneof":
+			for _, v := range strings.Fields(rule.Param) {
+				schema.Enum = append(schema.Enum, v)
+			}
+		case "pattern":
+			schema.Pattern = rule.Param
+		case "email":
+			schema.Format = "email"
+		case "url":
+			schema.Format = "uri"
+		}
+	}
+
+	return
+}
+
+// TypeSchema returns the schema of the JSON encoding of the type (e.g. of the data of a JSON view),
+// named structs are added to the components of the generator and referred to
+func (g *Generator) TypeSchema(t reflect.Type) *Schema {
+	nullable := false
+	for t.Kind() == reflect.Ptr {
+		t, nullable = t.Elem(), true
+	}
+
+	schema := g.typeSchema(t)
+	if nullable && schema.Ref == "" {
+		schema.Nullable = true
+	}
+
+	return schema
+}
+
+func (g *Generator) typeSchema(t reflect.Type) *Schema {
+	switch t {
+	case typeTime:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typeDuration:
+		return &Schema{Type: "integer", Format: "int64", Description: "a duration in nanoseconds"}
+	case typeRawMessage:
+		return &Schema{}
+	}
+
+	if t.Implements(typeJSONMarshaler) || reflect.PtrTo(t).Implements(typeJSONMarshaler) {
+		// its encoding cannot be known
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 && t.Kind() == reflect.Slice {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.TypeSchema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.TypeSchema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	}
+
+	// interfaces, which can be anything
+	return &Schema{}
+}
+
+// component returns the name of the named struct in the components, adding it if it is not there yet
+func (g *Generator) component(t reflect.Type) string {
+	if name, exists := g.types[t]; exists {
+		return name
+	}
+
+	name := t.Name()
+	if _, exists := g.schemas[name]; exists {
+		// another type of the same name in another package
+		name = strings.Replace(t.String(), ".", "_", -1)
+	}
+
+	// the name is taken before the schema is made, for types referring to themselves
+	g.types[t] = name
+	g.schemas[name] = nil
+	g.schemas[name] = g.structSchema(t)
+
+	return name
+}
+
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	g.addStructFields(schema, t)
+	return schema
+}
+
+// addStructFields adds the fields of the struct the way encoding/json encodes them
+func (g *Generator) addStructFields(schema *Schema, t reflect.Type) {
+	for i, numfield := 0, t.NumField(); i < numfield; i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if comma := strings.Index(tag, ","); comma >= 0 {
+			name, opts = tag[:comma], tag[comma+1:]
+		}
+
+		ftype := field.Type
+		if ftype.Kind() == reflect.Ptr {
+			ftype = ftype.Elem()
+		}
+
+		if field.Anonymous && name == "" && ftype.Kind() == reflect.Struct {
+			g.addStructFields(schema, ftype)
+			continue
+		} else if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		fschema := g.TypeSchema(field.Type)
+		if strings.Contains(","+opts+",", ",string,") {
+			fschema = &Schema{Type: "string"}
+		}
+
+		schema.Properties[name] = fschema
+
+		if !strings.Contains(","+opts+",", ",omitempty,") && field.Type.Kind() != reflect.Ptr {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package openapi
+
+import "github.com/zaolab/sunnified/mvc/view"
+
+// JSONToYAML converts the JSON to YAML, in the same order as its keys
+func JSONToYAML(b []byte) ([]byte, error) {
+	return view.JSONToYAML(b)
+}
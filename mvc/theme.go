@@ -0,0 +1,179 @@
+package mvc
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// ThemesResourceName is the name of the context resource holding the Themes of the request,
+// which is set by the app serving it (see SunnyApp.SetThemes)
+const ThemesResourceName = "mvc.themes"
+
+// DefaultThemes is used for requests without a Themes of their own,
+// i.e. the templates of themes/default/tmpl/{module}/{controller}/{action}{ext}
+var DefaultThemes = NewThemes("themes", "default")
+
+func NewThemes(root, theme string, fallback ...string) *Themes {
+	return &Themes{
+		Root:      root,
+		Theme:     theme,
+		Fallback:  fallback,
+		TmplDir:   "tmpl",
+		ShareDir:  "_share_",
+		LayoutDir: "_layout_",
+	}
+}
+
+// Themes finds the templates of the theme of a request, e.g. themes/{theme}/tmpl/{module}/{controller}/{action}.html;
+// a template the theme does not have is taken from the first theme of the fallback chain which has it
+type Themes struct {
+	// Root is the directory of the themes
+	Root string
+	// FS is the filesystem the themes are read from (Root being a directory of it), e.g. an embed.FS
+	// or a util.OverlayFS of one; the OS filesystem is used if it is nil
+	FS fs.FS
+	// Theme is the active theme, used unless Resolve selects another one
+	Theme string
+	// Fallback are the themes after Theme to look for a template in, e.g. tenant-x falling back to default
+	Fallback []string
+	// TmplDir is the directory of the templates in a theme
+	TmplDir string
+	// ShareDir is the directory of the partials parsed together with every template, under TmplDir
+	ShareDir string
+	// LayoutDir is the directory of the layouts, under TmplDir
+	LayoutDir string
+	// Layouts are the default layouts of the modules, the one of module "" is the default of all modules
+	Layouts map[string]string
+	// Resolve selects the theme of a request, e.g. by its host, a cookie or the preference of the user;
+	// Theme is used if it returns an empty string or a name that is not a theme
+	Resolve func(*web.Context) string
+}
+
+// GetThemes returns the Themes of the request, DefaultThemes if it has none
+func GetThemes(ctxt *web.Context) *Themes {
+	if ctxt != nil {
+		if th, ok := ctxt.Resource(ThemesResourceName).(*Themes); ok && th != nil {
+			return th
+		}
+	}
+	return DefaultThemes
+}
+
+// ThemeOf returns the theme of the request
+func (th *Themes) ThemeOf(ctxt *web.Context) string {
+	if th.Resolve != nil && ctxt != nil {
+		if theme := th.Resolve(ctxt); theme != "" && th.IsTheme(theme) {
+			return theme
+		}
+	}
+	return th.Theme
+}
+
+// IsTheme returns whether the name is a directory under Root,
+// names which are not a single path element (e.g. ../x from a cookie) are never a theme
+func (th *Themes) IsTheme(name string) bool {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return false
+	}
+
+	var fi fs.FileInfo
+	var err error
+
+	if th.FS == nil {
+		fi, err = os.Stat(filepath.Join(th.Root, name))
+	} else {
+		fi, err = fs.Stat(th.FS, path.Join(th.Root, name))
+	}
+
+	return err == nil && fi.IsDir()
+}
+
+// Chain returns the themes a template of the request is looked for in, in order
+func (th *Themes) Chain(ctxt *web.Context) []string {
+	return th.chainOf(th.ThemeOf(ctxt))
+}
+
+// chainOf returns the theme followed by Theme and the fallbacks, each once
+func (th *Themes) chainOf(theme string) (chain []string) {
+	seen := make(map[string]bool)
+
+	for _, theme := range append([]string{theme, th.Theme}, th.Fallback...) {
+		if theme != "" && !seen[theme] {
+			seen[theme] = true
+			chain = append(chain, theme)
+		}
+	}
+
+	return
+}
+
+// TmplPath returns the path of a template of the theme, e.g. themes/default/tmpl/users/list/_.html
+func (th *Themes) TmplPath(theme string, names Meta, ext string) string {
+	return path.Join(th.Root, theme, th.TmplDir, names[MVCModule], names[MVCController], names[MVCAction]+ext)
+}
+
+// SharePath returns the pattern of the partials of the theme with the extension
+func (th *Themes) SharePath(theme, ext string) string {
+	return path.Join(th.Root, theme, th.TmplDir, th.ShareDir, "*"+ext)
+}
+
+// Find returns the path of the template of the request, taken from the first theme of the chain which has it
+// (the path in the theme of the request if none has it), and the patterns of the partials of the chain
+// from the last theme to the first, so that a partial of a theme replaces the one of the same name of its fallback
+func (th *Themes) Find(ctxt *web.Context, names Meta, ext string) (p string, shares []string) {
+	return th.findIn(th.Chain(ctxt), names, ext)
+}
+
+func (th *Themes) findIn(chain []string, names Meta, ext string) (p string, shares []string) {
+	for _, theme := range chain {
+		if tp := th.TmplPath(theme, names, ext); p == "" && fileExists(th.FS, tp) {
+			p = tp
+		}
+	}
+
+	if p == "" && len(chain) > 0 {
+		p = th.TmplPath(chain[0], names, ext)
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		shares = append(shares, th.SharePath(chain[i], ext))
+	}
+
+	return
+}
+
+// ThemeByHost returns a Resolve selecting the theme by the host of the request (without its port)
+func ThemeByHost(hosts map[string]string) func(*web.Context) string {
+	return func(ctxt *web.Context) string {
+		host := ctxt.Request.Host
+		if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+			host = host[:i]
+		}
+		return hosts[strings.ToLower(host)]
+	}
+}
+
+// ThemeByCookie returns a Resolve selecting the theme named by the cookie
+func ThemeByCookie(name string) func(*web.Context) string {
+	return func(ctxt *web.Context) string {
+		return ctxt.CookieValue(name)
+	}
+}
+
+// ThemeByFunc returns a Resolve trying each of the resolvers in order,
+// e.g. the preference of the user before the host
+func ThemeByFunc(resolvers ...func(*web.Context) string) func(*web.Context) string {
+	return func(ctxt *web.Context) string {
+		for _, resolve := range resolvers {
+			if theme := resolve(ctxt); theme != "" {
+				return theme
+			}
+		}
+		return ""
+	}
+}
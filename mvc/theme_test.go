@@ -0,0 +1,95 @@
+package mvc
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func writeThemeFile(t *testing.T, root, p, content string) {
+	fp := filepath.Join(root, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(fp), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestThemes(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.html", `{{template "title"}} list`)
+	writeThemeFile(t, root, "default/tmpl/_share_/title.html", `{{define "title"}}default{{end}}`)
+	writeThemeFile(t, root, "tenant-x/tmpl/shop/cart/show.html", `x show`)
+	writeThemeFile(t, root, "tenant-x/tmpl/_share_/title.html", `{{define "title"}}x{{end}}`)
+
+	th := NewThemes(root, "default")
+	th.Resolve = ThemeByCookie("theme")
+
+	var (
+		names = Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}
+		r     = httptest.NewRequest("GET", "/shop/cart/list", nil)
+	)
+	r.AddCookie(&http.Cookie{Name: "theme", Value: "tenant-x"})
+	ctxt := web.NewContext(httptest.NewRecorder(), r)
+	ctxt.SetResource(ThemesResourceName, th)
+
+	if chain := th.Chain(ctxt); len(chain) != 2 || chain[0] != "tenant-x" || chain[1] != "default" {
+		t.Errorf("chain: %v", chain)
+	}
+
+	// list falls back to default, with the partials of tenant-x replacing those of default
+	tmpl, err := GetThemeHTMLTmpl(ctxt, names, ".html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, nil); err != nil || buf.String() != "x list" {
+		t.Errorf("got %q %v", buf.String(), err)
+	}
+
+	names[MVCAction] = "show"
+	if p, _ := th.Find(ctxt, names, ".html"); p != filepath.ToSlash(filepath.Join(root, "tenant-x/tmpl/shop/cart/show.html")) {
+		t.Errorf("path of show: %s", p)
+	}
+
+	for _, name := range []string{"../default", "missing", ".."} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: "theme", Value: name})
+		if theme := th.ThemeOf(web.NewContext(httptest.NewRecorder(), r)); theme != "default" {
+			t.Errorf("theme of %q: %s", name, theme)
+		}
+	}
+}
+
+func TestThemesFS(t *testing.T) {
+	th := NewThemes("themes", "default")
+	th.FS = fstest.MapFS{
+		"themes/default/tmpl/shop/cart/list.html": &fstest.MapFile{Data: []byte(`{{template "title"}} list`)},
+		"themes/default/tmpl/_share_/title.html":  &fstest.MapFile{Data: []byte(`{{define "title"}}embedded{{end}}`)},
+	}
+
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/shop/cart/list", nil))
+	ctxt.SetResource(ThemesResourceName, th)
+
+	tmpl, err := GetThemeHTMLTmpl(ctxt, Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}, ".html", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, nil); err != nil || buf.String() != "embedded list" {
+		t.Errorf("got %q %v", buf.String(), err)
+	}
+
+	if !th.IsTheme("default") || th.IsTheme("tenant-x") {
+		t.Error("themes of the filesystem")
+	}
+}
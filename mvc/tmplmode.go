@@ -0,0 +1,345 @@
+package mvc
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TmplMode is how the parsed templates of a Themes are cached, see Themes.SetMode
+type TmplMode int32
+
+const (
+	// TmplCacheMode expires a cached template CacheDuration after it was last used (the default)
+	TmplCacheMode TmplMode = iota
+	// TmplProductionMode keeps the parsed templates for the life of the process,
+	// all the templates being parsed at startup with Themes.ParseAll
+	TmplProductionMode
+	// TmplDevelopmentMode keeps the parsed templates until a file they are parsed from changes,
+	// which a TmplWatcher (see Themes.Watch) looks for
+	TmplDevelopmentMode
+)
+
+// tmplroot is the directory of the themes of a filesystem
+type tmplroot struct {
+	fsys fs.FS
+	root string
+}
+
+// contains returns whether the template is under the directory
+func (tr tmplroot) contains(fsys fs.FS, p string) bool {
+	p = cleanTmplPath(p)
+	return tr.fsys == fsys && (tr.root == "." || p == tr.root || strings.HasPrefix(p, tr.root+"/"))
+}
+
+var (
+	tmplmodes = make(map[tmplroot]TmplMode)
+	mmutex    = sync.RWMutex{}
+)
+
+// ErrTmplNotExist is the error of a template which is in none of the themes
+var ErrTmplNotExist = errors.New("mvc: template does not exist")
+
+// SetMode sets how the parsed templates of the themes are cached, removing those cached before;
+// the mode is of the templates under Root of FS (so FS is set first), which Themes of the same directory share
+func (th *Themes) SetMode(mode TmplMode) {
+	tr := tmplroot{th.FS, cleanTmplPath(th.Root)}
+	// the templates of a filesystem which is not comparable are never cached
+	if !(htkey{fsys: th.FS}).cacheable() {
+		return
+	}
+
+	mmutex.Lock()
+	if mode == TmplCacheMode {
+		delete(tmplmodes, tr)
+	} else {
+		tmplmodes[tr] = mode
+	}
+	mmutex.Unlock()
+
+	clearTmplCacheOf(tr)
+}
+
+// Mode returns how the parsed templates of the themes are cached
+func (th *Themes) Mode() TmplMode {
+	return tmplModeOf(th.FS, th.Root)
+}
+
+// tmplModeOf returns the mode of the themes the template is under, TmplCacheMode if it is under none
+func tmplModeOf(fsys fs.FS, p string) TmplMode {
+	if !(htkey{fsys: fsys}).cacheable() {
+		return TmplCacheMode
+	}
+
+	mmutex.RLock()
+	defer mmutex.RUnlock()
+
+	for tr, mode := range tmplmodes {
+		if tr.contains(fsys, p) {
+			return mode
+		}
+	}
+	return TmplCacheMode
+}
+
+// ParseAll parses every template of the themes as a request would, caching them: an action template
+// by the engine of its extension with the partials of the chain, through the default layout of its module
+// if it is an html template; a partial or a layout is also parsed alone. The templates are those of the chain
+// of Theme and, if Resolve can select another theme, of the chains of every theme under Root.
+// It returns the errors of the templates which cannot be parsed, used at startup in TmplProductionMode
+// so that a broken template stops the app instead of a request; the layouts of controllers (see Layouter)
+// are only parsed by a request or a check (see CheckTmpl).
+func (th *Themes) ParseAll() error {
+	var (
+		errs     []error
+		reported = make(map[string]bool)
+	)
+
+	report := func(p string, err error) {
+		if err != nil && !reported[err.Error()] {
+			reported[err.Error()] = true
+			errs = append(errs, fmt.Errorf("mvc: %s: %w", p, err))
+		}
+	}
+
+	for _, theme := range th.resolvable() {
+		chain := th.chainOf(theme)
+		seen := make(map[string]bool)
+
+		for _, theme := range chain {
+			dir := path.Join(th.Root, theme, th.TmplDir)
+
+			walkTmplFiles(th.FS, dir, func(p string, _ fs.FileInfo) {
+				rel := strings.TrimPrefix(p, dir+"/")
+
+				if sub := strings.SplitN(rel, "/", 2)[0]; sub == th.ShareDir || sub == th.LayoutDir {
+					b, err := readFile(th.FS, p)
+					if err == nil {
+						_, err = template.New(rel).Funcs(GetFuncMap()).Parse(string(b))
+					}
+					report(p, err)
+					return
+				}
+
+				// the template of a fallback is only used if the themes before it do not have it
+				if !seen[rel] {
+					seen[rel] = true
+					report(p, th.parseTmpl(chain, rel, p))
+				}
+			})
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolvable returns the themes a request can be served from, Theme and every theme under Root if Resolve is set
+func (th *Themes) resolvable() []string {
+	themes := []string{th.Theme}
+	if th.Resolve == nil {
+		return themes
+	}
+
+	var entries []fs.DirEntry
+	if th.FS == nil {
+		entries, _ = os.ReadDir(filepath.FromSlash(th.Root))
+	} else {
+		entries, _ = fs.ReadDir(th.FS, th.Root)
+	}
+
+	for _, entry := range entries {
+		if name := entry.Name(); name != th.Theme && th.IsTheme(name) {
+			themes = append(themes, name)
+		}
+	}
+
+	return themes
+}
+
+// parseTmpl parses the template of the path, rel being its path in the theme, as a request of the chain would;
+// a template which is not of an action ({module}/{controller}/{action}{ext}) is parsed without a layout
+func (th *Themes) parseTmpl(chain []string, rel, p string) error {
+	ext := path.Ext(p)
+	engine := GetViewEngine(ext)
+
+	var (
+		names    Meta
+		isaction = true
+	)
+	switch parts := strings.Split(strings.TrimSuffix(rel, ext), "/"); len(parts) {
+	case 3:
+		names = Meta{MVCModule: parts[0], MVCController: parts[1], MVCAction: parts[2]}
+	case 2:
+		names = Meta{MVCController: parts[0], MVCAction: parts[1]}
+	default:
+		isaction = false
+	}
+
+	if isaction && isHTMLEngine(engine) {
+		_, err := th.layoutHTMLTmpl(chain, names, ext, "", nil)
+		return err
+	}
+
+	var shares []string
+	for i := len(chain) - 1; i >= 0; i-- {
+		shares = append(shares, th.SharePath(chain[i], ext))
+	}
+
+	_, err := engine.Parse(th.FS, p, shares, nil)
+	return err
+}
+
+// CheckTmpl parses the template of the action in the theme and its fallbacks by the engine of the extension,
+// through the layout (the default layout of the module if it is empty) as a request would, and returns its path;
+// the error is ErrTmplNotExist if no theme has the template
+func (th *Themes) CheckTmpl(names Meta, ext, layout string) (p string, err error) {
+	var shares []string
+	if p, shares = th.Find(nil, names, ext); !fileExists(th.FS, p) {
+		return p, ErrTmplNotExist
+	}
+
+	if engine := GetViewEngine(ext); !isHTMLEngine(engine) {
+		_, err = engine.Parse(th.FS, p, shares, nil)
+		return
+	}
+
+	if layout == "" {
+		layout = th.LayoutOf(names[MVCModule])
+	}
+
+	var layouts []string
+	if layouts, err = th.FindLayouts(nil, layout, ext); err != nil {
+		return
+	}
+
+	if len(layouts) == 0 {
+		_, err = parseHTMLTmpl(th.FS, p, shares)
+	} else {
+		_, err = parseLayoutHTMLTmpl(th.FS, p, shares, layouts)
+	}
+
+	return
+}
+
+// Watch returns a TmplWatcher of the templates of the themes
+func (th *Themes) Watch(interval time.Duration) *TmplWatcher {
+	return WatchTmpl(th.FS, interval, th.Root)
+}
+
+// TmplWatcher polls directories of templates for files which are added, changed or removed,
+// removing the cached templates parsed from them (see InvalidateTmpl)
+type TmplWatcher struct {
+	fsys  fs.FS
+	dirs  []string
+	files map[string]tmplStamp
+	mutex sync.Mutex
+	stop  chan struct{}
+	once  sync.Once
+}
+
+type tmplStamp struct {
+	modtime time.Time
+	size    int64
+}
+
+// WatchTmpl returns a TmplWatcher polling the directories of fsys (nil for the OS filesystem) every interval,
+// or only when Check is called if interval is 0; the directories must be given as the templates are,
+// e.g. "themes" for the templates of themes/default/tmpl
+func WatchTmpl(fsys fs.FS, interval time.Duration, dirs ...string) *TmplWatcher {
+	w := &TmplWatcher{
+		fsys: fsys,
+		dirs: dirs,
+		stop: make(chan struct{}),
+	}
+	w.files = w.scan()
+
+	if interval > 0 {
+		go func() {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					w.Check()
+				case <-w.stop:
+					return
+				}
+			}
+		}()
+	}
+
+	return w
+}
+
+// Check looks for the files changed since the last check, removing the templates parsed from them,
+// and returns the changed files
+func (w *TmplWatcher) Check() (changed []string) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	files := w.scan()
+
+	for p, stamp := range files {
+		if old, exists := w.files[p]; !exists || !old.modtime.Equal(stamp.modtime) || old.size != stamp.size {
+			changed = append(changed, p)
+		}
+	}
+
+	for p := range w.files {
+		if _, exists := files[p]; !exists {
+			changed = append(changed, p)
+		}
+	}
+
+	w.files = files
+
+	for _, p := range changed {
+		InvalidateTmpl(w.fsys, p)
+	}
+
+	return
+}
+
+// Stop stops the polling of the watcher
+func (w *TmplWatcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+}
+
+func (w *TmplWatcher) scan() map[string]tmplStamp {
+	files := make(map[string]tmplStamp)
+
+	for _, dir := range w.dirs {
+		walkTmplFiles(w.fsys, dir, func(p string, fi fs.FileInfo) {
+			files[p] = tmplStamp{fi.ModTime(), fi.Size()}
+		})
+	}
+
+	return files
+}
+
+// walkTmplFiles calls fn with the slash separated path of each file under the directory of fsys
+// (nil for the OS filesystem), skipping the files which cannot be read
+func walkTmplFiles(fsys fs.FS, dir string, fn func(p string, fi fs.FileInfo)) {
+	walk := func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if fi, err := d.Info(); err == nil {
+				fn(cleanTmplPath(p), fi)
+			}
+		}
+		return nil
+	}
+
+	if fsys == nil {
+		filepath.WalkDir(filepath.FromSlash(dir), walk)
+	} else {
+		fs.WalkDir(fsys, dir, walk)
+	}
+}
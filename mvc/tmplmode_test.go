@@ -0,0 +1,119 @@
+package mvc
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func TestTmplModes(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.html", `{{template "title"}} list`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/show.html", `show`)
+	writeThemeFile(t, root, "default/tmpl/_share_/title.html", `{{define "title"}}old{{end}}`)
+
+	th := NewThemes(root, "default")
+	th.SetMode(TmplDevelopmentMode)
+	defer th.SetMode(TmplCacheMode)
+	ctxt := web.NewContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/shop/cart/list", nil))
+	ctxt.SetResource(ThemesResourceName, th)
+
+	if err := th.ParseAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	render := func(action string) string {
+		tmpl, err := GetThemeHTMLTmpl(ctxt, Meta{MVCModule: "shop", MVCController: "cart", MVCAction: action}, ".html", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	if s := render("list"); s != "old list" {
+		t.Fatalf("got %q", s)
+	}
+
+	w := th.Watch(0)
+	defer w.Stop()
+
+	writeThemeFile(t, root, "default/tmpl/_share_/title.html", `{{define "title"}}new title{{end}}`)
+
+	if changed := w.Check(); len(changed) != 1 {
+		t.Errorf("changed: %v", changed)
+	}
+
+	if s := render("list"); s != "new title list" {
+		t.Errorf("list after the partial changed: %q", s)
+	}
+
+	writeThemeFile(t, root, "default/tmpl/shop/cart/show.html", `{{if}}`)
+	if err := th.ParseAll(); err == nil {
+		t.Error("expected an error for a broken template")
+	}
+}
+
+func TestTmplModeOfThemes(t *testing.T) {
+	prod, cached := NewThemes(t.TempDir(), "default"), NewThemes(t.TempDir(), "default")
+	prod.SetMode(TmplProductionMode)
+	defer prod.SetMode(TmplCacheMode)
+
+	names := Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}
+
+	for _, th := range []*Themes{prod, cached} {
+		writeThemeFile(t, th.Root, "default/tmpl/shop/cart/list.html", `list`)
+		if err := th.ParseAll(); err != nil {
+			t.Fatal(err)
+		}
+
+		p, shares := th.Find(nil, names, ".html")
+		hmutex.RLock()
+		details := htmplcache[newHTKey(nil, append([]string{p}, shares...)...)]
+		hmutex.RUnlock()
+
+		if details == nil {
+			t.Fatalf("%s: the template is not cached", th.Root)
+		} else if expires := details.cdown != nil; expires != (th.Mode() == TmplCacheMode) {
+			t.Errorf("%s: mode %d, cache expiring %v", th.Root, th.Mode(), expires)
+		}
+	}
+}
+
+func TestParseAllThemesAndLayouts(t *testing.T) {
+	root := t.TempDir()
+	writeThemeFile(t, root, "default/tmpl/_layout_/base.html", `{{block "content" .}}{{end}}`)
+	writeThemeFile(t, root, "default/tmpl/shop/cart/list.html", `{{define "content"}}list{{end}}`)
+	writeThemeFile(t, root, "tenant-x/tmpl/shop/cart/show.html", `{{if}}`)
+
+	th := NewThemes(root, "default")
+	th.SetLayout("", "base")
+	th.SetMode(TmplProductionMode)
+	defer th.SetMode(TmplCacheMode)
+
+	// tenant-x is only used through Resolve
+	if err := th.ParseAll(); err != nil {
+		t.Errorf("tenant-x without a Resolve: %v", err)
+	}
+
+	th.Resolve = ThemeByCookie("theme")
+	if err := th.ParseAll(); err == nil || !strings.Contains(err.Error(), "tenant-x") {
+		t.Errorf("got %v", err)
+	}
+
+	// the action template is cached through the layout, as a request would get it
+	names := Meta{MVCModule: "shop", MVCController: "cart", MVCAction: "list"}
+	p, shares := th.Find(nil, names, ".html")
+	layouts, _ := th.FindLayouts(nil, "base", ".html")
+
+	if getHTMLCache(newHTKey(nil, append(append([]string{p}, shares...), layouts...)...)) == nil {
+		t.Error("the template is not cached with its layout")
+	}
+}
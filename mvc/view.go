@@ -1,11 +1,15 @@
 package mvc
 
 import (
-	"fmt"
 	"html/template"
+	"io/fs"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"regexp"
+	"strings"
 	"sync"
 	txtemplate "text/template"
 	"time"
@@ -15,9 +19,9 @@ import (
 )
 
 var (
-	htmplcache    = make(map[string]*htcachedetails)
+	htmplcache    = make(map[htkey]*htcachedetails)
 	hmutex        = sync.RWMutex{}
-	ttmplcache    = make(map[string]*ttcachedetails)
+	ttmplcache    = make(map[htkey]*ttcachedetails)
 	tmutex        = sync.RWMutex{}
 	CacheDuration = time.Minute * 1 / 60
 	fmutex        = sync.RWMutex{}
@@ -52,8 +56,9 @@ func emptyf(s ...interface{}) string {
 	return ""
 }
 
+// GetTemplateRelPath returns the path of the template of the action in the active theme of DefaultThemes
 func GetTemplateRelPath(names Meta, ext string) string {
-	return fmt.Sprintf("themes/default/tmpl/%s/%s/%s%s", names[MVCModule], names[MVCController], names[MVCAction], ext)
+	return DefaultThemes.TmplPath(DefaultThemes.Theme, names, ext)
 }
 
 type View interface {
@@ -83,6 +88,56 @@ type DataView interface {
 	SetData(string, interface{})
 }
 
+// htkey is the key of a cached template, the filesystem and the files it is parsed from;
+// the templates of a filesystem which is not comparable (e.g. an fstest.MapFS) are not cached
+type htkey struct {
+	fsys  fs.FS
+	files string
+}
+
+func newHTKey(fsys fs.FS, files ...string) htkey {
+	return htkey{fsys, strings.Join(files, "|")}
+}
+
+// dependsOn returns whether the template is parsed from the file of the filesystem,
+// the file being one of its files or matching the pattern of its partials
+func (key htkey) dependsOn(fsys fs.FS, file string) bool {
+	return key.fsys == fsys && filesDependOn(strings.Split(key.files, "|"), file)
+}
+
+func filesDependOn(files []string, file string) bool {
+	file = cleanTmplPath(file)
+
+	for _, f := range files {
+		f = cleanTmplPath(f)
+		if f == file {
+			return true
+		} else if matched, _ := path.Match(f, file); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+func cleanTmplPath(p string) string {
+	return path.Clean(filepath.ToSlash(p))
+}
+
+func (key htkey) cacheable() bool {
+	return key.fsys == nil || reflect.TypeOf(key.fsys).Comparable()
+}
+
+// file returns the path of the template, the first of its files
+func (key htkey) file() string {
+	return strings.SplitN(key.files, "|", 2)[0]
+}
+
+// mode returns how the template is cached, the mode of the themes of its file
+func (key htkey) mode() TmplMode {
+	return tmplModeOf(key.fsys, key.file())
+}
+
 type htcachedetails struct {
 	cdown *time.Timer
 	t     *template.Template
@@ -93,8 +148,50 @@ type ttcachedetails struct {
 	t     *txtemplate.Template
 }
 
+// GetHTMLTmpl returns the template of the path parsed with the partials of the active theme of DefaultThemes
 func GetHTMLTmpl(p string, fmap template.FuncMap) (t *template.Template, err error) {
-	if t = getHTMLCache(p); t == nil {
+	return getHTMLTmpl(nil, p, []string{DefaultThemes.SharePath(DefaultThemes.Theme, filepath.Ext(p))}, fmap)
+}
+
+// GetHTMLTmplFS is GetHTMLTmpl reading the template and the partials from fsys, e.g. an embed.FS
+func GetHTMLTmplFS(fsys fs.FS, p string, fmap template.FuncMap) (t *template.Template, err error) {
+	return getHTMLTmpl(fsys, p, []string{DefaultThemes.SharePath(DefaultThemes.Theme, path.Ext(p))}, fmap)
+}
+
+// GetThemeHTMLTmpl returns the template of the action in the theme of the request, see Themes.Find
+func GetThemeHTMLTmpl(ctxt *web.Context, names Meta, ext string, fmap template.FuncMap) (t *template.Template, err error) {
+	th := GetThemes(ctxt)
+	p, shares := th.Find(ctxt, names, ext)
+	return getHTMLTmpl(th.FS, p, shares, fmap)
+}
+
+func getHTMLTmpl(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t *template.Template, err error) {
+	if t, err = loadHTMLTmpl(fsys, p, shares, fmap); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// loadHTMLTmpl returns the cached template of the path, parsing it if it is not cached
+func loadHTMLTmpl(fsys fs.FS, p string, shares []string, fmap template.FuncMap) (t *template.Template, err error) {
+	key := newHTKey(fsys, append([]string{p}, shares...)...)
+
+	if t = getHTMLCache(key); t == nil {
+		if t, err = parseHTMLTmpl(fsys, p, shares); err != nil {
+			return nil, err
+		}
+
+		setHTMLCache(key, t)
+		t, _ = t.Clone()
+	}
+
+	t = t.Funcs(fmap)
+	return
+}
+
+// parseHTMLTmpl parses the template of the path with the partials matching the patterns
+func parseHTMLTmpl(fsys fs.FS, p string, shares []string) (t *template.Template, err error) {
+	if fsys == nil {
 		ap, e := filepath.Abs(p)
 		if e != nil {
 			ap = p
@@ -102,18 +199,77 @@ func GetHTMLTmpl(p string, fmap template.FuncMap) (t *template.Template, err err
 		t = template.New(filepath.Base(ap))
 		t = t.Funcs(GetFuncMap())
 		t, err = t.ParseFiles(ap)
+	} else {
+		t = template.New(path.Base(p))
+		t = t.Funcs(GetFuncMap())
+		t, err = t.ParseFS(fsys, p)
+	}
 
-		if err == nil {
-			// TODO: remove hardcoded directory structure
-			sharep, e := filepath.Abs("themes/default/tmpl/_share_/*" + filepath.Ext(ap))
-			if e == nil {
-				t.ParseGlob(sharep)
+	if err != nil {
+		return nil, err
+	}
+
+	parseShares(t, fsys, shares)
+	return t, nil
+}
+
+// parseShares parses the partials matching the patterns into the template, a pattern matching none is skipped
+func parseShares(t *template.Template, fsys fs.FS, shares []string) {
+	for _, share := range shares {
+		if fsys != nil {
+			if matches, e := fs.Glob(fsys, share); e == nil && len(matches) > 0 {
+				t.ParseFS(fsys, share)
 			}
-		} else {
-			panic(err)
+		} else if sharep, e := filepath.Abs(share); e == nil {
+			t.ParseGlob(sharep)
 		}
+	}
+}
+
+// fileExists returns whether the path is a file of fsys, or of the OS filesystem if fsys is nil
+func fileExists(fsys fs.FS, p string) bool {
+	var fi fs.FileInfo
+	var err error
+
+	if fsys == nil {
+		fi, err = os.Stat(p)
+	} else {
+		fi, err = fs.Stat(fsys, p)
+	}
+
+	return err == nil && !fi.IsDir()
+}
+
+// readFile reads the file of fsys, or of the OS filesystem if fsys is nil
+func readFile(fsys fs.FS, p string) ([]byte, error) {
+	if fsys == nil {
+		return os.ReadFile(p)
+	}
+	return fs.ReadFile(fsys, p)
+}
+
+// GetTextTmpl returns the template of the path parsed with the partials of the active theme of DefaultThemes
+func GetTextTmpl(p string, fmap txtemplate.FuncMap) (t *txtemplate.Template, err error) {
+	return getTextTmpl(nil, p, []string{DefaultThemes.SharePath(DefaultThemes.Theme, filepath.Ext(p))}, fmap)
+}
 
-		setHTMLCache(p, t)
+func getTextTmpl(fsys fs.FS, p string, shares []string, fmap txtemplate.FuncMap) (t *txtemplate.Template, err error) {
+	if t, err = loadTextTmpl(fsys, p, shares, fmap); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// loadTextTmpl returns the cached text template of the path, parsing it if it is not cached
+func loadTextTmpl(fsys fs.FS, p string, shares []string, fmap txtemplate.FuncMap) (t *txtemplate.Template, err error) {
+	key := newHTKey(fsys, append([]string{p}, shares...)...)
+
+	if t = getTextCache(key); t == nil {
+		if t, err = parseTextTmpl(fsys, p, shares); err != nil {
+			return nil, err
+		}
+
+		setTextCache(key, t)
 		t, _ = t.Clone()
 	}
 
@@ -121,8 +277,9 @@ func GetHTMLTmpl(p string, fmap template.FuncMap) (t *template.Template, err err
 	return
 }
 
-func GetTextTmpl(p string, fmap txtemplate.FuncMap) (t *txtemplate.Template, err error) {
-	if t = getTextCache(p); t == nil {
+// parseTextTmpl parses the text template of the path with the partials matching the patterns
+func parseTextTmpl(fsys fs.FS, p string, shares []string) (t *txtemplate.Template, err error) {
+	if fsys == nil {
 		ap, e := filepath.Abs(p)
 		if e != nil {
 			ap = p
@@ -130,91 +287,191 @@ func GetTextTmpl(p string, fmap txtemplate.FuncMap) (t *txtemplate.Template, err
 		t = txtemplate.New(filepath.Base(ap))
 		t = t.Funcs(GetTextFuncMap())
 		t, err = t.ParseFiles(ap)
+	} else {
+		t = txtemplate.New(path.Base(p))
+		t = t.Funcs(GetTextFuncMap())
+		t, err = t.ParseFS(fsys, p)
+	}
+
+	if err != nil {
+		return nil, err
+	}
 
-		if err == nil {
-			// TODO: remove hardcoded directory structure
-			sharep, e := filepath.Abs("themes/default/tmpl/_share_/*" + filepath.Ext(ap))
-			if e == nil {
-				t.ParseGlob(sharep)
+	for _, share := range shares {
+		if fsys != nil {
+			if matches, e := fs.Glob(fsys, share); e == nil && len(matches) > 0 {
+				t.ParseFS(fsys, share)
 			}
-		} else {
-			panic(err)
+		} else if sharep, e := filepath.Abs(share); e == nil {
+			t.ParseGlob(sharep)
 		}
-
-		setTextCache(p, t)
-		t, _ = t.Clone()
 	}
 
-	t = t.Funcs(fmap)
-	return
+	return t, nil
 }
 
-func getHTMLCache(p string) *template.Template {
+func getHTMLCache(p htkey) *template.Template {
+	if !p.cacheable() {
+		return nil
+	}
+
 	hmutex.RLock()
 	defer hmutex.RUnlock()
 	if _, ok := htmplcache[p]; ok {
-		htmplcache[p].cdown.Reset(CacheDuration)
+		if htmplcache[p].cdown != nil {
+			htmplcache[p].cdown.Reset(CacheDuration)
+		}
 		t, _ := htmplcache[p].t.Clone()
 		return t
 	}
 	return nil
 }
 
-func setHTMLCache(p string, t *template.Template) {
+func setHTMLCache(p htkey, t *template.Template) {
+	if !p.cacheable() {
+		return
+	}
+
 	hmutex.Lock()
 	defer hmutex.Unlock()
 	if _, ok := htmplcache[p]; ok {
-		htmplcache[p].cdown.Stop()
+		stopCacheTimer(htmplcache[p].cdown)
 		htmplcache[p].t = nil
 	}
 
-	timer := time.AfterFunc(CacheDuration, func() { delHTMLCache(p) })
+	var timer *time.Timer
+	if p.mode() == TmplCacheMode {
+		timer = time.AfterFunc(CacheDuration, func() { delHTMLCache(p) })
+	}
 	htmplcache[p] = &htcachedetails{timer, t}
 }
 
-func delHTMLCache(p string) {
+func delHTMLCache(p htkey) {
 	hmutex.Lock()
 	defer hmutex.Unlock()
 	if _, ok := htmplcache[p]; ok {
-		htmplcache[p].cdown.Stop()
+		stopCacheTimer(htmplcache[p].cdown)
 		htmplcache[p].t = nil
 		delete(htmplcache, p)
 	}
 }
 
-func getTextCache(p string) *txtemplate.Template {
+func getTextCache(p htkey) *txtemplate.Template {
+	if !p.cacheable() {
+		return nil
+	}
+
 	tmutex.RLock()
 	defer tmutex.RUnlock()
 	if _, ok := ttmplcache[p]; ok {
-		ttmplcache[p].cdown.Reset(CacheDuration)
+		if ttmplcache[p].cdown != nil {
+			ttmplcache[p].cdown.Reset(CacheDuration)
+		}
 		t, _ := ttmplcache[p].t.Clone()
 		return t
 	}
 	return nil
 }
 
-func setTextCache(p string, t *txtemplate.Template) {
+func setTextCache(p htkey, t *txtemplate.Template) {
+	if !p.cacheable() {
+		return
+	}
+
 	tmutex.Lock()
 	defer tmutex.Unlock()
 	if _, ok := ttmplcache[p]; ok {
-		ttmplcache[p].cdown.Stop()
+		stopCacheTimer(ttmplcache[p].cdown)
 		ttmplcache[p].t = nil
 	}
 
-	timer := time.AfterFunc(CacheDuration, func() { delTextCache(p) })
+	var timer *time.Timer
+	if p.mode() == TmplCacheMode {
+		timer = time.AfterFunc(CacheDuration, func() { delTextCache(p) })
+	}
 	ttmplcache[p] = &ttcachedetails{timer, t}
 }
 
-func delTextCache(p string) {
+func delTextCache(p htkey) {
 	tmutex.Lock()
 	defer tmutex.Unlock()
 	if _, ok := ttmplcache[p]; ok {
-		ttmplcache[p].cdown.Stop()
+		stopCacheTimer(ttmplcache[p].cdown)
 		ttmplcache[p].t = nil
 		delete(ttmplcache, p)
 	}
 }
 
+func stopCacheTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// InvalidateTmpl removes the cached templates parsed from the file of fsys (nil for the OS filesystem),
+// i.e. the template of the file and those including it as a partial or a layout; it returns the number removed
+func InvalidateTmpl(fsys fs.FS, file string) (n int) {
+	hmutex.Lock()
+	for key, details := range htmplcache {
+		if key.dependsOn(fsys, file) {
+			stopCacheTimer(details.cdown)
+			delete(htmplcache, key)
+			n++
+		}
+	}
+	hmutex.Unlock()
+
+	tmutex.Lock()
+	for key, details := range ttmplcache {
+		if key.dependsOn(fsys, file) {
+			stopCacheTimer(details.cdown)
+			delete(ttmplcache, key)
+			n++
+		}
+	}
+	tmutex.Unlock()
+
+	return
+}
+
+// clearTmplCacheOf removes the cached templates parsed from a file under the directory
+func clearTmplCacheOf(tr tmplroot) {
+	hmutex.Lock()
+	for key, details := range htmplcache {
+		if tr.contains(key.fsys, key.file()) {
+			stopCacheTimer(details.cdown)
+			delete(htmplcache, key)
+		}
+	}
+	hmutex.Unlock()
+
+	tmutex.Lock()
+	for key, details := range ttmplcache {
+		if tr.contains(key.fsys, key.file()) {
+			stopCacheTimer(details.cdown)
+			delete(ttmplcache, key)
+		}
+	}
+	tmutex.Unlock()
+}
+
+// ClearTmplCache removes all the cached templates
+func ClearTmplCache() {
+	hmutex.Lock()
+	for key, details := range htmplcache {
+		stopCacheTimer(details.cdown)
+		delete(htmplcache, key)
+	}
+	hmutex.Unlock()
+
+	tmutex.Lock()
+	for key, details := range ttmplcache {
+		stopCacheTimer(details.cdown)
+		delete(ttmplcache, key)
+	}
+	tmutex.Unlock()
+}
+
 func URLSafeTrashString(s string) string {
 	s = urlreplchars.ReplaceAllLiteralString(s, "-")
 	s = urldumpchars.ReplaceAllLiteralString(s, "")
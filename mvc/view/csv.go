@@ -0,0 +1,267 @@
+package view
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// CSVView publishes rows as CSV with a header line, e.g. for a report to be opened in a spreadsheet
+type CSVView struct {
+	// Rows is a slice of structs or of maps of string keys (e.g. []mvc.VM), a single struct or map is one row
+	Rows interface{}
+	// Header is the names of the columns in order; if it is empty, the columns are the fields of the structs
+	// in their order (named by the csv or json tag), or the keys of the maps in sorted order
+	Header []string
+	// Filename is the name of the file the response is downloaded as, it is shown inline if it is empty
+	Filename string
+	// Status is the status of the response, 200 if it is 0
+	Status int
+}
+
+func NewCSVView(rows interface{}, header ...string) CSVView {
+	return CSVView{Rows: rows, Header: header}
+}
+
+func (cv CSVView) ContentType(ctxt *web.Context) string {
+	return "text/csv; charset=utf-8"
+}
+
+func (cv CSVView) Render(ctxt *web.Context) ([]byte, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 100))
+	if err := writeCSV(buf, cv.Rows, cv.Header); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (cv CSVView) RenderString(ctxt *web.Context) (string, error) {
+	b, err := cv.Render(ctxt)
+	if err == nil {
+		return string(b), nil
+	}
+	return "", err
+}
+
+func (cv CSVView) Publish(ctxt *web.Context) error {
+	b, err := cv.Render(ctxt)
+	if err != nil {
+		return err
+	}
+
+	if cv.Filename != "" {
+		ctxt.SetHeader("Content-Disposition", contentDisposition("attachment", cv.Filename))
+	}
+
+	return publishEncoded(ctxt, cv.ContentType(ctxt), cv.Status, b)
+}
+
+// encodeCSV writes v as CSV with the columns in their default order, see CSVView
+func encodeCSV(w io.Writer, v interface{}) error {
+	return writeCSV(w, v, nil)
+}
+
+func writeCSV(w io.Writer, rows interface{}, header []string) error {
+	rv := reflect.ValueOf(rows)
+	for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+
+	var records []reflect.Value
+	switch {
+	case !rv.IsValid(), (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && rv.IsNil():
+	case rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			records = append(records, csvRecord(rv.Index(i)))
+		}
+	default:
+		records = []reflect.Value{rv}
+	}
+
+	for _, record := range records {
+		if record.IsValid() && record.Kind() != reflect.Struct &&
+			(record.Kind() != reflect.Map || record.Type().Key().Kind() != reflect.String) {
+			return fmt.Errorf("view: a row of csv is a struct or a map of string keys, not %s", record.Type())
+		}
+	}
+
+	if len(header) == 0 {
+		header = csvHeader(records)
+	}
+
+	cw := csv.NewWriter(w)
+	if len(header) > 0 {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	line := make([]string, len(header))
+	for _, record := range records {
+		var fields map[string]reflect.Value
+		if record.Kind() == reflect.Struct {
+			fields = csvFields(record)
+		}
+
+		for i, name := range header {
+			var value reflect.Value
+			switch record.Kind() {
+			case reflect.Struct:
+				value = fields[name]
+			case reflect.Map:
+				value = record.MapIndex(reflect.ValueOf(name).Convert(record.Type().Key()))
+			}
+			line[i] = csvString(value)
+		}
+
+		if err := cw.Write(line); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvRecord returns the struct or map of a row, an invalid value for a nil row
+func csvRecord(rv reflect.Value) reflect.Value {
+	for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) {
+		if rv.IsNil() {
+			return reflect.Value{}
+		}
+		rv = rv.Elem()
+	}
+	return rv
+}
+
+// csvHeader returns the columns of the first struct row, or the sorted keys of all the map rows
+func csvHeader(records []reflect.Value) (header []string) {
+	for _, record := range records {
+		if record.Kind() == reflect.Struct {
+			for _, field := range csvStructFields(record.Type()) {
+				header = append(header, field.name)
+			}
+			return
+		}
+	}
+
+	keys := map[string]bool{}
+	for _, record := range records {
+		if record.Kind() != reflect.Map {
+			continue
+		}
+
+		for _, key := range record.MapKeys() {
+			if name := key.String(); !keys[name] {
+				keys[name] = true
+				header = append(header, name)
+			}
+		}
+	}
+
+	sort.Strings(header)
+	return
+}
+
+type csvField struct {
+	name  string
+	index []int
+}
+
+// csvStructFields returns the exported fields of the struct type in their order, including those of
+// embedded structs; a field is named by its csv tag, its json tag, or its name, and skipped if it is named -
+func csvStructFields(t reflect.Type) (fields []csvField) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("csv")
+		if tag == "" {
+			tag = f.Tag.Get("json")
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if name == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, sub := range csvStructFields(ft) {
+				sub.index = append([]int{i}, sub.index...)
+				fields = append(fields, sub)
+			}
+			continue
+		} else if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, csvField{name: name, index: []int{i}})
+	}
+
+	return
+}
+
+func csvFields(rv reflect.Value) map[string]reflect.Value {
+	fields := map[string]reflect.Value{}
+
+	for _, field := range csvStructFields(rv.Type()) {
+		if _, exists := fields[field.name]; exists {
+			continue
+		}
+
+		if value, err := rv.FieldByIndexErr(field.index); err == nil {
+			fields[field.name] = value
+		}
+	}
+
+	return fields
+}
+
+// csvString returns the value of a cell, which is empty for nil and the text of an encoding.TextMarshaler
+// (e.g. time.Time in RFC 3339)
+func csvString(rv reflect.Value) string {
+	for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) {
+		if rv.IsNil() {
+			return ""
+		}
+		if rv.Type().Implements(textMarshalerType) {
+			break
+		}
+		rv = rv.Elem()
+	}
+
+	if !rv.IsValid() || !rv.CanInterface() {
+		return ""
+	}
+
+	v := rv.Interface()
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case encoding.TextMarshaler:
+		if b, err := v.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,336 @@
+package view
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+var ErrNoContent = errors.New("view: the download has no content")
+
+// DownloadView streams a download from a reader or a file without reading it into memory,
+// answering range requests (including multiple ranges) and conditional requests by its strong ETag;
+// the content is closed after it is published if it is an io.Closer
+type DownloadView struct {
+	// Content is the content of the download; File is read instead if it is nil
+	Content io.Reader
+	// File is the file of the download, e.g. of an embed.FS, whose stat gives the defaults of the fields below
+	File fs.File
+	// FileName is the name the client saves the download as, in UTF-8
+	FileName string
+	// CType is the content type, by the extension of the file name if it is empty
+	CType string
+	// ModTime is the time the content last changed, for Last-Modified and If-Modified-Since
+	ModTime time.Time
+	// ETag is the strong entity tag of the content (quoted), made of the name, size and time of the file if it is empty,
+	// or of the hash of the content if the time is unknown
+	ETag string
+	// Inline shows the download in the browser (e.g. a PDF) instead of saving it as an attachment
+	Inline bool
+	// Gzip compresses the content of a compressible type on the fly for the clients that accept it,
+	// except for range requests, whose ranges are of the content as it is
+	Gzip bool
+	err  error
+}
+
+// NewDownloadView returns a download of the content with the file name, ranges require an io.ReadSeeker
+func NewDownloadView(content io.Reader, fname string) *DownloadView {
+	return &DownloadView{Content: content, FileName: fname}
+}
+
+// NewDownloadViewFS returns a download of the file of fsys, which is not found if it cannot be opened
+func NewDownloadViewFS(fsys fs.FS, name string) *DownloadView {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return &DownloadView{err: err}
+	}
+	return &DownloadView{File: f}
+}
+
+func (dv *DownloadView) ContentType(ctxt *web.Context) string {
+	if dv.CType != "" {
+		return dv.CType
+	}
+
+	name := dv.FileName
+	if name == "" && dv.File != nil {
+		if stat, err := dv.File.Stat(); err == nil {
+			name = stat.Name()
+		}
+	}
+
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+	return "application/octet-stream"
+}
+
+func (dv *DownloadView) Render(ctxt *web.Context) ([]byte, error) {
+	content, _, err := dv.content()
+	if err != nil {
+		return nil, err
+	}
+	defer dv.close()
+
+	return io.ReadAll(content)
+}
+
+func (dv *DownloadView) RenderString(ctxt *web.Context) (string, error) {
+	b, err := dv.Render(ctxt)
+	if err == nil {
+		return string(b), nil
+	}
+	return "", err
+}
+
+func (dv *DownloadView) Publish(ctxt *web.Context) error {
+	content, stat, err := dv.content()
+	if err != nil {
+		ctxt.SetErrorCode(404)
+		return err
+	}
+	defer dv.close()
+
+	var (
+		name          = dv.FileName
+		modtime       = dv.ModTime
+		size    int64 = -1
+		header        = ctxt.Response.Header()
+	)
+
+	if stat != nil {
+		if name == "" {
+			name = stat.Name()
+		}
+		if modtime.IsZero() {
+			modtime = stat.ModTime()
+		}
+		size = stat.Size()
+	}
+
+	disposition := "attachment"
+	if dv.Inline {
+		disposition = "inline"
+	}
+
+	ctype := dv.ContentType(ctxt)
+	header.Set("Content-Type", ctype)
+	header.Set("Content-Disposition", contentDisposition(disposition, name))
+
+	rs, seekable := content.(io.ReadSeeker)
+	if !seekable {
+		// without seeking there can be neither ranges nor a hash of the content, it is copied as it is
+		header.Set("Accept-Ranges", "none")
+		if size >= 0 {
+			header.Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		if !modtime.IsZero() {
+			header.Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+		}
+		ctxt.Response.WriteHeader(200)
+
+		if ctxt.Method() != "HEAD" {
+			_, err = io.Copy(ctxt.Response, content)
+		}
+		return err
+	}
+
+	etag := dv.ETag
+	if etag == "" {
+		if etag, err = contentETag(rs, name, size, modtime); err != nil {
+			return err
+		}
+	}
+
+	var w http.ResponseWriter = ctxt.Response
+
+	if dv.Gzip && isCompressible(ctype) && ctxt.ReqHeader("Range") == "" && (size < 0 || size >= int64(GetMinGZIPSize())) {
+		header.Add("Vary", "Accept-Encoding")
+
+		if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
+			// the compressed content is another representation, with a tag of its own
+			etag = strings.TrimSuffix(etag, `"`) + `.gz"`
+			header.Set("Content-Encoding", "gzip")
+
+			gw := &gzipDownload{ResponseWriter: w}
+			defer gw.close()
+			w = gw
+		}
+	}
+
+	header.Set("ETag", etag)
+	http.ServeContent(w, ctxt.Request, name, modtime, rs)
+
+	return nil
+}
+
+// content returns the content to be published, with the stat of the file if there is one
+func (dv *DownloadView) content() (content io.Reader, stat fs.FileInfo, err error) {
+	if dv.err != nil {
+		return nil, nil, dv.err
+	}
+
+	if dv.File != nil {
+		if stat, err = dv.File.Stat(); err != nil {
+			return nil, nil, err
+		}
+		if stat.IsDir() {
+			return nil, nil, fs.ErrInvalid
+		}
+	}
+
+	switch {
+	case dv.Content != nil:
+		content = dv.Content
+	case dv.File != nil:
+		content = dv.File
+	default:
+		err = ErrNoContent
+	}
+
+	return
+}
+
+func (dv *DownloadView) close() {
+	if closer, ok := dv.Content.(io.Closer); ok {
+		closer.Close()
+	}
+	if dv.File != nil {
+		dv.File.Close()
+	}
+}
+
+// contentETag returns the strong entity tag of the content, of its name, size and time if they are known,
+// or else of the hash of the content, after which the content is read from the start again
+func contentETag(rs io.ReadSeeker, name string, size int64, modtime time.Time) (string, error) {
+	h := sha256.New()
+
+	if !modtime.IsZero() && size >= 0 {
+		fmt.Fprintf(h, "%d%s%d", size, name, modtime.UnixNano())
+	} else {
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(h, rs); err != nil {
+			return "", err
+		}
+		if _, err := rs.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	}
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`, nil
+}
+
+// contentDisposition returns the Content-Disposition of the file name as in RFC 6266,
+// a filename of ASCII for the clients that do not read the filename* of UTF-8
+func contentDisposition(disposition, name string) string {
+	if name == "" {
+		return disposition
+	}
+
+	var (
+		ascii   strings.Builder
+		isASCII = true
+	)
+
+	for _, r := range name {
+		switch {
+		case r == '"' || r == '\\' || r < 0x20 || r == 0x7f:
+			ascii.WriteByte('_')
+		case r > 0x7e:
+			ascii.WriteByte('_')
+			isASCII = false
+		default:
+			ascii.WriteRune(r)
+		}
+	}
+
+	value := disposition + `; filename="` + ascii.String() + `"`
+	if !isASCII {
+		value += "; filename*=UTF-8''" + encodeRFC5987(name)
+	}
+
+	return value
+}
+
+// encodeRFC5987 percent-encodes the value of an extended parameter, every byte but those of attr-char
+func encodeRFC5987(s string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			sb.WriteByte(c)
+		} else {
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+
+	return sb.String()
+}
+
+// isCompressible returns whether the content type is of text, which gzip compresses well,
+// unlike most other types which are compressed already (e.g. images and archives)
+func isCompressible(ctype string) bool {
+	mediatype, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+
+	if isTextMediaType(mediatype) || strings.HasSuffix(mediatype, "+json") || strings.HasSuffix(mediatype, "+xml") {
+		return true
+	}
+
+	switch mediatype {
+	case "application/javascript", "application/wasm", "application/x-ndjson", "image/bmp", "image/x-icon":
+		return true
+	}
+
+	return false
+}
+
+// gzipDownload compresses what is written of a download, the gzip writer is started by the first write,
+// so that a response without a body (e.g. 304) is left empty; a response other than 200 is not compressed
+type gzipDownload struct {
+	http.ResponseWriter
+	gz    *gzip.Writer
+	plain bool
+}
+
+func (gw *gzipDownload) WriteHeader(code int) {
+	if code != http.StatusOK {
+		gw.plain = true
+		gw.Header().Del("Content-Encoding")
+	}
+	gw.ResponseWriter.WriteHeader(code)
+}
+
+func (gw *gzipDownload) Write(b []byte) (int, error) {
+	if gw.plain {
+		return gw.ResponseWriter.Write(b)
+	}
+	if gw.gz == nil {
+		gw.gz = getGzipWriter(gw.ResponseWriter)
+	}
+	return gw.gz.Write(b)
+}
+
+func (gw *gzipDownload) close() {
+	if gw.gz != nil {
+		gw.gz.Close()
+		putGzipWriter(gw.gz)
+	}
+}
@@ -0,0 +1,111 @@
+package view
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func publishDownload(t *testing.T, dv *DownloadView, headers ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/download", nil)
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+
+	if err := dv.Publish(web.NewContext(w, r)); err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+func TestDownloadView(t *testing.T) {
+	content := strings.Repeat("0123456789", 200)
+
+	w := publishDownload(t, NewDownloadView(strings.NewReader(content), "résumé \"v2\".txt"))
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || w.Body.String() != content || !strings.HasPrefix(etag, `"`) ||
+		w.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("full: %d %s %s", w.Code, etag, w.Header().Get("Content-Type"))
+	}
+
+	if cd := w.Header().Get("Content-Disposition"); cd !=
+		`attachment; filename="r_sum_ _v2_.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9%20%22v2%22.txt` {
+		t.Errorf("disposition: %s", cd)
+	}
+
+	// the tag of the same content is the same
+	w = publishDownload(t, NewDownloadView(strings.NewReader(content), "résumé \"v2\".txt"), "If-None-Match", etag)
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("not modified: %d", w.Code)
+	}
+
+	w = publishDownload(t, &DownloadView{Content: strings.NewReader(content), FileName: "a.txt", Inline: true}, "Range", "bytes=2-4")
+	if w.Code != 206 || w.Body.String() != "234" || w.Header().Get("Content-Disposition") != `inline; filename="a.txt"` {
+		t.Errorf("range: %d %q", w.Code, w.Body.String())
+	}
+
+	w = publishDownload(t, NewDownloadView(strings.NewReader(content), "a.txt"), "Range", "bytes=0-1,10-12")
+	if w.Code != 206 || !strings.HasPrefix(w.Header().Get("Content-Type"), "multipart/byteranges") ||
+		!strings.Contains(w.Body.String(), "\r\n\r\n01\r\n") || !strings.Contains(w.Body.String(), "\r\n\r\n012\r\n") {
+		t.Errorf("ranges: %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+
+	// a reader that cannot seek is copied as it is
+	w = publishDownload(t, NewDownloadView(io.MultiReader(strings.NewReader("abc")), "a.bin"), "Range", "bytes=0-0")
+	if w.Code != 200 || w.Body.String() != "abc" || w.Header().Get("Accept-Ranges") != "none" {
+		t.Errorf("reader: %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestDownloadViewGzip(t *testing.T) {
+	modtime := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"report.csv": {Data: []byte(strings.Repeat("a,b,c\n", 500)), ModTime: modtime},
+		"photo.png":  {Data: bytes.Repeat([]byte{1}, 3000), ModTime: modtime},
+	}
+
+	download := func(name string, headers ...string) *httptest.ResponseRecorder {
+		dv := NewDownloadViewFS(fsys, name)
+		dv.Gzip = true
+		return publishDownload(t, dv, headers...)
+	}
+
+	w := download("report.csv", "Accept-Encoding", "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || !strings.HasSuffix(w.Header().Get("ETag"), `.gz"`) ||
+		w.Header().Get("Last-Modified") != "Wed, 01 May 2024 00:00:00 GMT" {
+		t.Fatalf("gzip: %v", w.Header())
+	}
+
+	gz, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(gz); string(b) != string(fsys["report.csv"].Data) {
+		t.Error("gzip content")
+	}
+
+	// the gzipped tag is not modified, and the body is left empty
+	w = download("report.csv", "Accept-Encoding", "gzip", "If-None-Match", w.Header().Get("ETag"))
+	if w.Code != 304 || w.Body.Len() != 0 {
+		t.Errorf("gzip not modified: %d %d", w.Code, w.Body.Len())
+	}
+
+	for _, c := range [][]string{{"report.csv", "Range", "bytes=0-5"}, {"photo.png", "Accept-Encoding", "gzip"}} {
+		if w = download(c[0], c[1:]...); w.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%v: compressed", c)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	if err = NewDownloadViewFS(fsys, "missing.txt").Publish(web.NewContext(w, httptest.NewRequest("GET", "/", nil))); err == nil {
+		t.Error("missing file")
+	}
+}
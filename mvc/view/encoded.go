@@ -0,0 +1,209 @@
+package view
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+var ErrNoEncoder = errors.New("view: no encoder of the media type")
+
+// Encoder writes the encoding of v to w
+type Encoder func(w io.Writer, v interface{}) error
+
+var (
+	encoders     = make(map[string]Encoder)
+	encodertypes []string
+	encmutex     = sync.RWMutex{}
+)
+
+func init() {
+	SetEncoder("application/json", func(w io.Writer, v interface{}) error {
+		return json.NewEncoder(w).Encode(v)
+	})
+	SetEncoder("application/xml", encodeXML)
+	SetEncoder("text/xml", encodeXML)
+	SetEncoder("application/yaml", encodeYAML)
+	SetEncoder("application/x-yaml", encodeYAML)
+	SetEncoder("text/csv", encodeCSV)
+	SetEncoder("application/msgpack", encodeMsgPack)
+	SetEncoder("application/x-msgpack", encodeMsgPack)
+}
+
+// encoderExts are the media types of the encoders which the extensions of a request ask for,
+// e.g. by ResultView when the action has no template of the extension
+var encoderExts = map[string]string{
+	".json":    "application/json",
+	".xml":     "application/xml",
+	".yaml":    "application/yaml",
+	".yml":     "application/yaml",
+	".csv":     "text/csv",
+	".msgpack": "application/msgpack",
+}
+
+// EncoderByExt returns the media type with an encoder of the extension (e.g. .csv), or an empty string if there is none
+func EncoderByExt(ext string) string {
+	if mediatype, exists := encoderExts[strings.ToLower(ext)]; exists && GetEncoder(mediatype) != nil {
+		return mediatype
+	}
+	return ""
+}
+
+// SetEncoder sets the encoder of the media type (e.g. application/json),
+// the media types are offered to the Accept header in the order they are first set
+func SetEncoder(mediatype string, f Encoder) {
+	mediatype = strings.ToLower(mediatype)
+
+	encmutex.Lock()
+	defer encmutex.Unlock()
+
+	if _, exists := encoders[mediatype]; !exists {
+		encodertypes = append(encodertypes, mediatype)
+	}
+
+	encoders[mediatype] = f
+}
+
+func GetEncoder(mediatype string) Encoder {
+	encmutex.RLock()
+	defer encmutex.RUnlock()
+
+	return encoders[strings.ToLower(mediatype)]
+}
+
+// EncoderMediaTypes returns the media types which have an encoder, in the order they are offered
+func EncoderMediaTypes() []string {
+	encmutex.RLock()
+	defer encmutex.RUnlock()
+
+	out := make([]string, len(encodertypes))
+	copy(out, encodertypes)
+	return out
+}
+
+// NegotiateEncoder returns the media type with an encoder that best suits the request,
+// or an empty string if none is acceptable
+func NegotiateEncoder(ctxt *web.Context) string {
+	return ctxt.Negotiate(EncoderMediaTypes()...)
+}
+
+// AcceptedEncoder returns the media type with an encoder which the Accept header of the request names,
+// or an empty string if the request prefers HTML to all of them; unlike NegotiateEncoder,
+// a wildcard (e.g. */* of a browser) or an empty Accept header does not choose an encoder
+func AcceptedEncoder(ctxt *web.Context) string {
+	for _, ar := range web.ParseAccept(ctxt.Request.Header.Get("Accept")) {
+		if ar.Q == 0 || ar.Type == "*" || ar.SubType == "*" {
+			continue
+		}
+
+		switch mediatype := ar.MediaType(); {
+		case mediatype == "text/html" || mediatype == "application/xhtml+xml":
+			return ""
+		case GetEncoder(mediatype) != nil:
+			return mediatype
+		}
+	}
+
+	return ""
+}
+
+// EncodedView publishes a value encoded by the encoder of its media type,
+// e.g. the result of an action returning a struct
+type EncodedView struct {
+	Value     interface{}
+	MediaType string
+	// Status is the status of the response, 200 if it is 0
+	Status int
+}
+
+func NewEncodedView(value interface{}, mediatype string) EncodedView {
+	return EncodedView{Value: value, MediaType: mediatype}
+}
+
+func (ev EncodedView) ContentType(ctxt *web.Context) string {
+	if isTextMediaType(ev.MediaType) {
+		return ev.MediaType + "; charset=utf-8"
+	}
+	return ev.MediaType
+}
+
+func (ev EncodedView) Render(ctxt *web.Context) ([]byte, error) {
+	encoder := GetEncoder(ev.MediaType)
+	if encoder == nil {
+		return nil, ErrNoEncoder
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, 100))
+	if err := encoder(buf, ev.Value); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (ev EncodedView) RenderString(ctxt *web.Context) (string, error) {
+	b, err := ev.Render(ctxt)
+	if err == nil {
+		return string(b), nil
+	}
+	return "", err
+}
+
+func (ev EncodedView) Publish(ctxt *web.Context) error {
+	b, err := ev.Render(ctxt)
+	if err != nil {
+		return err
+	}
+
+	return publishEncoded(ctxt, ev.ContentType(ctxt), ev.Status, b)
+}
+
+// publishEncoded writes the encoded body of the content type with the status, 200 if it is 0
+func publishEncoded(ctxt *web.Context, ctype string, status int, b []byte) (err error) {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	ctxt.SetHeader("Content-Type", ctype)
+	ctxt.SetHeader("Content-Length", strconv.Itoa(len(b)))
+	ctxt.AddHeaderVary("Accept")
+	ctxt.Response.WriteHeader(status)
+
+	if ctxt.Method() != "HEAD" {
+		_, err = ctxt.Response.Write(b)
+	}
+
+	return err
+}
+
+// isTextMediaType returns whether the media type is of text with a charset, e.g. not of MessagePack
+func isTextMediaType(mediatype string) bool {
+	if strings.HasPrefix(mediatype, "text/") {
+		return true
+	}
+
+	for _, suffix := range []string{"json", "xml", "yaml"} {
+		if strings.HasSuffix(mediatype, "/"+suffix) || strings.HasSuffix(mediatype, "+"+suffix) ||
+			strings.HasSuffix(mediatype, "-"+suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// encodingValue returns the value of the data to be encoded, which is the value of the key "" if it is the only one
+func encodingValue(vm mvc.VM) interface{} {
+	if v, exists := vm[""]; exists && len(vm) == 1 {
+		return v
+	}
+	return vm
+}
@@ -0,0 +1,133 @@
+package view
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+type csvItem struct {
+	Name  string  `json:"name"`
+	Price float64 `csv:"price,omitempty"`
+	Note  *string
+	Added time.Time `json:"added"`
+	code  int
+}
+
+func TestCSVView(t *testing.T) {
+	added := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	items := []csvItem{{Name: "pen, blue", Price: 1.5, Added: added}, {Name: `say "hi"`, Price: 2}}
+
+	for _, c := range []struct {
+		view CSVView
+		want string
+	}{
+		{NewCSVView(items), "name,price,Note,added\n\"pen, blue\",1.5,,2024-05-01T00:00:00Z\n" +
+			"\"say \"\"hi\"\"\",2,,0001-01-01T00:00:00Z\n"},
+		{NewCSVView(items, "price", "name"), "price,name\n1.5,\"pen, blue\"\n2,\"say \"\"hi\"\"\"\n"},
+		{NewCSVView([]mvc.VM{{"b": 1, "a": "x"}, {"c": true}}), "a,b,c\nx,1,\n,,true\n"},
+		{NewCSVView([]mvc.VM{{"b": 1, "a": "x"}}, "b", "a"), "b,a\n1,x\n"},
+		{NewCSVView(&csvItem{Name: "one"}, "name"), "name\none\n"},
+	} {
+		if got, err := c.view.RenderString(nil); err != nil || got != c.want {
+			t.Errorf("%v: got %q %v", c.view.Header, got, err)
+		}
+	}
+
+	if _, err := NewCSVView([]int{1}).Render(nil); err == nil {
+		t.Error("rows of int")
+	}
+}
+
+func TestEncodedViews(t *testing.T) {
+	vm := mvc.VM{"name": "pen", "tags": []string{"a", "b"}, "price": 2}
+
+	if got, _ := NewXMLView(vm).RenderString(nil); got != xml.Header+
+		"<response><name>pen</name><price>2</price><tags><item>a</item><item>b</item></tags></response>" {
+		t.Errorf("xml: %q", got)
+	}
+
+	if got, _ := NewYAMLView(vm).RenderString(nil); got != "name: pen\nprice: 2\ntags:\n  - a\n  - b\n" {
+		t.Errorf("yaml: %q", got)
+	}
+
+	got, _ := NewMsgPackView(mvc.VM{"": []interface{}{nil, true, -1, 200, -200, 1.5, "ab"}}).Render(nil)
+	want := []byte{0x97, 0xc0, 0xc3, 0xff, 0xd1, 0x00, 0xc8, 0xd1, 0xff, 0x38,
+		0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xa2, 'a', 'b'}
+	if !bytes.Equal(got, want) {
+		t.Errorf("msgpack: % x", got)
+	}
+
+	if ct := NewMsgPackView(nil).encoded().ContentType(nil); ct != "application/msgpack" {
+		t.Errorf("msgpack content type: %s", ct)
+	}
+}
+
+func TestResultViewEncoded(t *testing.T) {
+	publish := func(ext, accept string) *httptest.ResponseRecorder {
+		w, err := publishResult(t, ext, accept)
+		if err != nil {
+			t.Fatal(ext, err)
+		}
+		return w
+	}
+
+	w := publish(".csv", "text/html")
+	if w.Header().Get("Content-Type") != "text/csv; charset=utf-8" || w.Body.String() != "name\npen\n" {
+		t.Errorf("csv: %s %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = publish("", "application/yaml")
+	if w.Header().Get("Content-Type") != "application/yaml; charset=utf-8" || w.Body.String() != "- name: pen\n" {
+		t.Errorf("yaml: %s %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	w = publish("", "text/*, application/json;q=0.5")
+	if w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Errorf("json: %s %q", w.Header().Get("Content-Type"), w.Body.String())
+	}
+
+	// a browser asking for a page without a template is not given its data
+	for _, accept := range []string{"", "*/*", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"} {
+		if _, err := publishResult(t, "", accept); !errors.Is(err, mvc.ErrTmplNotExist) {
+			t.Errorf("%q: got %v", accept, err)
+		}
+	}
+}
+
+func publishResult(t *testing.T, ext, accept string) (*httptest.ResponseRecorder, error) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/shop/cart/list"+ext, nil)
+	r.Header.Set("Accept", accept)
+
+	ctxt := web.NewContext(w, r)
+	ctxt.Module, ctxt.Controller, ctxt.Action, ctxt.Ext = "shop", "cart", "list", ext
+	ctxt.SetResource(mvc.ThemesResourceName, mvc.NewThemes(t.TempDir(), "default"))
+
+	rv := NewResultView(mvc.VM{"": []mvc.VM{{"name": "pen"}}})
+	return w, rv.Publish(ctxt)
+}
+
+func TestSetEncoderConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetEncoder("text/csv", encodeCSV)
+		}()
+		go func() {
+			defer wg.Done()
+			GetEncoder("application/json")
+			EncoderMediaTypes()
+		}()
+	}
+	wg.Wait()
+}
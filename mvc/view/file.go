@@ -2,7 +2,6 @@ package view
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"mime"
 	"net/http"
@@ -69,7 +68,7 @@ func (fv *FileDownloadView) Publish(ctxt *web.Context) (err error) {
 
 		header := ctxt.Response.Header()
 		header.Set("Content-Type", fv.ContentType(ctxt))
-		header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fv.FileName))
+		header.Set("Content-Disposition", contentDisposition("attachment", fv.FileName))
 
 		var modtime time.Time
 		if stat, err := file.Stat(); err == nil {
@@ -1,10 +1,7 @@
 package view
 
 import (
-	"bytes"
-	"compress/gzip"
 	"html/template"
-	"io"
 	"log"
 
 	"github.com/zaolab/sunnified/mvc"
@@ -15,6 +12,8 @@ type HTMLView struct {
 	mvc.VM
 	GetTmpl func(fmap template.FuncMap) (t *template.Template, err error)
 	fmap    template.FuncMap
+	layout  string
+	mode    RenderMode
 }
 
 func (hv *HTMLView) SetViewFunc(name string, f interface{}) {
@@ -38,6 +37,25 @@ func (hv *HTMLView) SetGetTmpl(f func(fmap template.FuncMap) (t *template.Templa
 	hv.GetTmpl = f
 }
 
+// SetLayout sets the layout the template is rendered through, mvc.NoLayout for none;
+// the default layout of the module is used if it is empty
+func (hv *HTMLView) SetLayout(layout string) {
+	hv.layout = layout
+}
+
+func (hv *HTMLView) Layout() string {
+	return hv.layout
+}
+
+// SetRenderMode sets whether the page is buffered (the default) or streamed, see RenderMode
+func (hv *HTMLView) SetRenderMode(mode RenderMode) {
+	hv.mode = mode
+}
+
+func (hv *HTMLView) RenderMode() RenderMode {
+	return hv.mode
+}
+
 func (hv *HTMLView) SetVMap(vmap ...mvc.VM) {
 	if hv.VM == nil {
 		hv.VM = mvc.VM{}
@@ -60,21 +78,20 @@ func (hv *HTMLView) ContentType(ctxt *web.Context) string {
 	return "text/html; charset=utf-8"
 }
 
-func (hv *HTMLView) getTmpl(names mvc.Meta) (tmpl *template.Template, err error) {
+func (hv *HTMLView) getTmpl(ctxt *web.Context, names mvc.Meta) (tmpl *template.Template, err error) {
 	if hv.GetTmpl != nil {
 		tmpl, err = hv.GetTmpl(hv.fmap)
 	} else {
-		tmpl, err = mvc.GetHTMLTmpl(mvc.GetTemplateRelPath(names, ".html"), hv.fmap)
+		tmpl, err = mvc.GetLayoutHTMLTmpl(ctxt, names, ".html", hv.layout, hv.fmap)
 	}
 
 	return
 }
 
 func (hv *HTMLView) Render(ctxt *web.Context) (b []byte, err error) {
-	if tmpl, err := hv.getTmpl(mvc.GetMvcMeta(ctxt)); err == nil {
-		buf := &bytes.Buffer{}
-		tmpl.Execute(buf, hv.VM)
-		b = buf.Bytes()
+	var tmpl *template.Template
+	if tmpl, err = hv.getTmpl(ctxt, mvc.GetMvcMeta(ctxt)); err == nil {
+		b, err = renderTmpl(tmpl, hv.VM, nil, nil)
 	}
 
 	return
@@ -96,45 +113,16 @@ func (hv *HTMLView) Publish(ctxt *web.Context) (err error) {
 	}
 
 	var tmpl *template.Template
-	tmpl, err = hv.getTmpl(names)
+	tmpl, err = hv.getTmpl(ctxt, names)
 
 	if err == nil {
-		var method = ctxt.Method()
-
-		ctxt.SetHeader("Content-Type", "text/html; charset=utf-8")
-
-		if method != "HEAD" {
-			var err error
-			var tw io.Writer = ctxt.Response
-			var gzipwriter *gzip.Writer
-
-			if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
-				ctxt.SetHeader("Content-Encoding", "gzip")
-				gzipwriter, _ = gzip.NewWriterLevel(ctxt.Response, gzip.BestSpeed)
-				tw = gzipwriter
-			}
-
-			ctxt.SetHeader("Vary", "Accept-Encoding")
-			ctxt.Response.WriteHeader(200)
-
-			err = tmpl.Execute(tw, hv.VM)
-
-			if err != nil {
-				// Header already sent... multiple write headers
-				//panic(err)
-				log.Println(err)
-			}
-
-			if gzipwriter != nil {
-				gzipwriter.Close()
-			}
-
-			//if flushw, ok := ctxt.RootResponse().(http.Flusher); ok {
-			//	flushw.Flush()
-			//}
-		} else {
-			ctxt.Response.WriteHeader(200)
+		page := &tmplPage{
+			tmpl:    tmpl,
+			data:    hv.VM,
+			mode:    hv.mode,
+			headers: map[string]string{"Content-Type": "text/html; charset=utf-8"},
 		}
+		err = page.publish(ctxt)
 	} else {
 		log.Println(err)
 		ctxt.SetErrorCode(500)
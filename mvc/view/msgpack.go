@@ -0,0 +1,159 @@
+package view
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+// MsgPackView publishes the data as MessagePack, e.g. for clients of an API that save on the size of JSON;
+// the value of the key "" is published by itself if it is the only one (see NewMsgPackView)
+type MsgPackView mvc.VM
+
+func (mv MsgPackView) ContentType(ctxt *web.Context) string {
+	return "application/msgpack"
+}
+
+func (mv MsgPackView) Render(ctxt *web.Context) ([]byte, error) {
+	return mv.encoded().Render(ctxt)
+}
+
+func (mv MsgPackView) RenderString(ctxt *web.Context) (string, error) {
+	return mv.encoded().RenderString(ctxt)
+}
+
+func (mv MsgPackView) Publish(ctxt *web.Context) error {
+	return mv.encoded().Publish(ctxt)
+}
+
+func (mv MsgPackView) encoded() EncodedView {
+	return NewEncodedView(encodingValue(mvc.VM(mv)), "application/msgpack")
+}
+
+func NewMsgPackView(vmap mvc.VM) MsgPackView {
+	if vmap == nil {
+		vmap = mvc.VM{}
+	}
+	return MsgPackView(vmap)
+}
+
+// JSONToMsgPack converts the JSON to MessagePack, in the same order as its keys;
+// integers are encoded as integers and other numbers as float64
+func JSONToMsgPack(b []byte) ([]byte, error) {
+	node, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeMsgPackNode(&buf, node)
+	return buf.Bytes(), nil
+}
+
+// encodeMsgPack writes v as MessagePack by its JSON encoding, so that the tags and marshalers of JSON apply,
+// e.g. a []byte is a base64 string
+func encodeMsgPack(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if b, err = JSONToMsgPack(b); err == nil {
+		_, err = w.Write(b)
+	}
+	return err
+}
+
+func writeMsgPackNode(buf *bytes.Buffer, node *jsonNode) {
+	switch {
+	case node.null:
+		buf.WriteByte(0xc0)
+	case node.isCollection() && node.array:
+		writeMsgPackHead(buf, len(node.values), 0x90, 15, 0xdc)
+		for _, value := range node.values {
+			writeMsgPackNode(buf, value)
+		}
+	case node.isCollection():
+		writeMsgPackHead(buf, len(node.values), 0x80, 15, 0xde)
+		for i, value := range node.values {
+			writeMsgPackString(buf, node.keys[i])
+			writeMsgPackNode(buf, value)
+		}
+	default:
+		switch v := node.scalar.(type) {
+		case bool:
+			if v {
+				buf.WriteByte(0xc3)
+			} else {
+				buf.WriteByte(0xc2)
+			}
+		case string:
+			writeMsgPackString(buf, v)
+		case json.Number:
+			writeMsgPackNumber(buf, v)
+		}
+	}
+}
+
+// writeMsgPackHead writes the head of a string, array or map of the length; fix is the byte of the fix format
+// holding lengths up to max, and long the byte of its 16 bit format (which is followed by the 32 bit one)
+func writeMsgPackHead(buf *bytes.Buffer, n int, fix byte, max int, long byte) {
+	switch {
+	case n <= max:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(long)
+		buf.Write(binary.BigEndian.AppendUint16(nil, uint16(n)))
+	default:
+		buf.WriteByte(long + 1)
+		buf.Write(binary.BigEndian.AppendUint32(nil, uint32(n)))
+	}
+}
+
+func writeMsgPackString(buf *bytes.Buffer, s string) {
+	if n := len(s); n > 31 && n <= math.MaxUint8 {
+		buf.WriteByte(0xd9)
+		buf.WriteByte(byte(n))
+	} else {
+		writeMsgPackHead(buf, n, 0xa0, 31, 0xda)
+	}
+	buf.WriteString(s)
+}
+
+func writeMsgPackNumber(buf *bytes.Buffer, n json.Number) {
+	if i, err := strconv.ParseInt(n.String(), 10, 64); err == nil {
+		switch {
+		case i >= 0 && i <= 127, i < 0 && i >= -32:
+			buf.WriteByte(byte(int8(i)))
+		case i >= math.MinInt8 && i <= math.MaxInt8:
+			buf.WriteByte(0xd0)
+			buf.WriteByte(byte(int8(i)))
+		case i >= math.MinInt16 && i <= math.MaxInt16:
+			buf.WriteByte(0xd1)
+			buf.Write(binary.BigEndian.AppendUint16(nil, uint16(int16(i))))
+		case i >= math.MinInt32 && i <= math.MaxInt32:
+			buf.WriteByte(0xd2)
+			buf.Write(binary.BigEndian.AppendUint32(nil, uint32(int32(i))))
+		default:
+			buf.WriteByte(0xd3)
+			buf.Write(binary.BigEndian.AppendUint64(nil, uint64(i)))
+		}
+		return
+	}
+
+	if u, err := strconv.ParseUint(n.String(), 10, 64); err == nil {
+		buf.WriteByte(0xcf)
+		buf.Write(binary.BigEndian.AppendUint64(nil, u))
+		return
+	}
+
+	f, _ := n.Float64()
+	buf.WriteByte(0xcb)
+	buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(f)))
+}
@@ -1,10 +1,7 @@
 package view
 
 import (
-	"bytes"
-	"compress/gzip"
 	"html/template"
-	"io"
 	"log"
 
 	"github.com/zaolab/sunnified/mvc"
@@ -15,6 +12,8 @@ type MultiView struct {
 	mvc.VM
 	GetTmpl func(fmap template.FuncMap) (t *template.Template, err error)
 	fmap    template.FuncMap
+	layout  string
+	mode    RenderMode
 }
 
 func (mv *MultiView) SetViewFunc(name string, f interface{}) {
@@ -38,6 +37,25 @@ func (mv *MultiView) SetGetTmpl(f func(fmap template.FuncMap) (t *template.Templ
 	mv.GetTmpl = f
 }
 
+// SetLayout sets the layout the template is rendered through, mvc.NoLayout for none;
+// the default layout of the module is used if it is empty
+func (mv *MultiView) SetLayout(layout string) {
+	mv.layout = layout
+}
+
+func (mv *MultiView) Layout() string {
+	return mv.layout
+}
+
+// SetRenderMode sets whether the page is buffered (the default) or streamed, see RenderMode
+func (mv *MultiView) SetRenderMode(mode RenderMode) {
+	mv.mode = mode
+}
+
+func (mv *MultiView) RenderMode() RenderMode {
+	return mv.mode
+}
+
 func (mv *MultiView) SetVMap(vmap ...mvc.VM) {
 	if mv.VM == nil {
 		mv.VM = mvc.VM{}
@@ -60,21 +78,20 @@ func (mv *MultiView) ContentType(ctxt *web.Context) string {
 	return "text/html; charset=utf-8"
 }
 
-func (mv *MultiView) getTmpl(names mvc.Meta) (tmpl *template.Template, ext string, err error) {
+func (mv *MultiView) getTmpl(ctxt *web.Context, names mvc.Meta) (tmpl *template.Template, ext string, err error) {
 	if mv.GetTmpl != nil {
 		tmpl, err = mv.GetTmpl(mv.fmap)
 	} else {
-		tmpl, err = mvc.GetHTMLTmpl(mvc.GetTemplateRelPath(names, ext), mv.fmap)
+		tmpl, err = mvc.GetLayoutHTMLTmpl(ctxt, names, ext, mv.layout, mv.fmap)
 	}
 
 	return
 }
 
 func (mv *MultiView) Render(ctxt *web.Context) (b []byte, err error) {
-	if tmpl, _, err := mv.getTmpl(mvc.GetMvcMeta(ctxt)); err == nil {
-		buf := &bytes.Buffer{}
-		tmpl.Execute(buf, mv.VM)
-		b = buf.Bytes()
+	var tmpl *template.Template
+	if tmpl, _, err = mv.getTmpl(ctxt, mvc.GetMvcMeta(ctxt)); err == nil {
+		b, err = renderTmpl(tmpl, mv.VM, nil, nil)
 	}
 
 	return
@@ -96,45 +113,16 @@ func (mv *MultiView) Publish(ctxt *web.Context) (err error) {
 	}
 
 	var tmpl *template.Template
-	tmpl, _, err = mv.getTmpl(names)
+	tmpl, _, err = mv.getTmpl(ctxt, names)
 
 	if err == nil {
-		var method = ctxt.Method()
-
-		ctxt.SetHeader("Content-Type", "text/html; charset=utf-8")
-
-		if method != "HEAD" {
-			var err error
-			var tw io.Writer = ctxt.Response
-			var gzipwriter *gzip.Writer
-
-			if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
-				ctxt.SetHeader("Content-Encoding", "gzip")
-				gzipwriter, _ = gzip.NewWriterLevel(ctxt.Response, gzip.BestSpeed)
-				tw = gzipwriter
-			}
-
-			ctxt.SetHeader("Vary", "Accept-Encoding")
-			ctxt.Response.WriteHeader(200)
-
-			err = tmpl.Execute(tw, mv.VM)
-
-			if err != nil {
-				// Header already sent... multiple write headers
-				//panic(err)
-				log.Println(err)
-			}
-
-			if gzipwriter != nil {
-				gzipwriter.Close()
-			}
-
-			//if flushw, ok := ctxt.RootResponse().(http.Flusher); ok {
-			//	flushw.Flush()
-			//}
-		} else {
-			ctxt.Response.WriteHeader(200)
+		page := &tmplPage{
+			tmpl:    tmpl,
+			data:    mv.VM,
+			mode:    mv.mode,
+			headers: map[string]string{"Content-Type": "text/html; charset=utf-8"},
 		}
+		err = page.publish(ctxt)
 	} else {
 		log.Println(err)
 		ctxt.SetErrorCode(500)
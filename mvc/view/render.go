@@ -0,0 +1,218 @@
+package view
+
+import (
+	"bytes"
+	"compress/gzip"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	txtemplate "text/template"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+// RenderMode is how a template view writes its page
+type RenderMode int
+
+const (
+	// RenderBuffered executes the template into a pooled buffer and writes the page only if it succeeds,
+	// so that a broken template shows the error page of status 500 instead of half a page (the default)
+	RenderBuffered RenderMode = iota
+	// RenderStreaming writes the status and executes the template into the response, e.g. for large pages;
+	// the page is sent to the client as far as it is written by {{flush}} in the template, and at the end
+	RenderStreaming
+)
+
+// maxPooledBuffer is the capacity of the largest buffer put back in the pool,
+// so that a single large page does not keep its memory
+const maxPooledBuffer = 1 << 20
+
+var (
+	bufpool  = sync.Pool{New: func() interface{} { return bytes.NewBuffer(make([]byte, 0, 5120)) }}
+	gzippool = sync.Pool{New: func() interface{} {
+		gz, _ := gzip.NewWriterLevel(nil, gzip.BestSpeed)
+		return gz
+	}}
+)
+
+func init() {
+	// a template using flush must parse in every mode, it does nothing unless the page is streamed
+	mvc.AddFuncName("flush")
+}
+
+// RenderModeView is implemented by the views executing a template, e.g. for an action to stream a large page
+type RenderModeView interface {
+	SetRenderMode(RenderMode)
+	RenderMode() RenderMode
+}
+
+func getBuffer() *bytes.Buffer {
+	buf := bufpool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledBuffer {
+		bufpool.Put(buf)
+	}
+}
+
+func getGzipWriter(w io.Writer) *gzip.Writer {
+	gz := gzippool.Get().(*gzip.Writer)
+	gz.Reset(w)
+	return gz
+}
+
+func putGzipWriter(gz *gzip.Writer) {
+	gz.Reset(nil)
+	gzippool.Put(gz)
+}
+
+// renderTmpl executes the template with the data into a pooled buffer, returning a copy of the output
+func renderTmpl(tmpl mvc.ViewTmpl, data interface{}, before, after func(io.Writer)) (b []byte, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if before != nil {
+		before(buf)
+	}
+
+	if err = tmpl.Execute(buf, data); err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		after(buf)
+	}
+
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+// tmplPage is a page of a template view to be published
+type tmplPage struct {
+	tmpl mvc.ViewTmpl
+	data interface{}
+	mode RenderMode
+	// headers are set when the page is written, and not on the error page of a broken template
+	headers map[string]string
+	// before and after are written around the output of the template, e.g. the callback of jsonp
+	before func(io.Writer)
+	after  func(io.Writer)
+}
+
+func (pg *tmplPage) publish(ctxt *web.Context) error {
+	if pg.mode == RenderStreaming {
+		return pg.stream(ctxt)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	if pg.before != nil {
+		pg.before(buf)
+	}
+
+	if err := pg.tmpl.Execute(buf, pg.data); err != nil {
+		// nothing is written yet, the app shows the error page instead
+		ctxt.SetErrorCode(500)
+		return err
+	}
+
+	if pg.after != nil {
+		pg.after(buf)
+	}
+
+	pg.setHeaders(ctxt)
+
+	if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
+		ctxt.SetHeader("Content-Encoding", "gzip")
+		ctxt.Response.WriteHeader(200)
+
+		gz := getGzipWriter(ctxt.Response)
+		gz.Write(buf.Bytes())
+		gz.Close()
+		putGzipWriter(gz)
+	} else {
+		ctxt.SetHeader("Content-Length", strconv.Itoa(buf.Len()))
+		ctxt.Response.WriteHeader(200)
+		ctxt.Response.Write(buf.Bytes())
+	}
+
+	return nil
+}
+
+// stream writes the status and the page as the template is executed, an error of the template
+// can only be logged as part of the page is sent already
+func (pg *tmplPage) stream(ctxt *web.Context) (err error) {
+	var (
+		tw io.Writer = ctxt.Response
+		gz *gzip.Writer
+	)
+
+	pg.setHeaders(ctxt)
+
+	if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
+		ctxt.SetHeader("Content-Encoding", "gzip")
+		gz = getGzipWriter(ctxt.Response)
+		tw = gz
+	}
+
+	flush := func() {
+		if gz != nil {
+			gz.Flush()
+		}
+		flushResponse(ctxt)
+	}
+
+	flushf := func(...interface{}) string {
+		flush()
+		return ""
+	}
+
+	switch t := pg.tmpl.(type) {
+	case *template.Template:
+		t.Funcs(template.FuncMap{"flush": flushf})
+	case *txtemplate.Template:
+		t.Funcs(txtemplate.FuncMap{"flush": flushf})
+	}
+
+	ctxt.Response.WriteHeader(200)
+
+	if pg.before != nil {
+		pg.before(tw)
+	}
+
+	err = pg.tmpl.Execute(tw, pg.data)
+
+	if pg.after != nil {
+		pg.after(tw)
+	}
+
+	if gz != nil {
+		gz.Close()
+		putGzipWriter(gz)
+	}
+	flushResponse(ctxt)
+
+	return
+}
+
+func (pg *tmplPage) setHeaders(ctxt *web.Context) {
+	for name, value := range pg.headers {
+		ctxt.SetHeader(name, value)
+	}
+	ctxt.SetHeader("Vary", "Accept-Encoding")
+}
+
+// flushResponse sends what is written of the response to the client, if the response writer can
+func flushResponse(ctxt *web.Context) {
+	if flusher, ok := ctxt.Response.(http.Flusher); ok {
+		flusher.Flush()
+	} else if flusher, ok := ctxt.RootResponse().(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
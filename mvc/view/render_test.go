@@ -0,0 +1,51 @@
+package view
+
+import (
+	"errors"
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+func publishHTML(t *testing.T, src string, mode RenderMode) (*httptest.ResponseRecorder, *web.Context, error) {
+	w := httptest.NewRecorder()
+	ctxt := web.NewContext(w, httptest.NewRequest("GET", "/", nil))
+
+	hv := &HTMLView{VM: mvc.VM{"Name": "sunny"}}
+	hv.SetRenderMode(mode)
+	hv.SetGetTmpl(func(fmap template.FuncMap) (*template.Template, error) {
+		return template.New("page").Funcs(mvc.GetFuncMap()).Funcs(template.FuncMap{
+			"fail": func() (string, error) { return "", errors.New("broken") },
+		}).Parse(src)
+	})
+
+	err := hv.Publish(ctxt)
+	return w, ctxt, err
+}
+
+func TestRenderModes(t *testing.T) {
+	w, ctxt, err := publishHTML(t, `<h1>{{.Name}}</h1>`, RenderBuffered)
+	if err != nil || w.Code != 200 || w.Body.String() != "<h1>sunny</h1>" || w.Header().Get("Content-Length") != "14" {
+		t.Errorf("buffered: %d %q %v", w.Code, w.Body.String(), err)
+	}
+
+	// a broken template writes nothing, leaving the error page to the app
+	w, ctxt, err = publishHTML(t, `<h1>{{.Name}}</h1>{{fail}}`, RenderBuffered)
+	if err == nil || ctxt.ErrorCode() != 500 || w.Body.Len() != 0 {
+		t.Errorf("buffered error: %d %q %v", ctxt.ErrorCode(), w.Body.String(), err)
+	}
+
+	w, _, err = publishHTML(t, `<head></head>{{flush}}<body>{{.Name}}</body>`, RenderStreaming)
+	if err != nil || w.Code != 200 || !w.Flushed || w.Body.String() != "<head></head><body>sunny</body>" {
+		t.Errorf("streaming: %d %q %v", w.Code, w.Body.String(), err)
+	}
+
+	w, _, err = publishHTML(t, `<h1>{{.Name}}</h1>{{fail}}`, RenderStreaming)
+	if err == nil || w.Code != 200 || !strings.HasPrefix(w.Body.String(), "<h1>sunny</h1>") {
+		t.Errorf("streaming error: %d %q %v", w.Code, w.Body.String(), err)
+	}
+}
@@ -1,8 +1,6 @@
 package view
 
 import (
-	"bytes"
-	"compress/gzip"
 	"errors"
 	"html/template"
 	"io"
@@ -20,6 +18,8 @@ type ResultView struct {
 	mvc.VM
 	GetTmpl func(fmap template.FuncMap) (t *template.Template, err error)
 	fmap    template.FuncMap
+	layout  string
+	mode    RenderMode
 }
 
 func (rv *ResultView) SetViewFunc(name string, f interface{}) {
@@ -43,6 +43,25 @@ func (rv *ResultView) SetGetTmpl(f func(fmap template.FuncMap) (t *template.Temp
 	rv.GetTmpl = f
 }
 
+// SetLayout sets the layout the template is rendered through, mvc.NoLayout for none;
+// the default layout of the module is used if it is empty
+func (rv *ResultView) SetLayout(layout string) {
+	rv.layout = layout
+}
+
+func (rv *ResultView) Layout() string {
+	return rv.layout
+}
+
+// SetRenderMode sets whether the page is buffered (the default) or streamed, see RenderMode
+func (rv *ResultView) SetRenderMode(mode RenderMode) {
+	rv.mode = mode
+}
+
+func (rv *ResultView) RenderMode() RenderMode {
+	return rv.mode
+}
+
 func (rv *ResultView) SetVMap(vmap ...mvc.VM) {
 	if rv.VM == nil {
 		rv.VM = mvc.VM{}
@@ -62,19 +81,24 @@ func (rv *ResultView) SetData(name string, value interface{}) {
 }
 
 func (rv *ResultView) ContentType(ctxt *web.Context) string {
-	return GetContentType(mvc.GetMvcMeta(ctxt)[mvc.MVCType])
+	return viewContentType(mvc.GetMvcMeta(ctxt)[mvc.MVCType])
 }
 
-func (rv *ResultView) getTmpl(names mvc.Meta) (tmpl *template.Template, ext string, err error) {
+// getTmpl returns the template of the extension of the request parsed by its view engine (see mvc.SetViewEngine),
+// the html template if there is no template of the extension and the extension has no encoder (see EncoderByExt)
+func (rv *ResultView) getTmpl(ctxt *web.Context, names mvc.Meta) (tmpl mvc.ViewTmpl, ext string, err error) {
 	ext = names[mvc.MVCType]
 
 	if rv.GetTmpl != nil {
-		tmpl, err = rv.GetTmpl(rv.fmap)
+		var ht *template.Template
+		if ht, err = rv.GetTmpl(rv.fmap); err == nil {
+			tmpl = ht
+		}
 	} else {
-		tmpl, err = mvc.GetHTMLTmpl(mvc.GetTemplateRelPath(names, ext), rv.fmap)
+		tmpl, err = mvc.GetViewTmpl(ctxt, names, ext, rv.layout, rv.fmap)
 
-		if err != nil && ext != ".html" {
-			tmpl, err = mvc.GetHTMLTmpl(mvc.GetTemplateRelPath(names, ".html"), rv.fmap)
+		if errors.Is(err, mvc.ErrTmplNotExist) && ext != ".html" && EncoderByExt(ext) == "" {
+			tmpl, err = mvc.GetViewTmpl(ctxt, names, ".html", rv.layout, rv.fmap)
 			ext = ".html"
 		}
 	}
@@ -82,9 +106,44 @@ func (rv *ResultView) getTmpl(names mvc.Meta) (tmpl *template.Template, ext stri
 	return
 }
 
+// encodedView returns the view encoding the data when the action has no template, in the media type
+// of the extension of the request (e.g. .csv), or else the one the Accept header names (see AcceptedEncoder),
+// so that a page requested by a browser is not replied with its data when its template is missing
+func (rv *ResultView) encodedView(ctxt *web.Context, ext string) (ev EncodedView, ok bool) {
+	mediatype := EncoderByExt(ext)
+	if mediatype == "" {
+		mediatype = AcceptedEncoder(ctxt)
+	}
+
+	if mediatype != "" {
+		ev, ok = NewEncodedView(encodingValue(rv.VM), mediatype), true
+	}
+
+	return
+}
+
+// viewContentType returns the content type of the pages of the extension given by its view engine,
+// or by the extension itself
+func viewContentType(ext string) string {
+	if ctype := mvc.ViewContentType(ext); ctype != "" {
+		return ctype
+	}
+	return GetContentType(ext)
+}
+
 func (rv *ResultView) Render(ctxt *web.Context) (b []byte, err error) {
-	if tmpl, ext, err := rv.getTmpl(mvc.GetMvcMeta(ctxt)); err == nil {
-		buf := &bytes.Buffer{}
+	var tmpl mvc.ViewTmpl
+	var ext string
+
+	tmpl, ext, err = rv.getTmpl(ctxt, mvc.GetMvcMeta(ctxt))
+
+	if errors.Is(err, mvc.ErrTmplNotExist) {
+		if ev, ok := rv.encodedView(ctxt, ext); ok {
+			return ev.Render(ctxt)
+		}
+	}
+
+	if err == nil {
 		var jsonp string
 		if ext == ".jsonp" {
 			jsonp = ctxt.RequestValue("callback")
@@ -93,14 +152,14 @@ func (rv *ResultView) Render(ctxt *web.Context) (b []byte, err error) {
 			}
 			jsonp = strings.TrimSpace(jsonp)
 		}
+
 		if jsonp != "" {
-			writeJsonpStart(jsonp, buf)
-		}
-		tmpl.Execute(buf, rv.VM)
-		if jsonp != "" {
-			writeJsonpEnd(jsonp, buf)
+			b, err = renderTmpl(tmpl, rv.VM,
+				func(w io.Writer) { writeJsonpStart(jsonp, w) },
+				func(w io.Writer) { writeJsonpEnd(jsonp, w) })
+		} else {
+			b, err = renderTmpl(tmpl, rv.VM, nil, nil)
 		}
-		b = buf.Bytes()
 	}
 
 	return
@@ -121,7 +180,7 @@ func (rv *ResultView) Publish(ctxt *web.Context) (err error) {
 		names[mvc.MVCAction] = "_"
 	}
 
-	var tmpl *template.Template
+	var tmpl mvc.ViewTmpl
 	var ext string
 
 	/*
@@ -136,24 +195,37 @@ func (rv *ResultView) Publish(ctxt *web.Context) (err error) {
 			return
 		}
 	*/
-	tmpl, ext, err = rv.getTmpl(names)
+	tmpl, ext, err = rv.getTmpl(ctxt, names)
+
+	if errors.Is(err, mvc.ErrTmplNotExist) {
+		if ev, ok := rv.encodedView(ctxt, ext); ok {
+			return ev.Publish(ctxt)
+		}
+	}
 
 	if err == nil {
-		var isjsonp bool
-		var jsonp string
-		var method = ctxt.Method()
+		page := &tmplPage{
+			tmpl: tmpl,
+			data: rv.VM,
+			mode: rv.mode,
+		}
 
 		if ext == ".jsonp" {
-			jsonp = ctxt.RequestValue("callback")
+			var jsonp = ctxt.RequestValue("callback")
+			var method = ctxt.Method()
+
 			if jsonp == "" {
 				jsonp = ctxt.RequestValue("jsonp")
 			}
 
-			if (method == "GET" || method == "HEAD") && jsonp != "" && ext == ".jsonp" && validate.IsJSONPCallback(jsonp) {
-				ctxt.SetHeader("Content-Type", "application/javascript")
-				ctxt.SetHeader("Content-Disposition", "attachment; filename=jsonp.jsonp")
-				ctxt.SetHeader("X-Content-Type-Options", "nosniff")
-				isjsonp = true
+			if (method == "GET" || method == "HEAD") && jsonp != "" && validate.IsJSONPCallback(jsonp) {
+				page.headers = map[string]string{
+					"Content-Type":           "application/javascript",
+					"Content-Disposition":    "attachment; filename=jsonp.jsonp",
+					"X-Content-Type-Options": "nosniff",
+				}
+				page.before = func(w io.Writer) { writeJsonpStart(jsonp, w) }
+				page.after = func(w io.Writer) { writeJsonpEnd(jsonp, w) }
 			} else {
 				err = errors.New("Invalid jsonp callback")
 				log.Println(err)
@@ -161,52 +233,10 @@ func (rv *ResultView) Publish(ctxt *web.Context) (err error) {
 				return
 			}
 		} else {
-			ctxt.SetHeader("Content-Type", GetContentType(ext))
+			page.headers = map[string]string{"Content-Type": viewContentType(ext)}
 		}
 
-		if method != "HEAD" {
-			var err error
-			var b = bytes.NewBuffer(make([]byte, 0, 5120))
-			var tw = io.MultiWriter(ctxt.Response, b)
-			var gzipwriter *gzip.Writer
-
-			if ctxt.ReqHeaderHas("Accept-Encoding", "gzip") {
-				ctxt.SetHeader("Content-Encoding", "gzip")
-				gzipwriter, _ = gzip.NewWriterLevel(tw, gzip.BestSpeed)
-				tw = gzipwriter
-			}
-
-			ctxt.SetHeader("Vary", "Accept-Encoding")
-			ctxt.Response.WriteHeader(200)
-
-			if isjsonp {
-				writeJsonpStart(jsonp, tw)
-			}
-
-			err = tmpl.Execute(tw, rv.VM)
-
-			if err != nil {
-				// Header already sent... multiple write headers
-				//panic(err)
-				log.Println(err)
-			}
-
-			if isjsonp {
-				writeJsonpEnd(jsonp, tw)
-			}
-
-			if gzipwriter != nil {
-				gzipwriter.Close()
-			}
-
-			//mc.Set(&memcache.Item{Key: ctxt.Request.RequestURI, Value: b.Bytes(), Expiration: 3600})
-
-			//if flushw, ok := ctxt.RootResponse().(http.Flusher); ok {
-			//	flushw.Flush()
-			//}
-		} else {
-			ctxt.Response.WriteHeader(200)
-		}
+		err = page.publish(ctxt)
 	} else {
 		log.Println(err)
 		ctxt.SetErrorCode(500)
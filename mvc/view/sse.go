@@ -0,0 +1,311 @@
+package view
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// DefaultHeartbeat is the interval of the heartbeats of an event stream which sets none
+const DefaultHeartbeat = 15 * time.Second
+
+// sseClientBuffer is the number of events waiting to be written to a client before it is dropped as too slow,
+// the client reconnects and resumes from the replay buffer
+const sseClientBuffer = 32
+
+var ErrEventStream = errors.New("view: an event stream can only be published")
+
+// SSEvent is an event of a Server-Sent Events stream
+type SSEvent struct {
+	// ID is the id the client resumes from with Last-Event-ID, given by EventSource.Publish if it is empty
+	ID string
+	// Event is the type of the event, "message" if it is empty
+	Event string
+	// Data is the data of the event, which is sent in a data line per line
+	Data string
+	// Retry is the time the client waits before reconnecting, unchanged if it is 0
+	Retry time.Duration
+}
+
+// WriteTo writes the frame of the event
+func (ev SSEvent) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+
+	if ev.ID != "" {
+		sb.WriteString("id: " + sseLine(ev.ID) + "\n")
+	}
+	if ev.Event != "" {
+		sb.WriteString("event: " + sseLine(ev.Event) + "\n")
+	}
+	if ev.Retry > 0 {
+		sb.WriteString("retry: " + strconv.FormatInt(ev.Retry.Milliseconds(), 10) + "\n")
+	}
+
+	data := strings.ReplaceAll(strings.ReplaceAll(ev.Data, "\r\n", "\n"), "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		sb.WriteString("data: " + line + "\n")
+	}
+	sb.WriteString("\n")
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+// sseLine returns the value of a field without the line breaks, which would end the field
+func sseLine(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// EventSource sends events to the streams of its clients (see SSEView and handler.SSEHandler),
+// keeping the latest in a replay buffer for the clients that reconnect with Last-Event-ID
+type EventSource struct {
+	// Heartbeat is the interval of the comments keeping the idle streams open through proxies,
+	// DefaultHeartbeat if it is 0 and none if it is negative
+	Heartbeat time.Duration
+	mutex     sync.Mutex
+	replay    []SSEvent
+	size      int
+	lastid    uint64
+	clients   map[chan SSEvent]struct{}
+	done      chan struct{}
+}
+
+// NewEventSource returns an event source which replays up to the number of its latest events
+func NewEventSource(replay int) *EventSource {
+	return &EventSource{
+		size:    replay,
+		clients: make(map[chan SSEvent]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Send publishes the data as an event of the type, returning its id
+func (es *EventSource) Send(event, data string) string {
+	return es.Publish(SSEvent{Event: event, Data: data})
+}
+
+// SendJSON publishes the JSON of the value as an event of the type, returning its id
+func (es *EventSource) SendJSON(event string, v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return es.Publish(SSEvent{Event: event, Data: string(b)}), nil
+}
+
+// Publish sends the event to every client and keeps it for replay, returning its id;
+// a client too slow to take it is disconnected, and resumes from the replay buffer when it reconnects
+func (es *EventSource) Publish(ev SSEvent) string {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if es.isClosed() {
+		return ev.ID
+	}
+
+	if ev.ID == "" {
+		es.lastid++
+		ev.ID = strconv.FormatUint(es.lastid, 10)
+	}
+
+	if es.size > 0 {
+		if len(es.replay) >= es.size {
+			es.replay = append(es.replay[:0], es.replay[len(es.replay)-es.size+1:]...)
+		}
+		es.replay = append(es.replay, ev)
+	}
+
+	for ch := range es.clients {
+		select {
+		case ch <- ev:
+		default:
+			delete(es.clients, ch)
+			close(ch)
+		}
+	}
+
+	return ev.ID
+}
+
+// Close ends the streams of the clients, events are no longer sent afterwards
+func (es *EventSource) Close() {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if !es.isClosed() {
+		close(es.done)
+		for ch := range es.clients {
+			delete(es.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+func (es *EventSource) isClosed() bool {
+	select {
+	case <-es.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Clients returns the number of the clients streaming the events
+func (es *EventSource) Clients() int {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+	return len(es.clients)
+}
+
+// subscribe returns the channel of the events of a new client, with the events of the replay buffer after the last id;
+// all of them if the last id is no longer in the buffer, and none if the client has not seen an event yet
+func (es *EventSource) subscribe(lastid string) (ch chan SSEvent, backlog []SSEvent) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if es.isClosed() {
+		return nil, nil
+	}
+
+	ch = make(chan SSEvent, sseClientBuffer)
+	es.clients[ch] = struct{}{}
+
+	if lastid != "" {
+		backlog = es.replay
+		for i := len(es.replay) - 1; i >= 0; i-- {
+			if es.replay[i].ID == lastid {
+				backlog = es.replay[i+1:]
+				break
+			}
+		}
+		backlog = append([]SSEvent(nil), backlog...)
+	}
+
+	return
+}
+
+func (es *EventSource) unsubscribe(ch chan SSEvent) {
+	es.mutex.Lock()
+	defer es.mutex.Unlock()
+
+	if _, exists := es.clients[ch]; exists {
+		delete(es.clients, ch)
+		close(ch)
+	}
+}
+
+func (es *EventSource) heartbeat() time.Duration {
+	if es == nil || es.Heartbeat == 0 {
+		return DefaultHeartbeat
+	}
+	return es.Heartbeat
+}
+
+// SSEView streams the events of its source and channel to the client as Server-Sent Events,
+// until the client disconnects, the source is closed, the channel is closed, or the app is closed
+type SSEView struct {
+	Source *EventSource
+	// Events are sent to this stream alone, e.g. the notices of a user
+	Events <-chan SSEvent
+	// Retry is the time the client waits before reconnecting, the default of the client if it is 0
+	Retry time.Duration
+}
+
+func NewSSEView(source *EventSource) *SSEView {
+	return &SSEView{Source: source}
+}
+
+func (sv *SSEView) ContentType(ctxt *web.Context) string {
+	return "text/event-stream; charset=utf-8"
+}
+
+func (sv *SSEView) Render(ctxt *web.Context) ([]byte, error) {
+	return nil, ErrEventStream
+}
+
+func (sv *SSEView) RenderString(ctxt *web.Context) (string, error) {
+	return "", ErrEventStream
+}
+
+func (sv *SSEView) Publish(ctxt *web.Context) error {
+	var (
+		events  <-chan SSEvent
+		backlog []SSEvent
+		done    <-chan struct{}
+		beat    <-chan time.Time
+	)
+
+	if sv.Source != nil {
+		ch, bl := sv.Source.subscribe(ctxt.ReqHeader("Last-Event-ID"))
+		if ch == nil {
+			// the source is closed, the client should not reconnect
+			ctxt.Response.WriteHeader(204)
+			return nil
+		}
+		defer sv.Source.unsubscribe(ch)
+
+		events, backlog, done = ch, bl, sv.Source.done
+	}
+
+	ctxt.SetHeader("Content-Type", sv.ContentType(ctxt))
+	ctxt.SetHeader("Cache-Control", "no-cache")
+	// asks nginx not to buffer the stream
+	ctxt.SetHeader("X-Accel-Buffering", "no")
+	ctxt.Response.WriteHeader(200)
+
+	if ctxt.Method() == "HEAD" {
+		return nil
+	}
+
+	if sv.Retry > 0 {
+		io.WriteString(ctxt.Response, "retry: "+strconv.FormatInt(sv.Retry.Milliseconds(), 10)+"\n\n")
+	}
+
+	for _, ev := range backlog {
+		if _, err := ev.WriteTo(ctxt.Response); err != nil {
+			return nil
+		}
+	}
+	flushResponse(ctxt)
+
+	if interval := sv.Source.heartbeat(); interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		beat = ticker.C
+	}
+
+	for {
+		var err error
+
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return nil
+			}
+			_, err = ev.WriteTo(ctxt.Response)
+		case ev, ok := <-sv.Events:
+			if !ok {
+				return nil
+			}
+			_, err = ev.WriteTo(ctxt.Response)
+		case <-beat:
+			_, err = io.WriteString(ctxt.Response, ":\n\n")
+		case <-done:
+			return nil
+		case <-ctxt.Request.Context().Done():
+			return nil
+		}
+
+		// a write error is the client gone
+		if err != nil {
+			return nil
+		}
+		flushResponse(ctxt)
+	}
+}
@@ -0,0 +1,134 @@
+package view
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+// streamRecorder is a response recorder which can be read while the stream is written
+type streamRecorder struct {
+	mutex sync.Mutex
+	rec   *httptest.ResponseRecorder
+}
+
+func (sr *streamRecorder) Header() http.Header {
+	return sr.rec.Header()
+}
+
+func (sr *streamRecorder) Write(b []byte) (int, error) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.rec.Write(b)
+}
+
+func (sr *streamRecorder) WriteHeader(code int) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	sr.rec.WriteHeader(code)
+}
+
+func (sr *streamRecorder) Flush() {}
+
+func (sr *streamRecorder) body() string {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+	return sr.rec.Body.String()
+}
+
+func streamSSE(t *testing.T, sv *SSEView, lastid string) (sr *streamRecorder, cancel func(), done chan error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "/events", nil).WithContext(ctx)
+	if lastid != "" {
+		r.Header.Set("Last-Event-ID", lastid)
+	}
+
+	sr = &streamRecorder{rec: httptest.NewRecorder()}
+	done = make(chan error, 1)
+	go func() { done <- sv.Publish(web.NewContext(sr, r)) }()
+
+	return sr, cancel, done
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestSSEvent(t *testing.T) {
+	var sb strings.Builder
+	SSEvent{ID: "7", Event: "up\ndate", Data: "a\r\nb", Retry: 2 * time.Second}.WriteTo(&sb)
+
+	if want := "id: 7\nevent: update\nretry: 2000\ndata: a\ndata: b\n\n"; sb.String() != want {
+		t.Errorf("got %q", sb.String())
+	}
+}
+
+func TestSSEView(t *testing.T) {
+	es := NewEventSource(2)
+	es.Heartbeat = -1
+	es.Send("", "a")
+	es.Send("", "b")
+	es.Send("tick", "c")
+
+	// the client resumes after the last event it has seen
+	sr, cancel, done := streamSSE(t, NewSSEView(es), "2")
+	waitFor(t, func() bool { return es.Clients() == 1 })
+	if id, _ := es.SendJSON("tick", map[string]int{"n": 4}); id != "4" {
+		t.Errorf("id: %s", id)
+	}
+
+	want := "id: 3\nevent: tick\ndata: c\n\nid: 4\nevent: tick\ndata: {\"n\":4}\n\n"
+	waitFor(t, func() bool { return sr.body() == want })
+
+	// a client disconnecting ends the stream
+	cancel()
+	if err := <-done; err != nil || es.Clients() != 0 {
+		t.Errorf("disconnect: %v %d", err, es.Clients())
+	}
+	if ct := sr.rec.Header().Get("Content-Type"); ct != "text/event-stream; charset=utf-8" {
+		t.Errorf("content type: %s", ct)
+	}
+
+	// the replay buffer is sent from the start if the last event is no longer in it
+	sr, cancel, done = streamSSE(t, &SSEView{Source: es, Retry: time.Second}, "1")
+	defer cancel()
+	waitFor(t, func() bool { return strings.HasSuffix(sr.body(), "data: {\"n\":4}\n\n") })
+	if !strings.HasPrefix(sr.body(), "retry: 1000\n\nid: 3\n") {
+		t.Errorf("replay: %q", sr.body())
+	}
+
+	es.Close()
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+
+	// a closed source tells the client not to reconnect
+	sr, cancel, done = streamSSE(t, NewSSEView(es), "")
+	defer cancel()
+	if err := <-done; err != nil || sr.rec.Code != 204 {
+		t.Errorf("closed: %d %v", sr.rec.Code, err)
+	}
+}
+
+func TestSSEViewEvents(t *testing.T) {
+	events := make(chan SSEvent)
+	sr, cancel, done := streamSSE(t, &SSEView{Events: events}, "")
+	defer cancel()
+
+	events <- SSEvent{Event: "notice", Data: "hi"}
+	close(events)
+
+	if err := <-done; err != nil || sr.body() != "event: notice\ndata: hi\n\n" {
+		t.Errorf("got %q %v", sr.body(), err)
+	}
+}
@@ -0,0 +1,153 @@
+package view
+
+import (
+	"encoding"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+// xmlRoot is the name of the root element of a map or slice encoded as XML, and xmlItem of the elements of a slice
+const (
+	xmlRoot = "response"
+	xmlItem = "item"
+)
+
+var (
+	xmlMarshalerType  = reflect.TypeOf((*xml.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// XMLView publishes the data as XML, in a <response> element of an element per key;
+// the value of the key "" is published by itself if it is the only one (see NewXMLView)
+type XMLView mvc.VM
+
+func (xv XMLView) ContentType(ctxt *web.Context) string {
+	return "application/xml; charset=utf-8"
+}
+
+func (xv XMLView) Render(ctxt *web.Context) ([]byte, error) {
+	return xv.encoded().Render(ctxt)
+}
+
+func (xv XMLView) RenderString(ctxt *web.Context) (string, error) {
+	return xv.encoded().RenderString(ctxt)
+}
+
+func (xv XMLView) Publish(ctxt *web.Context) error {
+	return xv.encoded().Publish(ctxt)
+}
+
+func (xv XMLView) encoded() EncodedView {
+	return NewEncodedView(encodingValue(mvc.VM(xv)), "application/xml")
+}
+
+func NewXMLView(vmap mvc.VM) XMLView {
+	if vmap == nil {
+		vmap = mvc.VM{}
+	}
+	return XMLView(vmap)
+}
+
+// encodeXML writes v as an XML document; encoding/xml encodes structs, whereas a map is an element of
+// an element per key in the order of the keys, and a slice an element of <item> elements
+func encodeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	rv := reflect.ValueOf(v)
+
+	if isXMLCollection(rv) {
+		if err := encodeXMLValue(enc, xmlRoot, rv); err != nil {
+			return err
+		}
+		return enc.Flush()
+	}
+
+	return enc.Encode(v)
+}
+
+func encodeXMLValue(enc *xml.Encoder, name string, rv reflect.Value) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+
+	for rv.IsValid() && (rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && !rv.IsNil() &&
+		!rv.Type().Implements(xmlMarshalerType) && !rv.Type().Implements(textMarshalerType) {
+		rv = rv.Elem()
+	}
+
+	if !isXMLCollection(rv) {
+		if !rv.IsValid() || ((rv.Kind() == reflect.Interface || rv.Kind() == reflect.Ptr) && rv.IsNil()) {
+			if err := enc.EncodeToken(start); err != nil {
+				return err
+			}
+			return enc.EncodeToken(start.End())
+		}
+		return enc.EncodeElement(rv.Interface(), start)
+	}
+
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if rv.Kind() == reflect.Map {
+		keys := rv.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+		for _, key := range keys {
+			if err := encodeXMLValue(enc, xmlName(key.String()), rv.MapIndex(key)); err != nil {
+				return err
+			}
+		}
+	} else {
+		for i := 0; i < rv.Len(); i++ {
+			if err := encodeXMLValue(enc, xmlItem, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return enc.EncodeToken(start.End())
+}
+
+// isXMLCollection returns whether the value is a map of string keys or a slice (other than []byte),
+// which encoding/xml does not encode as an element of its own
+func isXMLCollection(rv reflect.Value) bool {
+	if !rv.IsValid() || rv.Type().Implements(xmlMarshalerType) {
+		return false
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return rv.Type().Key().Kind() == reflect.String
+	case reflect.Slice, reflect.Array:
+		return rv.Type().Elem().Kind() != reflect.Uint8
+	}
+
+	return false
+}
+
+// xmlName returns the key as a name of an element, of which the characters not allowed are replaced by _
+func xmlName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, key)
+
+	if first, _ := utf8.DecodeRuneInString(name); !(unicode.IsLetter(first) || first == '_') ||
+		strings.HasPrefix(strings.ToLower(name), "xml") {
+		name = "_" + name
+	}
+
+	return name
+}
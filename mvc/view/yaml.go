@@ -0,0 +1,212 @@
+package view
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/zaolab/sunnified/mvc"
+	"github.com/zaolab/sunnified/web"
+)
+
+// YAMLView publishes the data as YAML, in the order of its JSON encoding;
+// the value of the key "" is published by itself if it is the only one (see NewYAMLView)
+type YAMLView mvc.VM
+
+func (yv YAMLView) ContentType(ctxt *web.Context) string {
+	return "application/yaml; charset=utf-8"
+}
+
+func (yv YAMLView) Render(ctxt *web.Context) ([]byte, error) {
+	return yv.encoded().Render(ctxt)
+}
+
+func (yv YAMLView) RenderString(ctxt *web.Context) (string, error) {
+	return yv.encoded().RenderString(ctxt)
+}
+
+func (yv YAMLView) Publish(ctxt *web.Context) error {
+	return yv.encoded().Publish(ctxt)
+}
+
+func (yv YAMLView) encoded() EncodedView {
+	return NewEncodedView(encodingValue(mvc.VM(yv)), "application/yaml")
+}
+
+func NewYAMLView(vmap mvc.VM) YAMLView {
+	if vmap == nil {
+		vmap = mvc.VM{}
+	}
+	return YAMLView(vmap)
+}
+
+// jsonNode is a decoded JSON value keeping the order of the keys of its objects
+type jsonNode struct {
+	keys   []string
+	values []*jsonNode
+	array  bool
+	null   bool
+	scalar interface{}
+}
+
+// JSONToYAML converts the JSON to YAML, in the same order as its keys
+func JSONToYAML(b []byte) ([]byte, error) {
+	node, err := decodeJSON(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if node.isCollection() && !node.isEmpty() {
+		writeYAMLNode(&buf, node, 0)
+	} else {
+		buf.WriteString(yamlInline(node))
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeYAML writes v as YAML, in the order of its JSON encoding
+func encodeYAML(w io.Writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	if b, err = JSONToYAML(b); err == nil {
+		_, err = w.Write(b)
+	}
+	return err
+}
+
+func decodeJSON(b []byte) (*jsonNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	return decodeJSONNode(dec)
+}
+
+func decodeJSONNode(dec *json.Decoder) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		node := &jsonNode{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+
+			node.keys = append(node.keys, key.(string))
+			node.values = append(node.values, value)
+		}
+		_, err = dec.Token()
+		return node, err
+	case json.Delim('['):
+		node := &jsonNode{array: true}
+		for dec.More() {
+			value, err := decodeJSONNode(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.values = append(node.values, value)
+		}
+		_, err = dec.Token()
+		return node, err
+	}
+
+	if tok == nil {
+		return &jsonNode{null: true}, nil
+	}
+
+	return &jsonNode{scalar: tok}, nil
+}
+
+func (node *jsonNode) isCollection() bool {
+	return node.scalar == nil && !node.null
+}
+
+func (node *jsonNode) isEmpty() bool {
+	return len(node.values) == 0
+}
+
+func writeYAMLNode(buf *bytes.Buffer, node *jsonNode, indent int) {
+	prefix := strings.Repeat("  ", indent)
+
+	for i, value := range node.values {
+		buf.WriteString(prefix)
+		if node.array {
+			buf.WriteString("-")
+		} else {
+			buf.WriteString(yamlString(node.keys[i]))
+			buf.WriteString(":")
+		}
+
+		if !value.isCollection() || value.isEmpty() {
+			buf.WriteString(" ")
+			buf.WriteString(yamlInline(value))
+			buf.WriteByte('\n')
+		} else if node.array && !value.array {
+			// the first key of an object in a list goes on the line of its dash
+			var sub bytes.Buffer
+			writeYAMLNode(&sub, value, indent+1)
+			buf.WriteString(" ")
+			buf.Write(sub.Bytes()[len(prefix)+2:])
+		} else {
+			buf.WriteByte('\n')
+			writeYAMLNode(buf, value, indent+1)
+		}
+	}
+}
+
+func yamlInline(node *jsonNode) string {
+	if node.null {
+		return "null"
+	}
+
+	switch v := node.scalar.(type) {
+	case nil:
+		if node.array {
+			return "[]"
+		}
+		return "{}"
+	case string:
+		return yamlString(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case json.Number:
+		return v.String()
+	}
+
+	return ""
+}
+
+// yamlString returns the string as it is if it is read back as the same string, quoted otherwise
+func yamlString(s string) string {
+	if s == "" || strings.TrimSpace(s) != s || strings.ContainsAny(s, ":#{}[],&*!|>'\"%@`\n\t\\") ||
+		strings.HasPrefix(s, "-") || strings.HasPrefix(s, "?") {
+		return strconv.Quote(s)
+	}
+
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(s)
+	}
+
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
@@ -3,10 +3,11 @@ package publisher
 import (
 	htemplate "html/template"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"os"
-	"path/filepath"
+	"path"
 	"strings"
+	"sync"
 	"text/template"
 )
 
@@ -26,6 +27,7 @@ type Renderer func(name string, data interface{}) ([]byte, error)
 
 type SunnyPublisher struct {
 	renderer map[string]Renderer
+	rmutex   sync.RWMutex
 	htmpl    *htemplate.Template
 	tmpl     *template.Template
 	tchannel chan []CTemplate
@@ -33,30 +35,41 @@ type SunnyPublisher struct {
 }
 
 func NewSunnyPublisher(p string) *SunnyPublisher {
-	var htmpl = htemplate.New("index.html")
-	var tmpl = template.New("index")
-	var plen int
-
 	if strings.Contains(p, `\`) {
 		p = strings.Replace(p, `\`, "/", -1)
 	} else if p == "" {
 		p = DefaultViewPath
 	}
 
-	if plen = len(p); p[plen-1] != '/' {
-		p = p + "/"
-		plen++
+	return NewSunnyPublisherFS(os.DirFS(p), ".")
+}
+
+// NewSunnyPublisherFS returns a publisher of the templates in the directory p of fsys, e.g. an embed.FS
+// or a util.OverlayFS of one; the templates are named by their path relative to p, as with NewSunnyPublisher
+func NewSunnyPublisherFS(fsys fs.FS, p string) *SunnyPublisher {
+	var htmpl = htemplate.New("index.html")
+	var tmpl = template.New("index")
+
+	if p == "" {
+		p = DefaultViewPath
 	}
 
-	filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
-		if err == nil && !info.IsDir() {
-			b, err := ioutil.ReadFile(path)
+	p = path.Clean(p)
+
+	fs.WalkDir(fsys, p, func(fp string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			b, err := fs.ReadFile(fsys, fp)
 
 			if err == nil {
-				if strings.HasSuffix(info.Name(), ".html") {
-					htmpl.New(path[plen:]).Parse(string(b))
+				name := fp
+				if p != "." {
+					name = strings.TrimPrefix(fp, p+"/")
+				}
+
+				if strings.HasSuffix(d.Name(), ".html") {
+					htmpl.New(name).Parse(string(b))
 				} else {
-					tmpl.New(path[plen:]).Parse(string(b))
+					tmpl.New(name).Parse(string(b))
 				}
 			}
 		}
@@ -74,7 +87,8 @@ func NewSunnyPublisher(p string) *SunnyPublisher {
 // if template can't be found and there exists a publisher for the specific ext,
 // the publisher will be used to render and publish the content
 func (p *SunnyPublisher) AddRenderer(ext string, renderer Renderer) {
-	// TODO: add mutex
+	p.rmutex.Lock()
+	defer p.rmutex.Unlock()
 	p.renderer[ext] = renderer
 }
 
@@ -103,6 +117,7 @@ func (p *SunnyPublisher) Publish(wr io.Writer, name string, data interface{}) {
 		ht.Funcs(template.FuncMap(p.fmap))
 
 		if err == nil {
+			ht = ht.Lookup(name)
 			if ht != nil {
 				ht.Execute(wr, data)
 			} else {
@@ -116,10 +131,14 @@ func (p *SunnyPublisher) Publish(wr io.Writer, name string, data interface{}) {
 	return
 
 renderer:
-	ext := ".html"
-	b, err := p.renderer[ext](name, data)
-	if err == nil {
-		wr.Write(b)
+	p.rmutex.RLock()
+	render := p.renderer[path.Ext(name)]
+	p.rmutex.RUnlock()
+
+	if render != nil {
+		if b, err := render(name, data); err == nil {
+			wr.Write(b)
+		}
 	}
 }
 
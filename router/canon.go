@@ -0,0 +1,106 @@
+package router
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// PathPolicy describes how a router normalizes request paths;
+// requests that are not in the canonical form are redirected to it
+type PathPolicy struct {
+	// StrictSlash redirects /users/ to /users when the end point is registered without a trailing slash
+	// and /users to /users/ when it is registered with one
+	StrictSlash bool
+	// LowerCase redirects paths with upper case characters to their lower case form
+	LowerCase bool
+	// CleanPath redirects paths containing empty (//), . or .. elements to their cleaned form
+	CleanPath bool
+	// RedirectCode is the status used for the redirection, either 301 or 308;
+	// defaults to 301 if not set
+	RedirectCode int
+}
+
+func (pp PathPolicy) IsNil() bool {
+	return !pp.StrictSlash && !pp.LowerCase && !pp.CleanPath
+}
+
+func (pp PathPolicy) Code() int {
+	if pp.RedirectCode == http.StatusPermanentRedirect {
+		return http.StatusPermanentRedirect
+	}
+	return http.StatusMovedPermanently
+}
+
+// EndPointSlash is implemented by end points that know whether they are registered with a trailing slash
+type EndPointSlash interface {
+	TrailingSlash() bool
+}
+
+func (sr *SunnyRouter) SetPathPolicy(pp PathPolicy) {
+	sr.pathpolicy = pp
+}
+
+func (sr *SunnyRouter) PathPolicy() PathPolicy {
+	return sr.pathpolicy
+}
+
+// CanonicalPath returns the canonical form of the request path according to the path policy.
+// Cleaning and lower casing are done without an end point (i.e. before FindRequestedEndPoint),
+// while strict slash is only applied when rep is given.
+// The returned path is the same as r.URL.Path if it is already canonical.
+func CanonicalPath(pp PathPolicy, r *http.Request, rep *RequestedEndPoint) string {
+	p := r.URL.Path
+
+	if pp.IsNil() || p == "" {
+		return p
+	}
+
+	if pp.CleanPath {
+		trailslash := p[len(p)-1] == '/'
+		p = path.Clean(p)
+
+		if trailslash && p != "/" {
+			p += "/"
+		}
+	}
+
+	if pp.LowerCase {
+		p = strings.ToLower(p)
+	}
+
+	if pp.StrictSlash && p != "/" && rep != nil && rep.EndPoint != nil {
+		if slash, ok := rep.EndPoint.(EndPointSlash); ok {
+			trailslash := p[len(p)-1] == '/'
+
+			if !slash.TrailingSlash() && trailslash {
+				p = strings.TrimRight(p, "/")
+			} else if slash.TrailingSlash() && !trailslash && len(rep.UPath) == 0 {
+				// soft end points also serve the sub paths, which are left as they are
+				p += "/"
+			}
+		}
+	}
+
+	return p
+}
+
+// RedirectCanonical redirects the request to its canonical path if the router's path policy requires it.
+// It returns true if a redirection has been sent.
+func RedirectCanonical(rt Router, w http.ResponseWriter, r *http.Request, rep *RequestedEndPoint) bool {
+	if rt == nil {
+		return false
+	}
+
+	pp := rt.PathPolicy()
+
+	if p := CanonicalPath(pp, r, rep); p != r.URL.Path {
+		u := *r.URL
+		u.Path = p
+		u.RawPath = ""
+		http.Redirect(w, r, u.RequestURI(), pp.Code())
+		return true
+	}
+
+	return false
+}
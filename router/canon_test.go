@@ -0,0 +1,80 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	var (
+		all   = PathPolicy{StrictSlash: true, LowerCase: true, CleanPath: true}
+		noend = &RequestedEndPoint{}
+		plain = &RequestedEndPoint{EndPoint: &SunnyEndPoint{}}
+		slash = &RequestedEndPoint{EndPoint: &SunnyEndPoint{trailslash: true}}
+		soft  = &RequestedEndPoint{EndPoint: &SunnyEndPoint{trailslash: true}, UPath: web.UPath{"sub"}}
+	)
+
+	for _, c := range []struct {
+		pp   PathPolicy
+		path string
+		rep  *RequestedEndPoint
+		want string
+	}{
+		{PathPolicy{}, "/Users//1/", plain, "/Users//1/"},
+		{PathPolicy{CleanPath: true}, "/a//b/./c/../d", nil, "/a/b/d"},
+		{PathPolicy{CleanPath: true}, "/a//b/", nil, "/a/b/"},
+		{PathPolicy{CleanPath: true}, "//", nil, "/"},
+		{PathPolicy{LowerCase: true}, "/Users/ABC", nil, "/users/abc"},
+		{PathPolicy{StrictSlash: true}, "/users/", nil, "/users/"},
+		{PathPolicy{StrictSlash: true}, "/users/", noend, "/users/"},
+		{PathPolicy{StrictSlash: true}, "/users/", plain, "/users"},
+		{PathPolicy{StrictSlash: true}, "/users//", plain, "/users"},
+		{PathPolicy{StrictSlash: true}, "/users", plain, "/users"},
+		{PathPolicy{StrictSlash: true}, "/users", slash, "/users/"},
+		{PathPolicy{StrictSlash: true}, "/users/", slash, "/users/"},
+		{PathPolicy{StrictSlash: true}, "/users/sub", soft, "/users/sub"},
+		{PathPolicy{StrictSlash: true}, "/", plain, "/"},
+		{all, "/Users//1/", plain, "/users/1"},
+		{all, "/Users/./1", slash, "/users/1/"},
+	} {
+		r := httptest.NewRequest("GET", c.path, nil)
+		if got := CanonicalPath(c.pp, r, c.rep); got != c.want {
+			t.Errorf("%+v %s: got %s, want %s", c.pp, c.path, got, c.want)
+		}
+	}
+}
+
+func TestRedirectCanonical(t *testing.T) {
+	rt := NewSunnyRouter()
+
+	for _, c := range []struct {
+		pp       PathPolicy
+		target   string
+		code     int
+		location string
+	}{
+		{PathPolicy{}, "/Users", http.StatusOK, ""},
+		{PathPolicy{LowerCase: true}, "/users?q=A", http.StatusOK, ""},
+		{PathPolicy{LowerCase: true}, "/Users?q=A", http.StatusMovedPermanently, "/users?q=A"},
+		{PathPolicy{CleanPath: true, RedirectCode: http.StatusPermanentRedirect}, "/a//b",
+			http.StatusPermanentRedirect, "/a/b"},
+		{PathPolicy{CleanPath: true, RedirectCode: http.StatusFound}, "/a/../b",
+			http.StatusMovedPermanently, "/b"},
+	} {
+		rt.SetPathPolicy(c.pp)
+		w := httptest.NewRecorder()
+
+		redirected := RedirectCanonical(rt, w, httptest.NewRequest("GET", c.target, nil), nil)
+
+		if redirected != (c.location != "") || w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("%+v %s: got %v %d %q", c.pp, c.target, redirected, w.Code, w.Header().Get("Location"))
+		}
+	}
+
+	if RedirectCanonical(nil, httptest.NewRecorder(), httptest.NewRequest("GET", "/A", nil), nil) {
+		t.Error("nil router redirected")
+	}
+}
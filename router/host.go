@@ -0,0 +1,122 @@
+package router
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+var (
+	hostcache = make(map[string]*hostPattern)
+	hostmutex sync.RWMutex
+)
+
+// hostPattern is a parsed host, where each label can either be a literal (e.g. example),
+// a wildcard (e.g. www*) or a variable using the same syntax as path vars
+// (e.g. {tenant}, {id:int}, {name:[a-z]+});
+// a variable always matches exactly one label
+type hostPattern struct {
+	predot  bool
+	labels  []string
+	regexes []*regexp.Regexp
+	vars    []string
+}
+
+func parseHostPattern(host string) (hp *hostPattern) {
+	hp = &hostPattern{}
+
+	if host == "" {
+		return
+	}
+
+	if host[0] == '.' {
+		host = host[1:]
+		hp.predot = true
+	}
+
+	hp.labels = strings.Split(host, ".")
+	hp.regexes = make([]*regexp.Regexp, len(hp.labels))
+	hp.vars = make([]string, len(hp.labels))
+
+	for i, label := range hp.labels {
+		hp.vars[i], hp.regexes[i] = parseHostLabel(label)
+
+		if hp.regexes[i] == nil {
+			hp.labels[i] = strings.ToLower(label)
+		}
+	}
+
+	return
+}
+
+// cachedHostPattern is used for the full host (including the parents'),
+// which is only known at request time
+func cachedHostPattern(host string) (hp *hostPattern) {
+	var exists bool
+
+	hostmutex.RLock()
+	hp, exists = hostcache[host]
+	hostmutex.RUnlock()
+
+	if !exists {
+		hp = parseHostPattern(host)
+
+		hostmutex.Lock()
+		hostcache[host] = hp
+		hostmutex.Unlock()
+	}
+
+	return
+}
+
+func parseHostLabel(label string) (varname string, re *regexp.Regexp) {
+	if varname, re = web.HostVar(label); re == nil && strings.ContainsAny(label, "?|()*[]") {
+		re = regexp.MustCompile("^" + strings.Replace(label, "*", ".*", -1) + "$")
+	}
+
+	return
+}
+
+// match matches the labels of the host from the right,
+// returning the labels left unmatched and the captured variables
+func (hp *hostPattern) match(hostArr []string) (rest []string, data map[string]string, ok bool) {
+	var (
+		lohArr   = len(hp.labels)
+		lhostArr = len(hostArr)
+	)
+
+	if lhostArr < lohArr || (lhostArr > lohArr && !hp.predot) {
+		return
+	}
+
+	rest = hostArr[0 : lhostArr-lohArr]
+	hostArr = hostArr[lhostArr-lohArr : lhostArr]
+
+	for i, label := range hp.labels {
+		if re := hp.regexes[i]; re != nil {
+			if !re.MatchString(hostArr[i]) {
+				return nil, nil, false
+			}
+		} else if label != hostArr[i] {
+			return nil, nil, false
+		}
+
+		if varname := hp.vars[i]; varname != "" && varname != "_" {
+			if data == nil {
+				data = make(map[string]string)
+			}
+			data[varname] = hostArr[i]
+		}
+	}
+
+	ok = true
+	return
+}
+
+// IsHostPattern returns true if the host contains wildcards or variables,
+// i.e. it cannot be used as it is to build an URL
+func IsHostPattern(host string) bool {
+	return (host != "" && host[0] == '.') || strings.ContainsAny(host, "?|()*[]{}")
+}
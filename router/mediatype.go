@@ -0,0 +1,98 @@
+package router
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func NewMediaTypeHandler() *MediaTypeHandler {
+	return &MediaTypeHandler{
+		handlers: make(map[string]http.Handler),
+	}
+}
+
+// MediaTypeHandler dispatches to the handler of the media type that best suits the request;
+// the extension of the path is used first, followed by the q-values of the Accept header.
+// 406 Not Acceptable is sent if none of the media types can be served.
+type MediaTypeHandler struct {
+	mutex    sync.RWMutex
+	types    []string
+	handlers map[string]http.Handler
+}
+
+func (mh *MediaTypeHandler) SetHandler(mediatype string, handler interface{}) (err error) {
+	var h http.Handler
+
+	if h, err = toHandler(handler); err != nil {
+		return
+	}
+
+	mediatype = strings.ToLower(strings.TrimSpace(mediatype))
+
+	mh.mutex.Lock()
+	defer mh.mutex.Unlock()
+
+	_, exists := mh.handlers[mediatype]
+
+	if h != nil {
+		if !exists {
+			mh.types = append(mh.types, mediatype)
+		}
+		mh.handlers[mediatype] = h
+	} else if exists {
+		delete(mh.handlers, mediatype)
+		for i, t := range mh.types {
+			if t == mediatype {
+				mh.types = append(mh.types[:i], mh.types[i+1:]...)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// MediaTypes returns the media types in the order they were added,
+// the first of which is used when the request does not state a preference
+func (mh *MediaTypeHandler) MediaTypes() []string {
+	mh.mutex.RLock()
+	defer mh.mutex.RUnlock()
+
+	out := make([]string, len(mh.types))
+	copy(out, mh.types)
+	return out
+}
+
+func (mh *MediaTypeHandler) Handler(ctxt *web.Context) (h http.Handler, mediatype string) {
+	mh.mutex.RLock()
+	defer mh.mutex.RUnlock()
+
+	if mediatype = ctxt.Negotiate(mh.types...); mediatype != "" {
+		h = mh.handlers[mediatype]
+	}
+
+	return
+}
+
+func (mh *MediaTypeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctxt := web.NewContext(w, r)
+	ctxt.Ext = path.Ext(strings.TrimRight(r.URL.Path, "/"))
+	mh.ServeContextHTTP(ctxt)
+}
+
+func (mh *MediaTypeHandler) ServeContextHTTP(ctxt *web.Context) {
+	h, _ := mh.Handler(ctxt)
+	ctxt.AddHeaderVary("Accept")
+
+	if h == nil {
+		http.Error(ctxt.Response, http.StatusText(http.StatusNotAcceptable), http.StatusNotAcceptable)
+	} else if ctxthandler, ok := h.(web.ContextHandler); ok {
+		ctxthandler.ServeContextHTTP(ctxt)
+	} else {
+		h.ServeHTTP(ctxt.Response, ctxt.Request)
+	}
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func mediaTypeBody(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}
+}
+
+func TestMediaTypeHandler(t *testing.T) {
+	mh := NewMediaTypeHandler()
+	mh.SetHandler("text/html", mediaTypeBody("html"))
+	mh.SetHandler(" Application/JSON ", mediaTypeBody("json"))
+	mh.SetHandler("application/xml", ContextHTTPHandlerFunc(func(ctxt *web.Context) {
+		io.WriteString(ctxt.Response, "xml")
+	}))
+
+	for _, c := range []struct {
+		path, accept string
+		code         int
+		body         string
+	}{
+		{"/items", "", http.StatusOK, "html"},
+		{"/items", "*/*", http.StatusOK, "html"},
+		{"/items", "application/json", http.StatusOK, "json"},
+		{"/items", "text/html;q=0.5, application/json", http.StatusOK, "json"},
+		{"/items", "application/*;q=0.9, text/html;q=0.5, application/json;q=0.1", http.StatusOK, "xml"},
+		{"/items", "image/png", http.StatusNotAcceptable, ""},
+		{"/items.json", "text/html", http.StatusOK, "json"},
+		{"/items.html/", "application/json", http.StatusOK, "html"},
+		{"/items.png", "*/*", http.StatusNotAcceptable, ""},
+	} {
+		r := httptest.NewRequest("GET", c.path, nil)
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		w := httptest.NewRecorder()
+		mh.ServeHTTP(w, r)
+
+		if w.Code != c.code || (c.body != "" && w.Body.String() != c.body) || w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %q: got %d %q, Vary %q", c.path, c.accept, w.Code, w.Body.String(), w.Header().Get("Vary"))
+		}
+	}
+
+	mh.SetHandler("text/html", nil)
+	if types := mh.MediaTypes(); len(types) != 2 || types[0] != "application/json" || types[1] != "application/xml" {
+		t.Errorf("media types after removal: %v", types)
+	}
+}
+
+func TestHandleMediaType(t *testing.T) {
+	route := NewSunnyRoute()
+	route.HandleMediaType("/items", "application/json", mediaTypeBody("json"), "GET")
+	route.HandleMediaType("/items", "text/html", mediaTypeBody("html"), "GET")
+
+	r := httptest.NewRequest("GET", "/items", nil)
+	r.Header.Set("Accept", "text/html")
+
+	rep := route.FindRequestedEndPoint("/items", r)
+	if rep == nil || rep.Handler == nil {
+		t.Fatal("end point not found")
+	}
+
+	w := httptest.NewRecorder()
+	rep.Handler.ServeHTTP(w, r)
+
+	if w.Body.String() != "html" {
+		t.Errorf("got %q", w.Body.String())
+	}
+	if methods := rep.EndPoint.Methods(); len(methods) != 2 || methods[0] != "GET" || methods[1] != "HEAD" {
+		t.Errorf("methods: %v", methods)
+	}
+}
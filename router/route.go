@@ -255,52 +255,66 @@ func (sr *SunnyRoute) SplitPath(p string) (pathsplit []string) {
 func (sr *SunnyRoute) Handle(p string, h interface{}, method ...string) (ep EndPoint) {
 	p = strings.TrimSpace(p)
 
-	if p == "" {
-		if sr.hardend != nil {
-			ep = sr.hardend
-		} else {
-			ep = &SunnyEndPoint{}
-			sr.hardend = ep
-		}
-		ep.SetHandler(h, method...)
-	} else if p == "/" {
-		if sr.softend != nil {
-			ep = sr.softend
-		} else {
-			ep = &SunnyEndPoint{}
-			sr.softend = ep
-		}
+	if p == "" || p == "/" {
+		ep = sr.endPoint(p == "/")
 		ep.SetHandler(h, method...)
 	} else {
 		varnames, rts := sr.BuildRoute(p)
 
-		if len(rts) == 1 {
-			rt := rts[0]
+		for i, rt := range rts {
 			if p[len(p)-1] == '/' {
 				ep = rt.Handle("/", h, method...)
 			} else {
 				ep = rt.Handle("", h, method...)
 			}
 
-			if len(varnames) > 0 {
-				ep.PrependVarName(varnames[0]...)
-			}
-		} else {
-			for i, rt := range rts {
-				if p[len(p)-1] == '/' {
-					ep = rt.Handle("/", h, method...)
-				} else {
-					ep = rt.Handle("", h, method...)
-				}
+			ep.PrependVarName(varnames[i]...)
+		}
+	}
+
+	return
+}
+
+// HandleMediaType binds a handler to a media type of the path,
+// so that different handlers can serve e.g. application/json and text/html on the same path.
+// The media type is chosen by the path's extension first followed by the Accept header.
+func (sr *SunnyRoute) HandleMediaType(p string, mediatype string, h interface{}, method ...string) (ep EndPoint) {
+	p = strings.TrimSpace(p)
 
-				ep.PrependVarName(varnames[i]...)
+	if p == "" || p == "/" {
+		ep = sr.endPoint(p == "/")
+		ep.SetMediaTypeHandler(mediatype, h, method...)
+	} else {
+		varnames, rts := sr.BuildRoute(p)
+
+		for i, rt := range rts {
+			if p[len(p)-1] == '/' {
+				ep = rt.HandleMediaType("/", mediatype, h, method...)
+			} else {
+				ep = rt.HandleMediaType("", mediatype, h, method...)
 			}
+
+			ep.PrependVarName(varnames[i]...)
 		}
 	}
 
 	return
 }
 
+func (sr *SunnyRoute) endPoint(soft bool) EndPoint {
+	if soft {
+		if sr.softend == nil {
+			sr.softend = &SunnyEndPoint{trailslash: true}
+		}
+		return sr.softend
+	}
+
+	if sr.hardend == nil {
+		sr.hardend = &SunnyEndPoint{}
+	}
+	return sr.hardend
+}
+
 func (sr *SunnyRoute) FindEndPoint(p []string, data []string) (EndPoint, []string, []string) {
 	var lpath = len(p)
 
@@ -328,7 +342,7 @@ func (sr *SunnyRoute) FindEndPoint(p []string, data []string) (EndPoint, []strin
 	if route, exists := sr.hardroute[curpath]; exists {
 		return route.FindEndPoint(p, data)
 	} else if noext != "" {
-		if route, exists := sr.hardroute[curpath]; exists {
+		if route, exists := sr.hardroute[noext]; exists {
 			return route.FindEndPoint(p, data)
 		}
 	}
@@ -382,7 +396,18 @@ func (sr *SunnyRoute) FindRequestedEndPoint(p string, r *http.Request) *Requeste
 	pathsplit := sr.SplitPath(p)
 	ep, upath, data := sr.FindEndPoint(pathsplit, make([]string, 0, 3))
 	if ep != nil {
-		return ep.GetRequestedEndPoint(r, upath, data)
+		if rep := ep.GetRequestedEndPoint(r, upath, data); rep != nil {
+			return rep
+		}
+
+		// the path exists but not for the method requested,
+		// a RequestedEndPoint without a handler is returned so that 405 can be differentiated from 404
+		if len(ep.Methods()) > 0 {
+			return &RequestedEndPoint{
+				Method:   r.Method,
+				EndPoint: ep,
+			}
+		}
 	}
 	return nil
 }
@@ -403,7 +428,8 @@ type SunnyEndPoint struct {
 	delete http.Handler
 	head   http.Handler
 
-	varnames []string
+	varnames   []string
+	trailslash bool
 }
 
 func (se *SunnyEndPoint) PrependVarName(names ...string) {
@@ -423,29 +449,20 @@ func (se *SunnyEndPoint) PrependVarName(names ...string) {
 	se.varnames = varnames
 }
 
+// TrailingSlash returns true if the end point is registered with a trailing slash,
+// in which case it also serves the paths below it
+func (se *SunnyEndPoint) TrailingSlash() bool {
+	return se.trailslash
+}
+
 func (se *SunnyEndPoint) AppendVarName(name ...string) {
 	se.varnames = append(se.varnames, name...)
 }
 
 func (se *SunnyEndPoint) SetHandler(handler interface{}, method ...string) error {
-	var (
-		h  http.Handler
-		ch web.ContextHandler
-		hf func(w http.ResponseWriter, r *http.Request)
-		cf func(*web.Context)
-		ok bool
-	)
-
-	if h, ok = handler.(http.Handler); !ok {
-		if hf, ok = handler.(func(w http.ResponseWriter, r *http.Request)); ok {
-			h = http.HandlerFunc(hf)
-		} else if ch, ok = handler.(web.ContextHandler); ok {
-			h = ContextHTTPHandler{ch}
-		} else if cf, ok = handler.(func(*web.Context)); ok {
-			h = ContextHTTPHandlerFunc(cf)
-		} else if handler != nil {
-			return ErrInvalidHandler
-		}
+	h, err := toHandler(handler)
+	if err != nil {
+		return err
 	}
 
 	if len(method) == 0 {
@@ -476,6 +493,30 @@ func (se *SunnyEndPoint) SetHandler(handler interface{}, method ...string) error
 	return nil
 }
 
+// SetMediaTypeHandler adds a handler for the media type to the methods,
+// the other media types already set for the methods are kept
+func (se *SunnyEndPoint) SetMediaTypeHandler(mediatype string, handler interface{}, method ...string) error {
+	if len(method) == 0 {
+		method = []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	}
+
+	for _, m := range method {
+		mh, ok := se.Handler(m).(*MediaTypeHandler)
+		if !ok {
+			mh = NewMediaTypeHandler()
+			if err := se.SetHandler(mh, m); err != nil {
+				return err
+			}
+		}
+
+		if err := mh.SetHandler(mediatype, handler); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (se *SunnyEndPoint) Handlers() map[string]http.Handler {
 	return map[string]http.Handler{
 		"GET":    se.get,
@@ -556,8 +597,9 @@ func (se *SunnyEndPoint) Methods() (methods []string) {
 	methods = make([]string, 0, 6)
 	handlers := se.Handlers()
 
-	for meth, h := range handlers {
-		if h != nil {
+	// iterate in a fixed order so that the Allow header is consistent
+	for _, meth := range [...]string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"} {
+		if handlers[meth] != nil {
 			methods = append(methods, meth)
 		}
 	}
@@ -565,6 +607,29 @@ func (se *SunnyEndPoint) Methods() (methods []string) {
 	return
 }
 
+func toHandler(handler interface{}) (h http.Handler, err error) {
+	var (
+		ch web.ContextHandler
+		hf func(w http.ResponseWriter, r *http.Request)
+		cf func(*web.Context)
+		ok bool
+	)
+
+	if h, ok = handler.(http.Handler); !ok {
+		if hf, ok = handler.(func(w http.ResponseWriter, r *http.Request)); ok {
+			h = http.HandlerFunc(hf)
+		} else if ch, ok = handler.(web.ContextHandler); ok {
+			h = ContextHTTPHandler{ch}
+		} else if cf, ok = handler.(func(*web.Context)); ok {
+			h = ContextHTTPHandlerFunc(cf)
+		} else if handler != nil {
+			err = ErrInvalidHandler
+		}
+	}
+
+	return
+}
+
 func nextEndPoint(route Route, curpath string, p []string, data []string) (EndPoint, []string, []string) {
 	data = append(data, curpath)
 	return route.FindEndPoint(p, data)
@@ -11,11 +11,11 @@ import (
 
 func NewSunnyRouter() *SunnyRouter {
 	return &SunnyRouter{
-		Route:     NewSunnyRoute(),
-		hostregex: make([]*regexp.Regexp, 0, 5),
-		hostsplit: make([]string, 0, 5),
-		routers:   make(map[string]Router),
-		matchers:  make(map[string]RouteMatcher),
+		Route:    NewSunnyRoute(),
+		hostpat:  &hostPattern{},
+		routers:  make(map[string]Router),
+		matchers: make(map[string]RouteMatcher),
+		allow405: true,
 	}
 }
 
@@ -44,6 +44,11 @@ type Router interface {
 	ServeHTTP(http.ResponseWriter, *http.Request)
 	ServeRequestedEndPoint(http.ResponseWriter, *http.Request, *RequestedEndPoint)
 	Handle(string, interface{}, ...string) EndPoint
+	HandleMediaType(string, string, interface{}, ...string) EndPoint
+	SetHandleMethodNotAllowed(bool)
+	HandleMethodNotAllowed() bool
+	SetPathPolicy(PathPolicy)
+	PathPolicy() PathPolicy
 }
 
 type PathPrefix interface {
@@ -54,10 +59,21 @@ type PathPrefix interface {
 	FullPathPrefixCanon() string
 }
 
+type VersionedRouter interface {
+	SetVersioning(Versioning)
+	Versioning() Versioning
+	AddVersion(int, Router) bool
+	VersionRouter(int) Router
+	Version(int) Router
+	Versions() []int
+	SetVersionInfo(int, VersionInfo)
+	VersionInfo(int) VersionInfo
+}
+
 type Host interface {
 	SetHost(host string, canon string)
 	Host() string
-	FullHost() string
+	FullHost(data ...map[string]string) string
 	HostCanon() string
 	FullHostCanon() string
 }
@@ -71,6 +87,7 @@ type EndPoint interface {
 	DeleteHandler() http.Handler
 	HeadHandler() http.Handler
 	SetHandler(interface{}, ...string) error
+	SetMediaTypeHandler(string, interface{}, ...string) error
 	Methods() []string
 	GetRequestedEndPoint(*http.Request, []string, []string) *RequestedEndPoint
 	PrependVarName(...string)
@@ -86,6 +103,7 @@ type Route interface {
 	Switch(string) Switch
 	BuildRoute(string) ([][]string, []Route)
 	Handle(string, interface{}, ...string) EndPoint
+	HandleMediaType(string, string, interface{}, ...string) EndPoint
 }
 
 type Switch interface {
@@ -113,6 +131,23 @@ type RequestedEndPoint struct {
 	Method   string
 	Handler  http.Handler
 	EndPoint EndPoint
+	Header   http.Header // headers to be added to the response, e.g. the deprecation of a version
+}
+
+// SetHeader adds the headers of the RequestedEndPoint to the response
+func (rep *RequestedEndPoint) SetHeader(w http.ResponseWriter) {
+	header := w.Header()
+	for k, values := range rep.Header {
+		for _, v := range values {
+			header.Add(k, v)
+		}
+	}
+}
+
+// MethodNotAllowed returns true if the path was matched to an end point
+// but the end point has no handler for the requested method
+func (rep *RequestedEndPoint) MethodNotAllowed() bool {
+	return rep.Handler == nil && rep.EndPoint != nil
 }
 
 type SunnyRouter struct {
@@ -120,11 +155,16 @@ type SunnyRouter struct {
 
 	host       string
 	hostcanon  string
-	hostregex  []*regexp.Regexp
-	hostsplit  []string
-	hostpredot bool
+	hostpat    *hostPattern
 	pathprefix string
 	pathcanon  string
+	allow405   bool
+	pathpolicy PathPolicy
+
+	versioning  Versioning
+	rexvendor   *regexp.Regexp
+	versions    map[int]*routerVersion
+	versionlist []int
 
 	parent   Router
 	routers  map[string]Router
@@ -139,7 +179,7 @@ func (sr *SunnyRouter) CanRouteRequest(r *http.Request, value map[string]interfa
 	}
 
 	for _, matcher := range sr.matchers {
-		if ok, value = matcher.Match(r, value); ok {
+		if ok, value = matcher.Match(r, value); !ok {
 			return false, value
 		}
 	}
@@ -147,28 +187,15 @@ func (sr *SunnyRouter) CanRouteRequest(r *http.Request, value map[string]interfa
 	return true, value
 }
 
+// SetHost sets the host the router serves, host labels can be variables using the same syntax as path vars,
+// e.g. {tenant}.example.com or {id:int}.example.com, which are captured into PData.
+// If the host starts with '.', all child host names are matched too.
 func (sr *SunnyRouter) SetHost(host string, canon string) {
-	sr.host = strings.ToLower(host)
+	sr.host = host
 	sr.hostcanon = canon
-	sr.hostpredot = false
-	sr.hostsplit = nil
-	sr.hostregex = nil
+	sr.hostpat = parseHostPattern(host)
 
 	if host != "" {
-		if host[0] == '.' {
-			host = host[1:len(host)]
-			sr.hostpredot = true
-		}
-
-		sr.hostsplit = strings.Split(host, ".")
-		sr.hostregex = make([]*regexp.Regexp, len(sr.hostsplit))
-
-		for i, v := range sr.hostsplit {
-			if strings.ContainsAny(v, "?|()*[]") {
-				sr.hostregex[i] = regexp.MustCompile("^" + strings.Replace(v, "*", ".*", -1) + "$")
-			}
-		}
-
 		if sr.matchers == nil {
 			sr.matchers = make(map[string]RouteMatcher)
 		}
@@ -183,7 +210,9 @@ func (sr *SunnyRouter) Host() string {
 	return sr.host
 }
 
-func (sr *SunnyRouter) FullHost() string {
+// FullHost returns the host of the router joined with the hosts of its parents;
+// if data is given, the host variables are filled in from it (see web.FillHost)
+func (sr *SunnyRouter) FullHost(data ...map[string]string) string {
 	var getParentHost func(Router) string
 
 	getParentHost = func(rt Router) string {
@@ -199,15 +228,21 @@ func (sr *SunnyRouter) FullHost() string {
 		return ""
 	}
 
+	host := sr.host
+
 	if h := getParentHost(sr); h != "" {
 		if h[0] == '.' {
-			return sr.host + h
+			host += h
+		} else {
+			host += "." + h
 		}
+	}
 
-		return sr.host + "." + h
+	for _, d := range data {
+		host = web.FillHost(host, d)
 	}
 
-	return sr.host
+	return host
 }
 
 func (sr *SunnyRouter) HostCanon() string {
@@ -238,94 +273,35 @@ func (sr *SunnyRouter) FullHostCanon() string {
 }
 
 func (sr *SunnyRouter) MatchHost(r *http.Request, value map[string]interface{}) (bool, map[string]interface{}) {
-	if vIface, exists := value["host"]; exists {
-		v := vIface.(string)
+	var (
+		hostArr []string
+		hp      = sr.hostpat
+	)
 
-		if sr.pathprefix == "" {
+	if vIface, exists := value["host"]; exists {
+		// the parent router has matched the right part of the host,
+		// the rest of it is left for us to match
+		if sr.host == "" {
 			return true, value
 		}
 
-		var (
-			hostArr  = strings.Split(v, ".")
-			lohArr   = len(sr.hostsplit)
-			lhostArr = len(hostArr)
-		)
-
-		if lhostArr < lohArr || (lhostArr > lohArr && !sr.hostpredot) {
-			return false, value
-		} else if lhostArr > lohArr {
-			hostArr = hostArr[lhostArr-lohArr : lhostArr]
+		if v := vIface.(string); v != "" {
+			hostArr = strings.Split(v, ".")
 		}
+	} else {
+		h := sr.FullHost()
 
-		for i, re := range sr.hostregex {
-			if re != nil {
-				if !re.MatchString(hostArr[i]) {
-					return false, value
-				}
-			} else if sr.hostsplit[i] != hostArr[i] {
-				return false, value
-			}
-		}
-
-		value["host"] = strings.Join(hostArr[0:lhostArr-lohArr], ".")
-		return true, value
-	}
-
-	host := strings.ToLower(r.Host)
-	h := sr.FullHost()
-
-	if h == "" {
-		return true, value
-	}
-
-	if strings.Contains(host, ":") {
-		host = host[0:strings.Index(host, ":")]
-	}
-
-	if strings.ContainsAny(h, "?|()*[]") {
-		if sr.hostpredot {
-			h = h[1:len(h)]
-		}
-
-		var (
-			hArr     = strings.Split(h, ".")
-			hostArr  = strings.Split(host, ".")
-			_hostArr = hostArr
-			lhArr    = len(hArr)
-			lohArr   = len(sr.hostsplit)
-			lhostArr = len(hostArr)
-		)
-
-		if lhostArr < lhArr || (lhostArr > lhArr && !sr.hostpredot) {
-			return false, value
-		} else if lhostArr > lhArr {
-			hostArr = hostArr[lhostArr-lhArr : lhostArr]
+		if h == "" {
+			return true, value
 		}
 
-		for i, v := range hostArr {
-			if i >= lohArr {
-				j := i - lohArr
-				if strings.ContainsAny(hArr[j], "?|()*[]") {
-					tmp := "^" + strings.Replace(hArr[j], "*", ".*", -1) + "$"
-					if ok, err := regexp.MatchString(tmp, v); !ok || err != nil {
-						return false, value
-					}
-				} else if hArr[j] != v {
-					return false, value
-				}
-			} else {
-				if sr.hostregex[i] != nil {
-					if !sr.hostregex[i].MatchString(v) {
-						return false, value
-					}
-				} else if sr.hostsplit[i] != v {
-					return false, value
-				}
-			}
+		host := strings.ToLower(r.Host)
+		if strings.Contains(host, ":") {
+			host = host[0:strings.Index(host, ":")]
 		}
 
-		value["host"] = strings.Join(_hostArr[0:lhostArr-lhArr], ".")
-		return true, value
+		hostArr = strings.Split(host, ".")
+		hp = cachedHostPattern(h)
 	}
 
 	/* if starts with '.' char, we will match all child host names
@@ -335,25 +311,25 @@ func (sr *SunnyRouter) MatchHost(r *http.Request, value map[string]interface{})
 	 * a.abc.com, a.a.abc.com, a.a.a.abc.com, b.abc.com, etc.
 	 * but it does not match
 	 * babc.com */
-	if h != "" && h[0] == '.' {
-		lenh := len(h)
-		lenhost := len(host)
-		h = h[1:lenh]
-		lenh--
+	rest, data, ok := hp.match(hostArr)
+	if !ok {
+		return false, value
+	}
 
-		if lenhost < lenh || (lenhost > lenh && host[lenhost-lenh-1] != '.') {
-			return false, value
+	if len(data) > 0 {
+		hostdata, _ := value["hostdata"].(map[string]string)
+		if hostdata == nil {
+			hostdata = make(map[string]string)
+			value["hostdata"] = hostdata
 		}
 
-		if host[lenhost-lenh:lenhost] == h {
-			value["host"] = host[0 : lenhost-lenh]
-			return true, value
+		for k, v := range data {
+			hostdata[k] = v
 		}
-
-		return false, value
 	}
 
-	return host == h, value
+	value["host"] = strings.Join(rest, ".")
+	return true, value
 }
 
 func (sr *SunnyRouter) SetPathPrefix(path string, canon string) {
@@ -408,7 +384,7 @@ func (sr *SunnyRouter) MatchPathPrefix(r *http.Request, value map[string]interfa
 		urlpath += "/"
 	}
 
-	return len(urlpath) >= lpath && urlpath[0:lpath] == path, nil
+	return len(urlpath) >= lpath && urlpath[0:lpath] == path, value
 }
 
 func (sr *SunnyRouter) PathPrefix() string {
@@ -566,6 +542,16 @@ func (sr *SunnyRouter) HasParent() bool {
 	return sr.parent != nil
 }
 
+// SetHandleMethodNotAllowed sets whether a path that matches but has no handler
+// for the requested method is responded with 405 (and an Allow header) instead of 404
+func (sr *SunnyRouter) SetHandleMethodNotAllowed(handle bool) {
+	sr.allow405 = handle
+}
+
+func (sr *SunnyRouter) HandleMethodNotAllowed() bool {
+	return sr.allow405
+}
+
 func (sr *SunnyRouter) FindRequestedEndPoint(value map[string]interface{}, r *http.Request) (Router, *RequestedEndPoint) {
 	var ok bool
 
@@ -578,20 +564,117 @@ func (sr *SunnyRouter) FindRequestedEndPoint(value map[string]interface{}, r *ht
 	}
 
 	if ok, value = sr.CanRouteRequest(r, value); ok {
+		var (
+			nart  Router
+			narep *RequestedEndPoint
+		)
+
+		if len(sr.versions) > 0 {
+			// a method not allowed of the versioned router is kept unless the unversioned routes fully match,
+			// as a full match from another router takes precedence over a method not allowed
+			if rt, rep := sr.findVersionedEndPoint(value, r); rep != nil {
+				if !rep.MethodNotAllowed() {
+					return rt, rep
+				}
+				nart, narep = rt, rep
+			}
+		}
+
 		for _, rt := range sr.routers {
-			if rt, rep := rt.FindRequestedEndPoint(value, r); rep != nil {
-				return rt, rep
+			// each sub router gets its own copy since matchers modify the value as they match
+			if rt, rep := rt.FindRequestedEndPoint(copyRouteValue(value), r); rep != nil {
+				if !rep.MethodNotAllowed() {
+					return rt, rep
+				} else if narep == nil {
+					// a full match from another router takes precedence over a method not allowed
+					nart, narep = rt, rep
+				}
 			}
 		}
 
-		return sr, sr.Route.FindRequestedEndPoint(value["pathprefix"].(string), r)
+		rep := sr.Route.FindRequestedEndPoint(value["pathprefix"].(string), r)
+
+		if hostdata, ok := value["hostdata"].(map[string]string); ok && rep != nil {
+			if rep.PData == nil {
+				rep.PData = make(web.PData)
+			}
+
+			// path vars take precedence over host vars of the same name
+			for k, v := range hostdata {
+				if _, exists := rep.PData[k]; !exists {
+					rep.PData[k] = v
+				}
+			}
+		}
+
+		if rep != nil && rep.MethodNotAllowed() {
+			if narep != nil {
+				return nart, narep
+			} else if !sr.allow405 {
+				rep = nil
+			}
+		} else if rep == nil && narep != nil {
+			return nart, narep
+		}
+
+		return sr, rep
 	}
 
 	return sr, nil
 }
 
+// URL builds the absolute URL of path p under the router,
+// host variables are filled in from data (usually the PData of the current request);
+// the request's host is used if the router does not have a host that can be built
+func (sr *SunnyRouter) URL(ctxt *web.Context, p string, data map[string]string) string {
+	var (
+		scheme = "http://"
+		host   = sr.FullHost(data)
+	)
+
+	if ctxt.Request.TLS != nil {
+		scheme = "https://"
+	}
+
+	if host == "" || IsHostPattern(host) {
+		host = ctxt.Request.Host
+	}
+
+	if p != "" && p[0] != '/' {
+		p = "/" + p
+	}
+
+	return scheme + host + sr.FullPathPrefix() + p
+}
+
+func copyRouteValue(value map[string]interface{}) map[string]interface{} {
+	cp := make(map[string]interface{}, len(value))
+
+	for k, v := range value {
+		if hostdata, ok := v.(map[string]string); ok {
+			hostdatacp := make(map[string]string, len(hostdata))
+			for hk, hv := range hostdata {
+				hostdatacp[hk] = hv
+			}
+			v = hostdatacp
+		}
+		cp[k] = v
+	}
+
+	return cp
+}
+
 func (sr *SunnyRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if RedirectCanonical(sr, w, r, nil) {
+		return
+	}
+
 	router, rep := sr.FindRequestedEndPoint(make(map[string]interface{}), r)
+
+	if rep != nil && RedirectCanonical(router, w, r, rep) {
+		return
+	}
+
 	router.ServeRequestedEndPoint(w, r, rep)
 }
 
@@ -600,8 +683,15 @@ func (sr *SunnyRouter) ServeRequestedEndPoint(w http.ResponseWriter, r *http.Req
 		ctxt := web.NewContext(w, r)
 		ctxt.UPath = rep.UPath
 		ctxt.PData = rep.PData
+		ctxt.Ext = rep.Ext
+		rep.SetHeader(w)
 
 		if !HandleHeaders(ctxt, rep.EndPoint, rep.Handler) {
+			if rep.MethodNotAllowed() {
+				MethodNotAllowed(w, r, rep.EndPoint.Methods())
+				return
+			}
+
 			if ctxthandler, ok := rep.Handler.(web.ContextHandler); ok {
 				ctxthandler.ServeContextHTTP(ctxt)
 			} else {
@@ -712,7 +802,20 @@ func SetHeaderOrigin(w http.ResponseWriter, r *http.Request, origin map[string]s
 }
 
 func ServeOptions(methods []string, w http.ResponseWriter, r *http.Request, origin map[string]string) {
-	header := w.Header()
+	w.Header().Set("Allow", AllowHeader(methods))
+	SetHeaderOrigin(w, r, origin)
+	w.WriteHeader(200)
+}
+
+// MethodNotAllowed sets the Allow header and responds with 405 Method Not Allowed
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request, methods []string) {
+	w.Header().Set("Allow", AllowHeader(methods))
+	http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+}
+
+// AllowHeader builds the value of an Allow header from the list of methods;
+// HEAD is implied by GET and OPTIONS is always allowed
+func AllowHeader(methods []string) string {
 	methstr := "HEAD, OPTIONS, GET, POST, PUT, PATCH, DELETE"
 
 	if methods != nil {
@@ -720,13 +823,11 @@ func ServeOptions(methods []string, w http.ResponseWriter, r *http.Request, orig
 		if strings.Contains(methstr, "GET") && !strings.Contains(methstr, "HEAD") {
 			methstr += ", HEAD"
 		}
-		// we are already serving OPTIONS here...
+		// OPTIONS is handled by the router itself
 		if !strings.Contains(methstr, "OPTIONS") {
 			methstr += ", OPTIONS"
 		}
 	}
 
-	header.Set("Allow", methstr)
-	SetHeaderOrigin(w, r, origin)
-	w.WriteHeader(200)
+	return methstr
 }
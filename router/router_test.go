@@ -0,0 +1,73 @@
+package router
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/zaolab/sunnified/web"
+)
+
+func TestURL(t *testing.T) {
+	root := NewSunnyRouter()
+	root.SetHost(".{tenant}.example.com", "")
+
+	api := root.SubRouter("api").(*SunnyRouter)
+	api.SetHost("{version:v[0-9]+}.api", "")
+	api.SetPathPrefix("/rest", "")
+	api.Handle("/users", func(w http.ResponseWriter, r *http.Request) {})
+
+	tenant := NewSunnyRouter()
+	tenant.SetHost("{tenant}.example.com", "")
+
+	plain := NewSunnyRouter()
+	plain.SetPathPrefix("shop", "")
+
+	r := httptest.NewRequest("GET", "/rest/users", nil)
+	r.Host = "v2.api.acme.example.com"
+
+	_, rep := root.FindRequestedEndPoint(make(map[string]interface{}), r)
+	if rep == nil || rep.PData["tenant"] != "acme" || rep.PData["version"] != "v2" {
+		t.Fatalf("host vars not captured: %+v", rep)
+	}
+
+	ctxt := web.NewContext(httptest.NewRecorder(), r)
+	ctxt.PData = rep.PData
+
+	for _, c := range []struct {
+		rt   *SunnyRouter
+		p    string
+		data map[string]string
+		want string
+	}{
+		{api, "/users", rep.PData, "http://v2.api.acme.example.com/rest/users"},
+		{api, "users", map[string]string{"tenant": "other", "version": "v3"}, "http://v3.api.other.example.com/rest/users"},
+		// a value that does not match the constraint is not filled in, so the request's host is used
+		{api, "/users", map[string]string{"tenant": "other", "version": "x"}, "http://v2.api.acme.example.com/rest/users"},
+		{api, "/users", nil, "http://v2.api.acme.example.com/rest/users"},
+		{tenant, "/", map[string]string{"tenant": "other"}, "http://other.example.com/"},
+		// the root serves all the child hosts, so there is no host to build
+		{root, "/", map[string]string{"tenant": "other"}, "http://v2.api.acme.example.com/"},
+		{plain, "/cart", nil, "http://v2.api.acme.example.com/shop/cart"},
+	} {
+		if got := c.rt.URL(ctxt, c.p, c.data); got != c.want {
+			t.Errorf("%s %v: got %s, want %s", c.p, c.data, got, c.want)
+		}
+	}
+
+	if got := api.FullHost(); got != "{version:v[0-9]+}.api.{tenant}.example.com" {
+		t.Errorf("full host: %s", got)
+	}
+	if got := api.FullHost(map[string]string{"tenant": "acme"}); got != "{version:v[0-9]+}.api.acme.example.com" {
+		t.Errorf("full host filled: %s", got)
+	}
+
+	r.TLS = &tls.ConnectionState{}
+	if got := ctxt.URL("https://{version}.api.{tenant}.example.com/users?id=1"); got != "https://v2.api.acme.example.com/users?id=1" {
+		t.Errorf("context url: %s", got)
+	}
+	if got := ctxt.URL("/users"); got != "https://v2.api.acme.example.com/users" {
+		t.Errorf("context url: %s", got)
+	}
+}
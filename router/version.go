@@ -0,0 +1,219 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	rexVersionPath   = regexp.MustCompile(`^/v([0-9]+)(/|$)`)
+	rexVersionHeader = regexp.MustCompile(`^[vV]?([0-9]+)$`)
+)
+
+// Versioning describes where the API version of a request is taken from,
+// the sources are checked in the order of path prefix, header and then Accept
+type Versioning struct {
+	// PathPrefix takes the version from the path, e.g. /v2/users
+	PathPrefix bool
+	// Header takes the version from a custom header, e.g. X-API-Version: 2
+	Header string
+	// Vendor takes the version from the Accept header, e.g. x for Accept: application/vnd.x.v2+json
+	Vendor string
+	// Default is the version used when the request does not state one, defaults to the latest version
+	Default int
+}
+
+// VersionInfo holds the deprecation details of a version,
+// which are sent as the Deprecation, Sunset and Link headers
+type VersionInfo struct {
+	Deprecated time.Time
+	Sunset     time.Time
+	Link       string
+}
+
+func (vi VersionInfo) IsDeprecated() bool {
+	return !vi.Deprecated.IsZero() || !vi.Sunset.IsZero()
+}
+
+func (vi VersionInfo) setHeader(header http.Header) {
+	if !vi.Deprecated.IsZero() {
+		header.Set("Deprecation", "@"+strconv.FormatInt(vi.Deprecated.Unix(), 10))
+	} else if !vi.Sunset.IsZero() {
+		// a sunset version is deprecated even if no date is given
+		header.Set("Deprecation", "true")
+	}
+
+	if !vi.Sunset.IsZero() {
+		header.Set("Sunset", vi.Sunset.UTC().Format(http.TimeFormat))
+	}
+
+	if vi.Link != "" {
+		header.Add("Link", "<"+vi.Link+`>; rel="deprecation"`)
+	}
+}
+
+type routerVersion struct {
+	router Router
+	info   VersionInfo
+}
+
+func (sr *SunnyRouter) SetVersioning(v Versioning) {
+	sr.versioning = v
+
+	if v.Vendor != "" {
+		sr.rexvendor = regexp.MustCompile(`^application/vnd\.` + regexp.QuoteMeta(strings.ToLower(v.Vendor)) +
+			`\.v([0-9]+)(\+[a-z0-9.-]+)?$`)
+	} else {
+		sr.rexvendor = nil
+	}
+}
+
+func (sr *SunnyRouter) Versioning() Versioning {
+	return sr.versioning
+}
+
+// AddVersion sets rt to serve the requests of the version;
+// requests for a version that does not exist are served by the nearest older version
+func (sr *SunnyRouter) AddVersion(version int, rt Router) (ok bool) {
+	if sr.versions == nil {
+		sr.versions = make(map[int]*routerVersion)
+	}
+
+	if old, exists := sr.versions[version]; exists {
+		if old.router == rt {
+			return true
+		}
+		return false
+	}
+
+	if ok = rt.SetParent(sr); ok {
+		sr.versions[version] = &routerVersion{router: rt}
+		sr.versionlist = append(sr.versionlist, version)
+		sort.Ints(sr.versionlist)
+	}
+
+	return
+}
+
+// VersionRouter creates a sub router for the version
+func (sr *SunnyRouter) VersionRouter(version int) (rt Router) {
+	if rt = sr.Version(version); rt == nil {
+		rt = NewSunnyRouter()
+		sr.AddVersion(version, rt)
+	}
+	return
+}
+
+func (sr *SunnyRouter) Version(version int) Router {
+	if rv, exists := sr.versions[version]; exists {
+		return rv.router
+	}
+	return nil
+}
+
+func (sr *SunnyRouter) Versions() []int {
+	out := make([]int, len(sr.versionlist))
+	copy(out, sr.versionlist)
+	return out
+}
+
+func (sr *SunnyRouter) SetVersionInfo(version int, info VersionInfo) {
+	if rv, exists := sr.versions[version]; exists {
+		rv.info = info
+	}
+}
+
+func (sr *SunnyRouter) VersionInfo(version int) (info VersionInfo) {
+	if rv, exists := sr.versions[version]; exists {
+		info = rv.info
+	}
+	return
+}
+
+// NearestVersion returns the highest version that is not newer than the one requested,
+// the latest version is returned if version is 0
+func (sr *SunnyRouter) NearestVersion(version int) (nearest int, ok bool) {
+	for i := len(sr.versionlist) - 1; i >= 0; i-- {
+		if v := sr.versionlist[i]; version <= 0 || v <= version {
+			return v, true
+		}
+	}
+
+	return
+}
+
+// RequestedVersion returns the version stated by the request (0 if none),
+// the path prefix is returned without the version if it has been taken from it
+func (sr *SunnyRouter) RequestedVersion(r *http.Request, pathprefix string) (version int, p string) {
+	p = pathprefix
+
+	if sr.versioning.PathPrefix {
+		if match := rexVersionPath.FindStringSubmatch(p); match != nil {
+			version, _ = strconv.Atoi(match[1])
+			p = p[len(match[0])-len(match[2]):]
+			return
+		}
+	}
+
+	if h := sr.versioning.Header; h != "" {
+		if match := rexVersionHeader.FindStringSubmatch(strings.TrimSpace(r.Header.Get(h))); match != nil {
+			version, _ = strconv.Atoi(match[1])
+			return
+		}
+	}
+
+	if sr.rexvendor != nil {
+		for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+			if i := strings.Index(accept, ";"); i >= 0 {
+				accept = accept[:i]
+			}
+
+			if match := sr.rexvendor.FindStringSubmatch(strings.ToLower(strings.TrimSpace(accept))); match != nil {
+				version, _ = strconv.Atoi(match[1])
+				return
+			}
+		}
+	}
+
+	version = sr.versioning.Default
+	return
+}
+
+func (sr *SunnyRouter) findVersionedEndPoint(value map[string]interface{}, r *http.Request) (Router, *RequestedEndPoint) {
+	pathprefix := value["pathprefix"].(string)
+	version, p := sr.RequestedVersion(r, pathprefix)
+
+	nearest, ok := sr.NearestVersion(version)
+	if !ok {
+		return sr, nil
+	}
+
+	rv := sr.versions[nearest]
+	value = copyRouteValue(value)
+	value["pathprefix"] = p
+
+	rt, rep := rv.router.FindRequestedEndPoint(value, r)
+	if rep != nil {
+		if rep.Header == nil {
+			rep.Header = make(http.Header)
+		}
+
+		// unless the version is in the path, the same URL is served differently depending on the headers
+		if p == pathprefix {
+			if sr.versioning.Header != "" {
+				rep.Header.Add("Vary", sr.versioning.Header)
+			}
+			if sr.rexvendor != nil {
+				rep.Header.Add("Vary", "Accept")
+			}
+		}
+
+		rv.info.setHeader(rep.Header)
+	}
+
+	return rt, rep
+}
@@ -0,0 +1,71 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func versionBody(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}
+}
+
+func TestVersionedRouting(t *testing.T) {
+	root := NewSunnyRouter()
+	root.SetVersioning(Versioning{PathPrefix: true, Header: "X-API-Version", Vendor: "acme"})
+	root.Handle("/about", versionBody("about"), "GET")
+	root.Handle("/users", versionBody("unversioned"), "DELETE")
+
+	root.VersionRouter(1).Handle("/users", versionBody("v1"), "GET")
+	root.VersionRouter(3).Handle("/users", versionBody("v3"), "GET", "POST")
+	root.SetVersionInfo(1, VersionInfo{Sunset: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)})
+
+	for _, c := range []struct {
+		method, target, header, accept string
+		code                           int
+		body, allow, vary              string
+	}{
+		{"GET", "/users", "", "", http.StatusOK, "v3", "", "X-API-Version"},
+		{"GET", "/users", "2", "", http.StatusOK, "v1", "", "X-API-Version"},
+		{"GET", "/users", "v3", "", http.StatusOK, "v3", "", "X-API-Version"},
+		{"GET", "/users", "", "application/vnd.acme.v1+json", http.StatusOK, "v1", "", "X-API-Version"},
+		{"GET", "/v1/users", "3", "", http.StatusOK, "v1", "", ""},
+		{"POST", "/v5/users", "", "", http.StatusOK, "v3", "", ""},
+		{"GET", "/about", "1", "", http.StatusOK, "about", "", ""},
+		// the versioned end point exists but not for the method
+		{"POST", "/users", "1", "", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS", "X-API-Version"},
+		// a full match of the unversioned routes takes precedence over the method not allowed of the versioned one
+		{"DELETE", "/users", "", "", http.StatusOK, "unversioned", "", ""},
+		{"DELETE", "/v1/users", "", "", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS", ""},
+		{"GET", "/v1/missing", "", "", http.StatusNotFound, "", "", ""},
+	} {
+		r := httptest.NewRequest(c.method, c.target, nil)
+		if c.header != "" {
+			r.Header.Set("X-API-Version", c.header)
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+
+		w := httptest.NewRecorder()
+		root.ServeHTTP(w, r)
+
+		if w.Code != c.code || (c.body != "" && w.Body.String() != c.body) ||
+			w.Header().Get("Allow") != c.allow || w.Header().Get("Vary") != c.vary {
+			t.Errorf("%s %s (%q %q): got %d %q, Allow %q, Vary %q", c.method, c.target, c.header, c.accept,
+				w.Code, w.Body.String(), w.Header().Get("Allow"), w.Header().Get("Vary"))
+		}
+	}
+
+	r := httptest.NewRequest("GET", "/v1/users", nil)
+	w := httptest.NewRecorder()
+	root.ServeHTTP(w, r)
+
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Sunset") != "Tue, 01 Jan 2030 00:00:00 GMT" {
+		t.Errorf("deprecation headers: %v", w.Header())
+	}
+}
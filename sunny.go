@@ -1,6 +1,7 @@
 package sunnified
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -8,6 +9,7 @@ import (
 	"net/http/fcgi"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strconv"
 	"sync"
 	"sync/atomic"
@@ -15,9 +17,12 @@ import (
 
 	"github.com/zaolab/sunnified/config"
 	"github.com/zaolab/sunnified/handler"
+	"github.com/zaolab/sunnified/mvc"
 	"github.com/zaolab/sunnified/mvc/controller"
 	"github.com/zaolab/sunnified/mware"
 	"github.com/zaolab/sunnified/router"
+	"github.com/zaolab/sunnified/util/av"
+	"github.com/zaolab/sunnified/util/di"
 	"github.com/zaolab/sunnified/util/event"
 	"github.com/zaolab/sunnified/web"
 )
@@ -71,7 +76,9 @@ type SunnyApp struct {
 	id          int
 	MiddleWares []mware.MiddleWare
 	MaxFileSize int64
+	FileScanner av.Scanner
 	conf        config.Library
+	container   *di.Container
 	runners     int32
 	closed      int32
 	_callback   func()
@@ -80,15 +87,22 @@ type SunnyApp struct {
 	controllers *controller.Group
 	ctrlhand    *handler.DynamicHandler
 	resources   map[string]func() interface{}
+	themes      *mvc.Themes
+	tmplwatcher *mvc.TmplWatcher
 	mwareresp   []func(*web.Context)
 	listener    net.Listener
+	// closectx is cancelled by Close, ending the contexts of the requests (e.g. of event streams)
+	closectx  context.Context
+	closefunc context.CancelFunc
 }
 
 func (sk *SunnyApp) Run(params map[string]interface{}) {
 	var laddr = ":80"
 	var timeout = ReqTimeout
+	var isdev bool
 
 	if dev, ok := params["dev"]; ok && dev.(bool) {
+		isdev = true
 		laddr = "127.0.0.1:8080"
 	} else {
 		if port, ok := params["port"]; ok {
@@ -126,6 +140,19 @@ func (sk *SunnyApp) Run(params map[string]interface{}) {
 		timeout = tout.(time.Duration)
 	}
 
+	// tmplmode is "production" or "development", which is the default of dev;
+	// it is the mode of the themes of the app (see mvc.Themes.SetMode)
+	switch tmplmode, _ := params["tmplmode"].(string); {
+	case tmplmode == "production":
+		sk.Themes().SetMode(mvc.TmplProductionMode)
+		if err := sk.Themes().ParseAll(); err != nil {
+			log.Panicln(err)
+		}
+	case tmplmode == "development" || (tmplmode == "" && isdev):
+		sk.Themes().SetMode(mvc.TmplDevelopmentMode)
+		sk.tmplwatcher = sk.Themes().Watch(time.Second)
+	}
+
 	if graceful, ok := params["graceful"]; ok && graceful.(bool) {
 		GracefulShutDown()
 	}
@@ -208,11 +235,28 @@ func (sk *SunnyApp) AddMiddleWare(mwarecon mware.MiddleWare) {
 	sk.mwareresp = append(sk.mwareresp, mwarecon.Response)
 }
 
+// SetBindSource sets the request data that the controllers added after it are bound from,
+// unless they are controller.BindSourcers; only the path vars are bound by default
+func (sk *SunnyApp) SetBindSource(src controller.BindSource) {
+	sk.controllers.SetBindSource(src)
+}
+
 func (sk *SunnyApp) AddController(cinterface interface{}) {
 	sk.controllers.AddController(cinterface)
 	sk.createDynamicHandler()
 }
 
+// RESTResource routes the conventional actions (Index, Show, Create, Update, Delete, New and Edit)
+// of the controller under /name, see handler.NewRESTResource; nested resources are added with Nested
+func (sk *SunnyApp) RESTResource(name string, cinterface interface{}) *handler.RESTResource {
+	// the container is created first, so that the controllers are parsed with it
+	sk.Container()
+	cm, _, _, _ := sk.controllers.MakeControllerMeta(cinterface)
+	res := handler.NewRESTResource(sk.Router, "", name, cm)
+	res.Controllers = sk.controllers
+	return res
+}
+
 func (sk *SunnyApp) SetControllerDefaults(action, control, mod string) {
 	sk.createDynamicHandler()
 	sk.ctrlhand.SetAction(action)
@@ -241,6 +285,24 @@ func (sk *SunnyApp) SubRouter(name string) (rt router.Router) {
 	return rt
 }
 
+// VersionRouter creates a sub app serving the API version,
+// each of which has its own controllers
+func (sk *SunnyApp) VersionRouter(version int) (rt router.Router) {
+	vr, ok := sk.Router.(router.VersionedRouter)
+	if !ok {
+		return nil
+	}
+
+	if rt = vr.Version(version); rt == nil {
+		rt = NewSunnyApp()
+		if !vr.AddVersion(version, rt) {
+			return nil
+		}
+	}
+
+	return rt
+}
+
 func (sk *SunnyApp) AddResourceFunc(name string, f func() interface{}) {
 	if sk.resources == nil {
 		sk.resources = make(map[string]func() interface{})
@@ -248,6 +310,45 @@ func (sk *SunnyApp) AddResourceFunc(name string, f func() interface{}) {
 	sk.resources[name] = f
 }
 
+// SetThemes sets the themes the templates of the views of the app are found in,
+// mvc.DefaultThemes is used if it is not set (sub routers have their own)
+func (sk *SunnyApp) SetThemes(th *mvc.Themes) {
+	sk.themes = th
+}
+
+func (sk *SunnyApp) Themes() *mvc.Themes {
+	if sk.themes == nil {
+		return mvc.DefaultThemes
+	}
+	return sk.themes
+}
+
+// CheckTemplates returns the templates of the actions of the controllers of the app which are missing
+// or cannot be parsed for the extensions, see controller.Group.CheckTemplates
+func (sk *SunnyApp) CheckTemplates(exts ...string) []controller.TmplProblem {
+	return sk.controllers.CheckTemplates(sk.Themes(), exts...)
+}
+
+// Container returns the dependency injection container of the app, creating it on first use;
+// the services are injected into controller fields tagged with sunnified.inject
+// and action arguments of the provided types, which have to be provided before the controllers are added
+func (sk *SunnyApp) Container() *di.Container {
+	sk.mutex.Lock()
+	defer sk.mutex.Unlock()
+
+	if sk.container == nil {
+		sk.container = di.NewContainer()
+		sk.controllers.SetContainer(sk.container)
+	}
+
+	return sk.container
+}
+
+// Provide registers a service constructor with the app's container, see di.Container.Provide
+func (sk *SunnyApp) Provide(scope di.Scope, ctor interface{}, teardown ...di.Teardown) error {
+	return sk.Container().Provide(scope, ctor, teardown...)
+}
+
 func (sk *SunnyApp) createDynamicHandler() {
 	if sk.ctrlhand == nil {
 		sk.ctrlhand = handler.NewDynamicHandler(sk.controllers)
@@ -291,10 +392,19 @@ func (sk *SunnyApp) decrunners() {
 }
 
 func (sk *SunnyApp) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if router.RedirectCanonical(sk.Router, w, r, nil) {
+		return
+	}
+
 	rt, rep := sk.Router.FindRequestedEndPoint(make(map[string]interface{}), r)
 	if rt == sk.Router {
 		rt = sk
 	}
+
+	if rep != nil && router.RedirectCanonical(rt, w, r, rep) {
+		return
+	}
+
 	rt.ServeRequestedEndPoint(w, r, rep)
 }
 
@@ -314,6 +424,14 @@ func (sk *SunnyApp) ServeRequestedEndPoint(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if sk.closectx != nil {
+		ctx, cancel := context.WithCancel(r.Context())
+		stop := context.AfterFunc(sk.closectx, cancel)
+		defer stop()
+		defer cancel()
+		r = r.WithContext(ctx)
+	}
+
 	sw := &SunnyResponseWriter{
 		Status:         200,
 		ResponseWriter: w,
@@ -357,13 +475,27 @@ func (sk *SunnyApp) ServeRequestedEndPoint(w http.ResponseWriter, r *http.Reques
 	sunctxt.PData = rep.PData
 	sunctxt.Ext = rep.Ext
 	sunctxt.MaxFileSize = sk.MaxFileSize
+	sunctxt.FileScanner = sk.FileScanner
 	sunctxt.ParseRequestData()
 	sw.ctxt = sunctxt
+	rep.SetHeader(w)
 
 	for n, f := range sk.resources {
 		sunctxt.SetResource(n, f())
 	}
 
+	if sk.themes != nil {
+		sunctxt.SetResource(mvc.ThemesResourceName, sk.themes)
+	}
+
+	if sk.container != nil {
+		sunctxt.Services = sk.container.NewScope()
+		sunctxt.Services.Set(sunctxt)
+		sunctxt.Services.Set(sunctxt.Request)
+		sunctxt.Services.SetAs(reflect.TypeOf((*http.ResponseWriter)(nil)).Elem(), reflect.ValueOf(sunctxt.Response))
+		defer sunctxt.Services.Close()
+	}
+
 	for _, midware := range sk.MiddleWares {
 		midware.Request(sunctxt)
 		defer midware.Cleanup(sunctxt)
@@ -373,6 +505,12 @@ func (sk *SunnyApp) ServeRequestedEndPoint(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if rep.MethodNotAllowed() {
+		w.Header().Set("Allow", router.AllowHeader(rep.EndPoint.Methods()))
+		handler.ErrorHTML(w, r, http.StatusMethodNotAllowed)
+		return
+	}
+
 	sw.midwares = sk.mwareresp
 	for _, midware := range sk.MiddleWares {
 		midware.Body(sunctxt)
@@ -422,6 +560,9 @@ func (sk *SunnyApp) ServeRequestedEndPoint(w http.ResponseWriter, r *http.Reques
 			handler.ErrorHTML(w, r, sunctxt.ErrorCode())
 		} else if sunctxt.IsRedirecting() {
 			sk.triggerevent(sunctxt, "redirect", map[string]interface{}{"redirection": sunctxt.Redirection()})
+		} else if state == http.StatusMethodNotAllowed && !sk.Router.HandleMethodNotAllowed() {
+			w.Header().Del("Allow")
+			handler.ErrorHTML(w, r, http.StatusNotFound)
 		} else if state != -1 && (state < 200 || state >= 300) {
 			handler.ErrorHTML(w, r, state)
 		}
@@ -452,6 +593,9 @@ func (sk *SunnyApp) Close(callback func()) bool {
 	sk.mutex.Unlock()
 
 	if atomic.CompareAndSwapInt32(&sk.closed, 0, 1) {
+		if sk.closefunc != nil {
+			sk.closefunc()
+		}
 		if atomic.AddInt32(&sk.runners, -1) == 0 {
 			removeSunnyApp(sk.id)
 			sk.callback()
@@ -465,10 +609,17 @@ func (sk *SunnyApp) Close(callback func()) bool {
 func (sk *SunnyApp) clear() {
 	sk.ev = nil
 	sk.MiddleWares = nil
+	if sk.container != nil {
+		sk.container.Close()
+	}
 	if sk.listener != nil {
 		sk.listener.Close()
 		sk.listener = nil
 	}
+	if sk.tmplwatcher != nil {
+		sk.tmplwatcher.Stop()
+		sk.tmplwatcher = nil
+	}
 }
 
 func setreqerror(err error, w http.ResponseWriter) {
@@ -493,6 +644,7 @@ func NewSunnyApp() (ss *SunnyApp) {
 		runners:     1,
 		mwareresp:   make([]func(*web.Context), 0, 5),
 	}
+	ss.closectx, ss.closefunc = context.WithCancel(context.Background())
 
 	mutex.Lock()
 	defer mutex.Unlock()
@@ -0,0 +1,333 @@
+package di
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+)
+
+type Scope int
+
+const (
+	// Singleton values are constructed once for the container and torn down when it is closed
+	Singleton Scope = iota
+	// PerRequest values are constructed once for each request scope and torn down when the request ends
+	PerRequest
+)
+
+var (
+	ErrNotProvided   = errors.New("no provider for the type")
+	ErrInvalidCtor   = errors.New("constructor has to be a func returning a value and optionally an error")
+	ErrScopeMismatch = errors.New("a singleton cannot depend on a per request value")
+	ErrCircular      = errors.New("circular dependency")
+	ErrClosed        = errors.New("container has been closed")
+
+	typeError = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Teardown is called with a value when its scope ends,
+// values without a teardown are closed if they implement io.Closer
+type Teardown func(interface{})
+
+type provider struct {
+	scope    Scope
+	ctor     reflect.Value
+	rtype    reflect.Type
+	teardown Teardown
+	mutex    sync.Mutex
+	built    bool
+	value    reflect.Value
+}
+
+func NewContainer() *Container {
+	return &Container{
+		providers: make(map[reflect.Type]*provider),
+	}
+}
+
+// Container holds the providers of the types that can be injected.
+// Values are constructed lazily, i.e. only when they (or a value depending on them) are first asked for;
+// the arguments of a constructor are themselves injected by their types.
+type Container struct {
+	mutex     sync.RWMutex
+	providers map[reflect.Type]*provider
+	built     []*provider
+	closed    bool
+}
+
+// Provide registers ctor as the provider of its first return type, which has to be a func
+// returning the value and optionally an error, e.g. func(Config) (*sql.DB, error).
+// To provide an interface, declare the interface as the return type of ctor.
+func (c *Container) Provide(scope Scope, ctor interface{}, teardown ...Teardown) error {
+	f := reflect.ValueOf(ctor)
+	ft := f.Type()
+
+	if ft.Kind() != reflect.Func || ft.NumOut() < 1 || ft.NumOut() > 2 ||
+		(ft.NumOut() == 2 && ft.Out(1) != typeError) || ft.IsVariadic() {
+		return ErrInvalidCtor
+	}
+
+	p := &provider{
+		scope: scope,
+		ctor:  f,
+		rtype: ft.Out(0),
+	}
+
+	if len(teardown) > 0 {
+		p.teardown = teardown[0]
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.providers[p.rtype] = p
+
+	return nil
+}
+
+// Value registers an already constructed singleton under its own type,
+// which is torn down with the container like the constructed ones
+func (c *Container) Value(v interface{}, teardown ...Teardown) {
+	p := &provider{
+		scope: Singleton,
+		rtype: reflect.TypeOf(v),
+		built: true,
+		value: reflect.ValueOf(v),
+	}
+
+	if len(teardown) > 0 {
+		p.teardown = teardown[0]
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.providers[p.rtype] = p
+	c.built = append(c.built, p)
+}
+
+func (c *Container) Has(t reflect.Type) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	_, exists := c.providers[t]
+	return exists
+}
+
+func (c *Container) provider(t reflect.Type) (p *provider, err error) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	if c.closed {
+		return nil, ErrClosed
+	}
+
+	var exists bool
+	if p, exists = c.providers[t]; !exists {
+		err = fmt.Errorf("%v: %s", ErrNotProvided, t)
+	}
+
+	return
+}
+
+// Resolve returns the singleton of the type, constructing it if it has not been
+func (c *Container) Resolve(t reflect.Type) (reflect.Value, error) {
+	return c.resolve(t, nil, nil)
+}
+
+// Get sets the value ptr points to with the singleton of its type, e.g.
+//
+//	var db *sql.DB
+//	err := container.Get(&db)
+func (c *Container) Get(ptr interface{}) error {
+	return get(ptr, c.Resolve)
+}
+
+func (c *Container) resolve(t reflect.Type, s *RequestScope, resolving []reflect.Type) (value reflect.Value, err error) {
+	for _, rt := range resolving {
+		if rt == t {
+			return value, fmt.Errorf("%v: %s", ErrCircular, t)
+		}
+	}
+
+	if s != nil {
+		if value, exists := s.value(t); exists {
+			return value, nil
+		}
+	}
+
+	p, err := c.provider(t)
+	if err != nil {
+		return
+	}
+
+	resolving = append(resolving, t)
+
+	if p.scope == PerRequest {
+		if s == nil {
+			return value, fmt.Errorf("%v: %s", ErrScopeMismatch, t)
+		}
+		return s.build(p, resolving)
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if !p.built {
+		// a singleton lives longer than any request, so it only gets to see other singletons
+		if p.value, err = c.call(p, nil, resolving); err != nil {
+			return
+		}
+
+		p.built = true
+
+		c.mutex.Lock()
+		c.built = append(c.built, p)
+		c.mutex.Unlock()
+	}
+
+	return p.value, nil
+}
+
+func (c *Container) call(p *provider, s *RequestScope, resolving []reflect.Type) (value reflect.Value, err error) {
+	ft := p.ctor.Type()
+	args := make([]reflect.Value, ft.NumIn())
+
+	for i := range args {
+		if args[i], err = c.resolve(ft.In(i), s, resolving); err != nil {
+			return
+		}
+	}
+
+	results := p.ctor.Call(args)
+	if len(results) == 2 && !results[1].IsNil() {
+		return value, results[1].Interface().(error)
+	}
+
+	return results[0], nil
+}
+
+// NewScope starts the scope of a request, which has to be closed when the request ends
+func (c *Container) NewScope() *RequestScope {
+	return &RequestScope{
+		container: c,
+		values:    make(map[reflect.Type]reflect.Value),
+	}
+}
+
+// Close tears down the singletons that have been constructed, in the reverse order of construction
+func (c *Container) Close() {
+	c.mutex.Lock()
+	built := c.built
+	c.built = nil
+	c.closed = true
+	c.mutex.Unlock()
+
+	for i := len(built) - 1; i >= 0; i-- {
+		built[i].close(built[i].value)
+	}
+}
+
+// RequestScope holds the per request values of a container
+type RequestScope struct {
+	container *Container
+	mutex     sync.Mutex
+	values    map[reflect.Type]reflect.Value
+	built     []scopedValue
+}
+
+type scopedValue struct {
+	p     *provider
+	value reflect.Value
+}
+
+// Set adds a value of the request (e.g. the *web.Context) under its own type,
+// which can then be injected into per request constructors
+func (s *RequestScope) Set(v interface{}) {
+	s.SetAs(reflect.TypeOf(v), reflect.ValueOf(v))
+}
+
+func (s *RequestScope) SetAs(t reflect.Type, v reflect.Value) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.values[t] = v
+}
+
+func (s *RequestScope) value(t reflect.Type) (v reflect.Value, exists bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, exists = s.values[t]
+	return
+}
+
+func (s *RequestScope) build(p *provider, resolving []reflect.Type) (value reflect.Value, err error) {
+	if value, err = s.container.call(p, s, resolving); err != nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// another goroutine of the same request might have built it meanwhile
+	if v, exists := s.values[p.rtype]; exists {
+		p.close(value)
+		return v, nil
+	}
+
+	s.values[p.rtype] = value
+	s.built = append(s.built, scopedValue{p: p, value: value})
+
+	return
+}
+
+func (s *RequestScope) Container() *Container {
+	return s.container
+}
+
+// Resolve returns the value of the type, per request values are constructed once for the scope
+func (s *RequestScope) Resolve(t reflect.Type) (reflect.Value, error) {
+	return s.container.resolve(t, s, nil)
+}
+
+func (s *RequestScope) Get(ptr interface{}) error {
+	return get(ptr, s.Resolve)
+}
+
+// Close tears down the per request values, in the reverse order of construction
+func (s *RequestScope) Close() {
+	s.mutex.Lock()
+	built := s.built
+	s.built = nil
+	s.values = make(map[reflect.Type]reflect.Value)
+	s.mutex.Unlock()
+
+	for i := len(built) - 1; i >= 0; i-- {
+		built[i].p.close(built[i].value)
+	}
+}
+
+func (p *provider) close(value reflect.Value) {
+	if !value.IsValid() {
+		return
+	}
+
+	if p.teardown != nil {
+		p.teardown(value.Interface())
+	} else if closer, ok := value.Interface().(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+func get(ptr interface{}, resolve func(reflect.Type) (reflect.Value, error)) error {
+	pv := reflect.ValueOf(ptr)
+	if pv.Kind() != reflect.Ptr || pv.IsNil() {
+		return errors.New("di: Get requires a non-nil pointer")
+	}
+
+	value, err := resolve(pv.Type().Elem())
+	if err != nil {
+		return err
+	}
+
+	pv.Elem().Set(value)
+	return nil
+}
@@ -0,0 +1,123 @@
+package di
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testDB struct {
+	closed bool
+}
+
+func (db *testDB) Close() error {
+	db.closed = true
+	return nil
+}
+
+type testRepo struct {
+	db  *testDB
+	req string
+}
+
+type testA struct{}
+type testB struct{}
+
+func TestContainerLazySingleton(t *testing.T) {
+	var (
+		c     = NewContainer()
+		calls = 0
+	)
+
+	c.Provide(Singleton, func() *testDB {
+		calls++
+		return &testDB{}
+	})
+
+	if calls != 0 {
+		t.Fatal("singleton constructed before it is asked for")
+	}
+
+	var db1, db2 *testDB
+	if err := c.Get(&db1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(&db2); err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 1 || db1 != db2 {
+		t.Error("singleton constructed more than once:", calls)
+	}
+
+	c.Close()
+
+	if !db1.closed {
+		t.Error("singleton not closed with the container")
+	}
+}
+
+func TestContainerPerRequest(t *testing.T) {
+	var (
+		c         = NewContainer()
+		teardowns []string
+	)
+
+	c.Provide(Singleton, func() *testDB { return &testDB{} })
+	c.Provide(PerRequest, func(db *testDB, req string) *testRepo {
+		return &testRepo{db: db, req: req}
+	}, func(v interface{}) {
+		teardowns = append(teardowns, v.(*testRepo).req)
+	})
+
+	s1, s2 := c.NewScope(), c.NewScope()
+	s1.Set("one")
+	s2.Set("two")
+
+	var r1, r1b, r2 *testRepo
+	s1.Get(&r1)
+	s1.Get(&r1b)
+	s2.Get(&r2)
+
+	if r1 == nil || r1 != r1b {
+		t.Fatal("per request value not shared within the scope")
+	}
+	if r1 == r2 || r1.db != r2.db {
+		t.Error("per request values should differ while sharing the singleton")
+	}
+	if r1.req != "one" || r2.req != "two" {
+		t.Error("request values not injected:", r1.req, r2.req)
+	}
+
+	s2.Close()
+	s1.Close()
+
+	if strings.Join(teardowns, ",") != "two,one" {
+		t.Error("teardowns not called at the end of the scopes:", teardowns)
+	}
+
+	if _, err := c.Resolve(reflect.TypeOf((*testRepo)(nil))); err == nil {
+		t.Error("per request value resolved without a scope")
+	}
+}
+
+func TestContainerErrors(t *testing.T) {
+	c := NewContainer()
+
+	if err := c.Provide(Singleton, "not a func"); err != ErrInvalidCtor {
+		t.Error("expected ErrInvalidCtor, got", err)
+	}
+
+	c.Provide(Singleton, func(testB) testA { return testA{} })
+	c.Provide(Singleton, func(testA) testB { return testB{} })
+
+	var a testA
+	if err := c.Get(&a); err == nil || !strings.Contains(err.Error(), ErrCircular.Error()) {
+		t.Error("expected a circular dependency error, got", err)
+	}
+
+	var db *testDB
+	if err := c.Get(&db); err == nil || !strings.Contains(err.Error(), ErrNotProvided.Error()) {
+		t.Error("expected a not provided error, got", err)
+	}
+}
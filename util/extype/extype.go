@@ -63,6 +63,9 @@ var extensions = map[string]string{
 	".rdf":        "application/xml",
 	".rss":        "application/xml",
 	".xml":        "application/xml",
+	".yaml":       "application/yaml",
+	".yml":        "application/yaml",
+	".msgpack":    "application/msgpack",
 	".woff":       "application/font-woff",
 	".eot":        "application/vnd.ms-fontobject",
 	".ttc":        "application/x-font-ttf",
@@ -124,6 +127,7 @@ var extensions = map[string]string{
 	".shtml":      "text/html",
 	".mml":        "text/mathml",
 	".txt":        "text/plain",
+	".csv":        "text/csv",
 	".jad":        "text/vnd.sun.j2me.app-descriptor",
 	".wml":        "text/vnd.wap.wml",
 	".vtt":        "text/vtt",
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"sort"
+)
+
+// OverlayFS returns a filesystem reading the files of the OS directory dir before those of fsys,
+// e.g. the source directory of an embed.FS for editing templates without rebuilding in development;
+// fsys is used alone if dir is empty, and the directory alone if fsys is nil
+func OverlayFS(dir string, fsys fs.FS) fs.FS {
+	if dir == "" {
+		return fsys
+	} else if fsys == nil {
+		return os.DirFS(dir)
+	}
+	return &overlayFS{upper: os.DirFS(dir), lower: fsys}
+}
+
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+func (ofs *overlayFS) Open(name string) (fs.File, error) {
+	f, err := ofs.upper.Open(name)
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		return ofs.lower.Open(name)
+	}
+	return f, err
+}
+
+// ReadDir returns the entries of the directory in both filesystems, an entry of the OS directory
+// replacing the one of the same name of fsys, so that fs.WalkDir and fs.Glob see every file
+func (ofs *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	upper, uerr := fs.ReadDir(ofs.upper, name)
+	lower, lerr := fs.ReadDir(ofs.lower, name)
+
+	if uerr != nil && lerr != nil {
+		return nil, lerr
+	} else if uerr != nil {
+		return lower, nil
+	} else if lerr != nil {
+		return upper, nil
+	}
+
+	seen := make(map[string]bool, len(upper))
+	for _, entry := range upper {
+		seen[entry.Name()] = true
+	}
+
+	for _, entry := range lower {
+		if !seen[entry.Name()] {
+			upper = append(upper, entry)
+		}
+	}
+
+	sort.Slice(upper, func(i, j int) bool { return upper[i].Name() < upper[j].Name() })
+	return upper, nil
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
+	"io/fs"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 var s1 = "This is a string. Its name is s1. It has a length x."
@@ -43,3 +47,28 @@ func TestStringSplitLastN(t *testing.T) {
 		}
 	}
 }
+
+func TestOverlayFS(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "views"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "views", "a.html"), []byte("dev a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := OverlayFS(dir, fstest.MapFS{
+		"views/a.html": &fstest.MapFile{Data: []byte("embedded a")},
+		"views/b.html": &fstest.MapFile{Data: []byte("embedded b")},
+	})
+
+	for name, want := range map[string]string{"views/a.html": "dev a", "views/b.html": "embedded b"} {
+		if b, err := fs.ReadFile(fsys, name); err != nil || string(b) != want {
+			t.Errorf("%s: got %q %v", name, b, err)
+		}
+	}
+
+	if matches, err := fs.Glob(fsys, "views/*.html"); err != nil || !reflect.DeepEqual(matches, []string{"views/a.html", "views/b.html"}) {
+		t.Errorf("glob: %v %v", matches, err)
+	}
+}
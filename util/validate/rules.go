@@ -0,0 +1,395 @@
+package validate
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// TagName is the struct tag holding the rules of a field, e.g.
+//
+//	Name     string `validate:"required,min=3,max=20"`
+//	Age      int    `validate:"min=18,max=130"`
+//	Gender   string `validate:"oneof=m f"`
+//	Confirm  string `validate:"eqfield=Password"`
+//	Username string `validate:"required,pattern=^[a-z0-9_,]+$"`
+//
+// Rules are separated by commas and take their parameter after '=';
+// pattern consumes the rest of the tag (commas included) and so has to be the last rule.
+// Apart from required, the rules are skipped when the value is empty.
+const TagName = "validate"
+
+// RuleType is the rule failed by a value that cannot be converted to the type of its field
+const RuleType = "type"
+
+var (
+	ErrRuleUnknown = errors.New("validation rule not found")
+	ErrRuleParam   = errors.New("validation rule has an invalid parameter")
+
+	rulemutex   sync.RWMutex
+	rulecache   = make(map[string]Rules)
+	structcache = make(map[reflect.Type]error)
+	validators  = map[string]ValidatorFunc{
+		"email":         func(s, _ string) bool { return IsEmail(s) },
+		"url":           func(s, _ string) bool { return IsURL(s) },
+		"jsonpcallback": func(s, _ string) bool { return IsJSONPCallback(s) },
+		"notempty":      func(s, _ string) bool { return IsNotEmpty(s) },
+	}
+	rulemessages = map[string]string{
+		"email": "is not a valid email address",
+		"url":   "is not a valid url",
+	}
+)
+
+// ValidatorFunc is a custom validation rule, param is the text after '=' in the tag
+type ValidatorFunc func(value, param string) bool
+
+// RegisterValidator adds a rule usable in the validate tag by its name;
+// registering an existing name replaces it
+func RegisterValidator(name string, f ValidatorFunc) {
+	rulemutex.Lock()
+	defer rulemutex.Unlock()
+	validators[strings.ToLower(name)] = f
+}
+
+func getValidator(name string) (f ValidatorFunc, exists bool) {
+	rulemutex.RLock()
+	defer rulemutex.RUnlock()
+	f, exists = validators[name]
+	return
+}
+
+type Rule struct {
+	Name  string
+	Param string
+	rex   *regexp.Regexp
+	num   float64
+	in    []string
+}
+
+type Rules []Rule
+
+// RuleError is a rule that a value has failed,
+// the message does not contain the name of the field, e.g. "is required"
+type RuleError struct {
+	Rule    string
+	Param   string
+	Message string
+}
+
+func (re RuleError) Error() string {
+	return re.Message
+}
+
+// ParseRules parses the rules of a validate tag
+func ParseRules(tag string) (rules Rules, err error) {
+	for tag = strings.TrimSpace(tag); tag != ""; {
+		var (
+			part string
+			rule Rule
+		)
+
+		if i := strings.IndexAny(tag, ",="); i >= 0 && tag[i] == '=' &&
+			strings.TrimSpace(strings.ToLower(tag[:i])) == "pattern" {
+			part, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			part, tag = tag[:i], strings.TrimSpace(tag[i+1:])
+		} else {
+			part, tag = tag, ""
+		}
+
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+
+		if i := strings.Index(part, "="); i >= 0 {
+			rule.Name, rule.Param = strings.TrimSpace(part[:i]), part[i+1:]
+		} else {
+			rule.Name = part
+		}
+		rule.Name = strings.ToLower(rule.Name)
+
+		if rule, err = compileRule(rule); err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return
+}
+
+// CachedRules is ParseRules for tags that are read on every request
+func CachedRules(tag string) (rules Rules, err error) {
+	rulemutex.RLock()
+	rules, exists := rulecache[tag]
+	rulemutex.RUnlock()
+
+	if !exists {
+		if rules, err = ParseRules(tag); err == nil {
+			rulemutex.Lock()
+			rulecache[tag] = rules
+			rulemutex.Unlock()
+		}
+	}
+
+	return
+}
+
+// TagError is a validate (or regexp) tag of a struct field that cannot be parsed
+type TagError struct {
+	Struct string
+	Field  string
+	Err    error
+}
+
+func (te *TagError) Error() string {
+	return "invalid validate tag of " + te.Struct + "." + te.Field + ": " + te.Err.Error()
+}
+
+func (te *TagError) Unwrap() error {
+	return te.Err
+}
+
+// FieldRules returns the rules of the validate tag of a field of the struct type,
+// followed by the pattern of the older regexp tag; an invalid tag is returned as a *TagError
+func FieldRules(rtype reflect.Type, field reflect.StructField) (rules Rules, err error) {
+	if tag, exists := field.Tag.Lookup(TagName); exists {
+		if rules, err = CachedRules(tag); err != nil {
+			return nil, &TagError{Struct: rtype.Name(), Field: field.Name, Err: err}
+		}
+	}
+
+	if rex := field.Tag.Get("regexp"); rex != "" {
+		rule, err := PatternRule(rex)
+		if err != nil {
+			return nil, &TagError{Struct: rtype.Name(), Field: field.Name, Err: err}
+		}
+		// the cached rules are shared, so they are never appended to in place
+		rules = append(rules[:len(rules):len(rules)], rule)
+	}
+
+	return
+}
+
+// CheckStruct returns the error of the first invalid tag of the fields of a struct type,
+// including those of its nested structs; the result of a type is cached.
+// It is meant to be called when the type is set up, so an invalid tag is found before the first request.
+func CheckStruct(rtype reflect.Type) error {
+	for rtype.Kind() == reflect.Ptr || rtype.Kind() == reflect.Slice || rtype.Kind() == reflect.Map {
+		rtype = rtype.Elem()
+	}
+
+	if rtype.Kind() != reflect.Struct {
+		return nil
+	}
+
+	rulemutex.RLock()
+	err, exists := structcache[rtype]
+	rulemutex.RUnlock()
+
+	if !exists {
+		err = checkStruct(rtype, nil)
+
+		rulemutex.Lock()
+		structcache[rtype] = err
+		rulemutex.Unlock()
+	}
+
+	return err
+}
+
+func checkStruct(rtype reflect.Type, parents []reflect.Type) error {
+	for _, parent := range parents {
+		if parent == rtype {
+			return nil
+		}
+	}
+	parents = append(parents, rtype)
+
+	for i := 0; i < rtype.NumField(); i++ {
+		field := rtype.Field(i)
+
+		if _, err := FieldRules(rtype, field); err != nil {
+			return err
+		}
+
+		ftype := field.Type
+		for ftype.Kind() == reflect.Ptr || ftype.Kind() == reflect.Slice || ftype.Kind() == reflect.Map {
+			ftype = ftype.Elem()
+		}
+
+		if ftype.Kind() == reflect.Struct {
+			if err := checkStruct(ftype, parents); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// PatternRule returns the rule of a regular expression,
+// used for the older regexp tag of controller fields
+func PatternRule(pattern string) (Rule, error) {
+	return compileRule(Rule{Name: "pattern", Param: pattern})
+}
+
+func compileRule(rule Rule) (_ Rule, err error) {
+	switch rule.Name {
+	case "required":
+	case "min", "max":
+		if rule.num, err = strconv.ParseFloat(strings.TrimSpace(rule.Param), 64); err != nil {
+			return rule, ErrRuleParam
+		}
+	case "oneof":
+		rule.in = strings.Fields(rule.Param)
+	case "pattern":
+		if rule.rex, err = regexp.Compile(rule.Param); err != nil {
+			return rule, err
+		}
+	case "eqfield":
+		if rule.Param = strings.TrimSpace(rule.Param); rule.Param == "" {
+			return rule, ErrRuleParam
+		}
+	default:
+		if _, exists := getValidator(rule.Name); !exists {
+			return rule, ErrRuleUnknown
+		}
+	}
+
+	return rule, nil
+}
+
+func (rs Rules) Has(name string) bool {
+	for _, rule := range rs {
+		if rule.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks the value against the rules, returning the rules it fails.
+// kind is the kind of the field the value is for, which decides whether min and max
+// are numeric ranges (numbers) or lengths (everything else);
+// a value that cannot be converted to the kind fails with the type rule alone.
+// field returns the value of another field by name, used by eqfield.
+func (rs Rules) Validate(value string, kind reflect.Kind, field func(string) string) (errs []RuleError) {
+	if strings.TrimSpace(value) == "" {
+		if rs.Has("required") {
+			errs = append(errs, RuleError{Rule: "required", Message: "is required"})
+		}
+		return
+	}
+
+	if !Convertible(value, kind) {
+		return []RuleError{{Rule: RuleType, Message: "is not a valid value"}}
+	}
+
+	for _, rule := range rs {
+		var (
+			pass    = true
+			message string
+		)
+
+		switch rule.Name {
+		case "required":
+			continue
+		case "min", "max":
+			var (
+				num   float64
+				isnum = isNumericKind(kind)
+			)
+
+			if isnum {
+				// the value has been checked to be convertible
+				num, _ = strconv.ParseFloat(value, 64)
+			} else {
+				num = float64(utf8.RuneCountInString(value))
+			}
+
+			if rule.Name == "min" {
+				pass = num >= rule.num
+				message = "must be at least " + rule.Param
+			} else {
+				pass = num <= rule.num
+				message = "must be at most " + rule.Param
+			}
+
+			if !isnum {
+				message += " characters long"
+			}
+		case "oneof":
+			pass = IsIn(value, rule.in...)
+			message = "must be one of " + strings.Join(rule.in, ", ")
+		case "pattern":
+			pass = rule.rex.MatchString(value)
+			message = "is not in a valid format"
+		case "eqfield":
+			pass = field != nil && field(rule.Param) == value
+			message = "must be the same as " + rule.Param
+		default:
+			if f, exists := getValidator(rule.Name); exists {
+				pass = f(value, rule.Param)
+			}
+			if message = rulemessages[rule.Name]; message == "" {
+				message = "is not valid"
+			}
+		}
+
+		if !pass {
+			errs = append(errs, RuleError{Rule: rule.Name, Param: rule.Param, Message: message})
+		}
+	}
+
+	return
+}
+
+// Convertible returns true if the value can be converted to the kind,
+// numbers have to fit in the size of the kind (e.g. 300 does not fit in an int8)
+func Convertible(value string, kind reflect.Kind) (ok bool) {
+	var err error
+
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		_, err = strconv.ParseInt(value, 10, kindBits(kind))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		_, err = strconv.ParseUint(value, 10, kindBits(kind))
+	case reflect.Float32, reflect.Float64:
+		_, err = strconv.ParseFloat(value, kindBits(kind))
+	case reflect.Bool:
+		_, err = strconv.ParseBool(value)
+	}
+
+	return err == nil
+}
+
+// kindBits returns the bit size of a numeric kind for strconv, 0 being the size of int
+func kindBits(kind reflect.Kind) int {
+	switch kind {
+	case reflect.Int8, reflect.Uint8:
+		return 8
+	case reflect.Int16, reflect.Uint16:
+		return 16
+	case reflect.Int32, reflect.Uint32, reflect.Float32:
+		return 32
+	case reflect.Int64, reflect.Uint64, reflect.Float64:
+		return 64
+	}
+	return 0
+}
+
+func isNumericKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
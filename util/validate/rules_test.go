@@ -0,0 +1,204 @@
+package validate
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRules(t *testing.T) {
+	for _, c := range []struct {
+		tag   string
+		names string
+		err   error
+	}{
+		{"", "", nil},
+		{"required", "required", nil},
+		{" Required , MIN=3,max=20 ", "required min max", nil},
+		{"oneof=m f,email", "oneof email", nil},
+		{"required,pattern=^[a-z,]+$", "required pattern", nil},
+		{"min=x", "", ErrRuleParam},
+		{"eqfield=", "", ErrRuleParam},
+		{"nosuchrule", "", ErrRuleUnknown},
+	} {
+		rules, err := ParseRules(c.tag)
+
+		var names []string
+		for _, rule := range rules {
+			names = append(names, rule.Name)
+		}
+
+		if err != c.err || strings.Join(names, " ") != c.names {
+			t.Errorf("%q: got %v %v", c.tag, names, err)
+		}
+	}
+
+	if rules, _ := ParseRules("required,pattern=^[a-z,]+$"); rules[1].Param != "^[a-z,]+$" {
+		t.Errorf("pattern param: %q", rules[1].Param)
+	}
+	if _, err := ParseRules("pattern=[a-"); err == nil {
+		t.Error("invalid pattern")
+	}
+}
+
+func TestRulesValidate(t *testing.T) {
+	RegisterValidator("even", func(s, _ string) bool { return len(s)%2 == 0 })
+
+	others := map[string]string{"password": "secret"}
+	field := func(name string) string { return others[strings.ToLower(name)] }
+
+	for _, c := range []struct {
+		tag   string
+		value string
+		kind  reflect.Kind
+		fails string
+	}{
+		{"required", "", reflect.String, "required"},
+		{"required", "  ", reflect.String, "required"},
+		{"min=3", "", reflect.String, ""},
+		{"min=3", "ab", reflect.String, "min"},
+		{"min=3", "abc", reflect.String, ""},
+		{"max=3", "日本語", reflect.String, ""},
+		{"max=3", "日本語は", reflect.String, "max"},
+		{"min=18,max=130", "17", reflect.Int, "min"},
+		{"min=18,max=130", "18", reflect.Int, ""},
+		{"min=18,max=130", "131", reflect.Int, "max"},
+		{"min=0.5", "0.25", reflect.Float64, "min"},
+		{"min=10", "9", reflect.String, "min"},
+		// values that cannot be converted to the kind fail with the type rule alone
+		{"required,min=18", "abc", reflect.Int, "type"},
+		{"max=300", "300", reflect.Int8, "type"},
+		{"min=0", "-1", reflect.Uint, "type"},
+		{"", "1e400", reflect.Float64, "type"},
+		{"", "yes", reflect.Bool, "type"},
+		{"", "true", reflect.Bool, ""},
+		{"oneof=m f", "m", reflect.String, ""},
+		{"oneof=m f", "x", reflect.String, "oneof"},
+		{"pattern=^[a-z]+$", "abc", reflect.String, ""},
+		{"pattern=^[a-z]+$", "ab1", reflect.String, "pattern"},
+		{"eqfield=Password", "secret", reflect.String, ""},
+		{"eqfield=Password", "other", reflect.String, "eqfield"},
+		{"email", "a@b.com", reflect.String, ""},
+		{"email,url", "no pe", reflect.String, "email url"},
+		{"even", "ab", reflect.String, ""},
+		{"even", "abc", reflect.String, "even"},
+	} {
+		rules, err := ParseRules(c.tag)
+		if err != nil {
+			t.Fatalf("%q: %v", c.tag, err)
+		}
+
+		var fails []string
+		for _, err := range rules.Validate(c.value, c.kind, field) {
+			fails = append(fails, err.Rule)
+		}
+
+		if strings.Join(fails, " ") != c.fails {
+			t.Errorf("%q %q %v: got %v", c.tag, c.value, c.kind, fails)
+		}
+	}
+}
+
+type badTag struct {
+	Name  string `validate:"required"`
+	Inner struct {
+		Age int `validate:"min=abc"`
+	}
+}
+
+type badRegexp struct {
+	Code string `regexp:"[a-"`
+}
+
+func TestCheckStruct(t *testing.T) {
+	var terr *TagError
+
+	if err := CheckStruct(reflect.TypeOf(&badTag{})); !errors.As(err, &terr) || terr.Field != "Age" ||
+		!errors.Is(err, ErrRuleParam) || err.Error() != "invalid validate tag of .Age: "+ErrRuleParam.Error() {
+		t.Errorf("nested: %v", err)
+	}
+	if err := CheckStruct(reflect.TypeOf([]badRegexp{})); !errors.As(err, &terr) || terr.Struct != "badRegexp" {
+		t.Errorf("regexp: %v", err)
+	}
+	if err := CheckStruct(reflect.TypeOf(signup{})); err != nil {
+		t.Error(err)
+	}
+}
+
+type signup struct {
+	Name     string `validate:"required,min=3"`
+	Age      int    `validate:"required,min=18"`
+	Password string
+	Confirm  string `validate:"eqfield=Password"`
+	Code     int8   `va_func:"pass"`
+}
+
+func TestReqValidator(t *testing.T) {
+	for _, c := range []struct {
+		query string
+		ok    bool
+		want  signup
+	}{
+		{"name=ann&age=20&password=x&confirm=x", true, signup{"ann", 20, "", "x", 0}},
+		{"name=ann&age=abc", false, signup{Name: "ann"}},
+		{"name=ann&age=17", false, signup{Name: "ann"}},
+		{"name=ann&age=20&password=x&confirm=y", false, signup{Name: "ann", Age: 20}},
+		{"name=ann&age=20&code=300", false, signup{Name: "ann", Age: 20}},
+		{"name=ann&age=20&code=-3", true, signup{Name: "ann", Age: 20, Code: -3}},
+	} {
+		var (
+			rv ReqValidator
+			s  signup
+		)
+
+		req, _ := url.ParseQuery(c.query)
+
+		if ok := rv.Validate(&s, req); ok != c.ok || bool(rv) != c.ok || s != c.want {
+			t.Errorf("%s: got %v %+v", c.query, ok, s)
+		}
+	}
+
+	// an invalid tag fails instead of panicking, CheckStruct tells why
+	var rv ReqValidator
+	if rv.Validate(&badTag{}, url.Values{"age": {"20"}}) || bool(rv) {
+		t.Error("invalid tag passed")
+	}
+	if err := CheckStruct(reflect.TypeOf(badTag{})); err == nil || err.Error() != "invalid validate tag of .Age: "+ErrRuleParam.Error() {
+		t.Errorf("invalid tag: %v", err)
+	}
+}
+
+type profile struct {
+	Age  *int    `validate:"min=18"`
+	Nick *string `validate:"min=2"`
+}
+
+func TestReqValidatorPointer(t *testing.T) {
+	for _, c := range []struct {
+		query     string
+		ok        bool
+		age       int
+		nick      string
+		nilfields bool
+	}{
+		{"age=20&nick=ann", true, 20, "ann", false},
+		{"", true, 0, "", true},
+		{"age=17", false, 0, "", true},
+		{"age=abc", false, 0, "", true},
+	} {
+		var (
+			rv ReqValidator
+			p  profile
+		)
+
+		req, _ := url.ParseQuery(c.query)
+		ok := rv.Validate(&p, req)
+
+		if ok != c.ok || (p.Age == nil) != c.nilfields || (p.Nick == nil) != c.nilfields ||
+			(p.Age != nil && (*p.Age != c.age || *p.Nick != c.nick)) {
+			t.Errorf("%s: got %v %v %v", c.query, ok, p.Age, p.Nick)
+		}
+	}
+}
@@ -52,15 +52,35 @@ func IsIn(s string, in ...string) bool {
 	return false
 }
 
+// IsInFold is IsIn with the strings compared case-insensitively
+func IsInFold(s string, in ...string) bool {
+	for _, v := range in {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
 type ReqValidator bool
 
-func (rv *ReqValidator) Validate(m interface{}, req url.Values) bool {
+// Validate validates the request values and sets them to the fields of the struct m points to.
+// A field with a validate tag is checked against its rules, otherwise the va_func, va_args
+// and va_default tags are used. A struct with an invalid validate tag fails,
+// CheckStruct returns its *TagError (the same error as the controllers').
+func (rv *ReqValidator) Validate(m interface{}, req url.Values) (ok bool) {
 	defer func() {
 		if err := recover(); err != nil {
-			*rv = false
+			*rv, ok = false, false
 		}
 	}()
 
+	if rtype := reflect.TypeOf(m); rtype == nil || CheckStruct(rtype) != nil {
+		*rv = false
+		return false
+	}
+
 	mod := reflect.ValueOf(m)
 	mod = reflect.Indirect(mod.Elem())
 
@@ -72,6 +92,14 @@ func (rv *ReqValidator) Validate(m interface{}, req url.Values) bool {
 			fieldtyp := modtyp.Field(i)
 			lname := strings.ToLower(fieldtyp.Name)
 
+			if _, exists := fieldtyp.Tag.Lookup(TagName); exists {
+				if !rv.validateRules(field, modtyp, fieldtyp, req) {
+					*rv = false
+					return false
+				}
+				continue
+			}
+
 			if _, exists := req[lname]; !exists && strings.Contains(string(fieldtyp.Tag), "va_default:") {
 				SetValue(field, fieldtyp.Tag.Get("va_default"))
 				continue
@@ -127,6 +155,39 @@ func (rv *ReqValidator) Validate(m interface{}, req url.Values) bool {
 	return true
 }
 
+// validateRules validates and sets a field using the rules of its validate tag,
+// va_default is still used when the request does not have the value
+func (rv *ReqValidator) validateRules(field reflect.Value, modtyp reflect.Type, fieldtyp reflect.StructField, req url.Values) bool {
+	rules, err := FieldRules(modtyp, fieldtyp)
+	if err != nil || !field.CanSet() {
+		return false
+	}
+
+	lname := strings.ToLower(fieldtyp.Name)
+	val, exists := req.Get(lname), len(req[lname]) > 0
+
+	if def, hasdef := fieldtyp.Tag.Lookup("va_default"); !exists && hasdef {
+		val = def
+	}
+
+	kind := field.Kind()
+	if kind == reflect.Ptr {
+		kind = field.Type().Elem().Kind()
+	}
+
+	if errs := rules.Validate(val, kind, func(name string) string {
+		return req.Get(strings.ToLower(name))
+	}); len(errs) > 0 {
+		return false
+	}
+
+	return SetValue(field, val)
+}
+
+// SetValue sets the field to the value converted to its kind,
+// returning false if the value cannot be converted (the field is then left as it is);
+// an empty value leaves a field that is not a string as it is.
+// A pointer field is set to a new value holding the converted value, unless the value is empty.
 func SetValue(field reflect.Value, val string) (ok bool) {
 	defer func() {
 		if err := recover(); err != nil {
@@ -134,32 +195,44 @@ func SetValue(field reflect.Value, val string) (ok bool) {
 		}
 	}()
 
-	ok = true
+	kind := field.Kind()
+
+	if kind == reflect.Ptr {
+		if val == "" {
+			return true
+		}
+
+		ptr := reflect.New(field.Type().Elem())
+		if !SetValue(ptr.Elem(), val) {
+			return false
+		}
+
+		field.Set(ptr)
+		return true
+	}
+
+	if kind != reflect.String && val == "" {
+		return true
+	} else if !Convertible(val, kind) {
+		return false
+	}
 
-	switch field.Kind() {
+	switch kind {
 	case reflect.String:
 		field.SetString(val)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		i, err := strconv.ParseInt(val, 10, 64)
-		if err == nil {
-			field.SetInt(i)
-		}
+		i, _ := strconv.ParseInt(val, 10, 64)
+		field.SetInt(i)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		ui, err := strconv.ParseUint(val, 10, 64)
-		if err == nil {
-			field.SetUint(ui)
-		}
+		ui, _ := strconv.ParseUint(val, 10, 64)
+		field.SetUint(ui)
 	case reflect.Float32, reflect.Float64:
-		fl, err := strconv.ParseFloat(val, 64)
-		if err == nil {
-			field.SetFloat(fl)
-		}
+		fl, _ := strconv.ParseFloat(val, 64)
+		field.SetFloat(fl)
 	case reflect.Bool:
-		b, err := strconv.ParseBool(val)
-		if err == nil {
-			field.SetBool(b)
-		}
+		b, _ := strconv.ParseBool(val)
+		field.SetBool(b)
 	}
 
-	return
+	return true
 }
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AcceptRange is a single media range of an Accept header, e.g. text/html;q=0.8
+type AcceptRange struct {
+	Type    string
+	SubType string
+	Q       float64
+	Params  map[string]string
+}
+
+func (ar AcceptRange) MediaType() string {
+	return ar.Type + "/" + ar.SubType
+}
+
+// specificity is used to determine which range applies to a media type when more than one matches,
+// e.g. text/html is more specific than text/* which is more specific than */*
+func (ar AcceptRange) specificity() int {
+	switch {
+	case ar.Type == "*":
+		return 0
+	case ar.SubType == "*":
+		return 1
+	}
+	return 2 + len(ar.Params)
+}
+
+// Match returns true if the media type (without parameters) falls within the range
+func (ar AcceptRange) Match(mediatype string) bool {
+	mtype, msubtype := splitMediaType(mediatype)
+
+	return (ar.Type == "*" || ar.Type == mtype) &&
+		(ar.SubType == "*" || ar.SubType == msubtype)
+}
+
+// ParseAccept parses an Accept header into its media ranges ordered by preference;
+// ranges with the same q value keep the order they appear in
+func ParseAccept(accept string) (ranges []AcceptRange) {
+	if accept = strings.TrimSpace(accept); accept == "" {
+		return
+	}
+
+	for _, part := range strings.Split(accept, ",") {
+		var (
+			ar     = AcceptRange{Q: 1}
+			params = strings.Split(part, ";")
+		)
+
+		ar.Type, ar.SubType = splitMediaType(params[0])
+		if ar.Type == "" {
+			continue
+		}
+
+		for _, param := range params[1:] {
+			kv := strings.SplitN(param, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			k, v := strings.ToLower(strings.TrimSpace(kv[0])), strings.Trim(strings.TrimSpace(kv[1]), `"`)
+
+			if k == "q" {
+				if q, err := strconv.ParseFloat(v, 64); err == nil && q >= 0 && q <= 1 {
+					ar.Q = q
+				}
+			} else {
+				if ar.Params == nil {
+					ar.Params = make(map[string]string)
+				}
+				ar.Params[k] = v
+			}
+		}
+
+		ranges = append(ranges, ar)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Q != ranges[j].Q {
+			return ranges[i].Q > ranges[j].Q
+		}
+		return ranges[i].specificity() > ranges[j].specificity()
+	})
+
+	return
+}
+
+// NegotiateMediaType returns the offered media type most preferred by the Accept header,
+// or an empty string if none of the offers are acceptable.
+// The first offer is returned if the Accept header is empty.
+func NegotiateMediaType(accept string, offers ...string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+
+	ranges := ParseAccept(accept)
+	if len(ranges) == 0 {
+		return offers[0]
+	}
+
+	var (
+		best  string
+		bestq float64
+	)
+
+	for _, offer := range offers {
+		var (
+			q    float64
+			spec = -1
+		)
+
+		// the most specific range that matches determines the q value of the offer
+		for _, ar := range ranges {
+			if s := ar.specificity(); s > spec && ar.Match(offer) {
+				q, spec = ar.Q, s
+			}
+		}
+
+		if q > bestq {
+			best, bestq = offer, q
+		}
+	}
+
+	return best
+}
+
+// MediaTypeByExt returns the media type (without parameters) of an extension, e.g. .json,
+// or an empty string if it is unknown
+func MediaTypeByExt(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	if ext[0] != '.' {
+		ext = "." + ext
+	}
+
+	mtype, _, err := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(ext)))
+	if err != nil {
+		return ""
+	}
+
+	return mtype
+}
+
+// Negotiate returns the offered media type that best suits the request,
+// the extension of the request path (Context.Ext) takes precedence over the Accept header.
+// An empty string is returned if none of the offers are acceptable.
+func (c *Context) Negotiate(offers ...string) string {
+	if mtype := MediaTypeByExt(c.Ext); mtype != "" {
+		for _, offer := range offers {
+			if mediaRange(offer).Match(mtype) {
+				return offer
+			}
+		}
+
+		return ""
+	}
+
+	return NegotiateMediaType(c.Request.Header.Get("Accept"), offers...)
+}
+
+func mediaRange(mediatype string) (ar AcceptRange) {
+	ar.Type, ar.SubType = splitMediaType(mediatype)
+	ar.Q = 1
+	return
+}
+
+func splitMediaType(mediatype string) (mtype, msubtype string) {
+	if i := strings.Index(mediatype, ";"); i >= 0 {
+		mediatype = mediatype[:i]
+	}
+
+	mediatype = strings.ToLower(strings.TrimSpace(mediatype))
+
+	if mediatype == "*" {
+		return "*", "*"
+	}
+
+	split := strings.SplitN(mediatype, "/", 2)
+	if len(split) != 2 || split[0] == "" || split[1] == "" {
+		return "", ""
+	}
+
+	return split[0], split[1]
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net"
 	"net/http"
 	"net/url"
@@ -17,7 +18,9 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/zaolab/sunnified/util"
+	"github.com/zaolab/sunnified/util/av"
 	"github.com/zaolab/sunnified/util/collection"
+	"github.com/zaolab/sunnified/util/di"
 	"github.com/zaolab/sunnified/util/event"
 	"github.com/zaolab/sunnified/util/validate"
 )
@@ -58,6 +61,8 @@ type Context struct {
 	Session     SessionManager
 	Cache       CacheManager
 	MaxFileSize int64
+	FileScanner av.Scanner
+	Services    *di.RequestScope
 	WebSocket   *websocket.Conn
 	resource    map[string]interface{}
 	sunnyserver int
@@ -69,6 +74,7 @@ type Context struct {
 	flashcache  *collection.Queue
 	parseState  parseState
 	rdata       map[string]interface{}
+	uploads     map[*multipart.FileHeader]*UploadedFile
 	//Router      *router.Router // TODO: change it into an interface so there is no cyclic reference when router uses web.UPath, web.FDATA
 }
 
@@ -677,9 +683,15 @@ func (c *Context) RedirectOut(location string, state ...int) {
 	panic(c.redirecting)
 }
 
+// URL returns the absolute URL of path on the host of the request;
+// the host variables of an absolute URL, e.g. https://{tenant}.example.com/users, are filled in from PData
 func (c *Context) URL(path string, qstr ...Q) string {
 	var buf bytes.Buffer
 
+	if strings.Contains(path, "{") {
+		path = fillURLHost(path, c.PData)
+	}
+
 	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
 		buf.WriteString("http")
 		if c.Request.TLS != nil {
@@ -782,6 +794,17 @@ func (c *Context) SetResource(name string, ref interface{}) {
 	c.resource[name] = ref
 }
 
+// Inject sets the value ptr points to with the service of its type, e.g.
+//
+//	var db *sql.DB
+//	err := ctxt.Inject(&db)
+func (c *Context) Inject(ptr interface{}) error {
+	if c.Services == nil {
+		return di.ErrNotProvided
+	}
+	return c.Services.Get(ptr)
+}
+
 func (c *Context) ToWebSocket(upgrader *websocket.Upgrader, header http.Header) (err error) {
 	if upgrader == nil {
 		upgrader = &websocket.Upgrader{}
@@ -0,0 +1,79 @@
+package web
+
+import (
+	"regexp"
+	"strings"
+)
+
+// HostVar parses a host label that is a variable, using the same syntax as path vars,
+// e.g. {tenant}, {id:int} or {name:[a-z]+}, into its name and the regexp its value must match;
+// re is nil if the label is not a variable
+func HostVar(label string) (varname string, re *regexp.Regexp) {
+	llabel := len(label)
+	if llabel < 2 || label[0] != '{' || label[llabel-1] != '}' {
+		return
+	}
+
+	var pattern = "[^.]+"
+
+	label = strings.TrimSpace(label[1 : llabel-1])
+	varname = label
+
+	if strings.Contains(label, ":") {
+		labelsplit := strings.SplitN(label, ":", 2)
+		varname = strings.TrimSpace(labelsplit[0])
+
+		switch strings.ToLower(labelsplit[1]) {
+		// a host label cannot contain '.', so floats are the same as ints
+		case "int32", "int", "int64", "float32", "float", "float64":
+			pattern = "-?[0-9]+"
+		default:
+			pattern = strings.TrimSuffix(strings.TrimPrefix(labelsplit[1], "^"), "$")
+		}
+	}
+
+	if varname == "" {
+		varname = "_"
+	}
+
+	re = regexp.MustCompile("^(?:" + pattern + ")$")
+	return
+}
+
+// FillHost replaces the variables in a host pattern (e.g. {tenant}.example.com)
+// with the values found in data; variables without a valid value are left as they are
+func FillHost(host string, data map[string]string) string {
+	if !strings.Contains(host, "{") {
+		return host
+	}
+
+	labels := strings.Split(host, ".")
+
+	for i, label := range labels {
+		if varname, re := HostVar(label); re != nil {
+			if value, exists := data[varname]; exists && re.MatchString(value) {
+				labels[i] = value
+			}
+		}
+	}
+
+	return strings.Join(labels, ".")
+}
+
+// fillURLHost fills the host variables of an absolute URL, e.g. https://{tenant}.example.com/users
+func fillURLHost(u string, data map[string]string) string {
+	i := strings.Index(u, "://")
+	if i == -1 {
+		return u
+	}
+
+	i += 3
+	end := strings.IndexAny(u[i:], "/?#")
+	if end == -1 {
+		end = len(u)
+	} else {
+		end += i
+	}
+
+	return u[:i] + FillHost(u[i:end], data) + u[end:]
+}
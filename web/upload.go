@@ -0,0 +1,298 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zaolab/sunnified/util/av"
+	"github.com/zaolab/sunnified/util/validate"
+)
+
+const (
+	UploadCodeSize  = "maxsize"
+	UploadCodeExt   = "ext"
+	UploadCodeType  = "type"
+	UploadCodeVirus = "virus"
+	UploadCodeScan  = "scan"
+)
+
+var (
+	ErrUploadNoFile = errors.New("no file uploaded")
+
+	unsafefilechars = regexp.MustCompile(`[^\w\-. ]+`)
+)
+
+// UploadError is the reason an uploaded file is rejected, the code is one of the UploadCode constants
+type UploadError struct {
+	Code    string
+	Message string
+}
+
+func (ue UploadError) Error() string {
+	return ue.Message
+}
+
+// UploadRules are the checks an uploaded file has to pass before it is accepted
+type UploadRules struct {
+	// MaxSize is the size limit in bytes, 0 for no limit other than Context.MaxFileSize
+	MaxSize int64
+	// Exts are the allowed extensions (e.g. .jpg), all extensions are allowed if empty
+	Exts []string
+	// Types are the allowed media ranges (e.g. image/*) matched against the sniffed content type,
+	// all types are allowed if empty
+	Types []string
+}
+
+func NewUploadedFile(field string, fh *multipart.FileHeader) *UploadedFile {
+	return &UploadedFile{
+		FileHeader: fh,
+		Field:      field,
+	}
+}
+
+// UploadedFile is a file of a multipart request, which can be declared as a controller field
+// or action argument (*web.UploadedFile or []*web.UploadedFile)
+type UploadedFile struct {
+	*multipart.FileHeader
+	Field   string
+	sniffed string
+	once    sync.Once
+	// scanmutex guards the scan result, the same file can be bound to both a field and an argument
+	scanmutex sync.Mutex
+	scan      *av.Result
+}
+
+// Name returns the base name of the file as given by the client
+func (f *UploadedFile) Name() string {
+	return filepath.Base(strings.Replace(f.Filename, `\`, "/", -1))
+}
+
+// SafeName returns the base name with all characters except letters, digits, '-', '_', '.' and ' ' replaced,
+// which is safe to be used as a file name on disk
+func (f *UploadedFile) SafeName() string {
+	name := strings.Trim(unsafefilechars.ReplaceAllString(f.Name(), "_"), ". ")
+	if name == "" {
+		name = "upload"
+	}
+	return name
+}
+
+func (f *UploadedFile) Ext() string {
+	return strings.ToLower(filepath.Ext(f.Name()))
+}
+
+// ContentType returns the content type sniffed from the first 512 bytes of the file,
+// the type given by the client is not trusted
+func (f *UploadedFile) ContentType() string {
+	f.once.Do(func() {
+		f.sniffed = "application/octet-stream"
+
+		if file, err := f.Open(); err == nil {
+			defer file.Close()
+
+			buf := make([]byte, 512)
+			if n, err := io.ReadFull(file, buf); n > 0 && (err == nil || err == io.ErrUnexpectedEOF) {
+				f.sniffed = http.DetectContentType(buf[:n])
+			}
+		}
+	})
+
+	return f.sniffed
+}
+
+// Check returns an UploadError if the file does not pass the rules or is larger than maxsize (0 for no limit)
+func (f *UploadedFile) Check(rules UploadRules, maxsize int64) error {
+	if rules.MaxSize > 0 && (maxsize <= 0 || rules.MaxSize < maxsize) {
+		maxsize = rules.MaxSize
+	}
+
+	if maxsize > 0 && f.Size > maxsize {
+		return UploadError{Code: UploadCodeSize, Message: "is larger than the size allowed"}
+	}
+
+	if len(rules.Exts) > 0 && !validate.IsInFold(f.Ext(), rules.Exts...) {
+		return UploadError{Code: UploadCodeExt, Message: "is not of an allowed file extension"}
+	}
+
+	if len(rules.Types) > 0 {
+		ctype, allowed := f.ContentType(), false
+
+		for _, t := range rules.Types {
+			if mediaRange(t).Match(ctype) {
+				allowed = true
+				break
+			}
+		}
+
+		if !allowed {
+			return UploadError{Code: UploadCodeType, Message: "is not of an allowed file type"}
+		}
+	}
+
+	return nil
+}
+
+// Scan scans the file with the scanner, returning an UploadError if it is infected or cannot be scanned;
+// the result is kept so that a file is scanned only once
+func (f *UploadedFile) Scan(scanner av.Scanner) error {
+	f.scanmutex.Lock()
+	defer f.scanmutex.Unlock()
+
+	if f.scan == nil {
+		file, err := f.Open()
+		if err != nil {
+			return UploadError{Code: UploadCodeScan, Message: "could not be scanned"}
+		}
+		defer file.Close()
+
+		res, err := scanner.ScanStream(file)
+		if err != nil {
+			return UploadError{Code: UploadCodeScan, Message: "could not be scanned"}
+		}
+
+		res.FileName = f.Name()
+		f.scan = &res
+	}
+
+	if !f.scan.Status {
+		return UploadError{Code: UploadCodeVirus, Message: "is infected with " + f.scan.Virus}
+	}
+
+	return nil
+}
+
+// ScanResult returns the result of Scan, nil if the file has not been scanned
+func (f *UploadedFile) ScanResult() *av.Result {
+	f.scanmutex.Lock()
+	defer f.scanmutex.Unlock()
+	return f.scan
+}
+
+// SaveAs writes the file to filename atomically, i.e. it is written to a temporary file
+// in the same directory first and renamed only when it is complete
+func (f *UploadedFile) SaveAs(filename string, perm os.FileMode) (err error) {
+	var (
+		src multipart.File
+		tmp *os.File
+	)
+
+	if src, err = f.Open(); err != nil {
+		return
+	}
+	defer src.Close()
+
+	if tmp, err = ioutil.TempFile(filepath.Dir(filename), ".upload-"); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = io.Copy(tmp, src); err != nil {
+		return
+	}
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	if err = tmp.Chmod(perm); err != nil {
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	return os.Rename(tmp.Name(), filename)
+}
+
+// SaveIn saves the file in dir under its SafeName, returning the path it is saved to
+func (f *UploadedFile) SaveIn(dir string, perm os.FileMode) (filename string, err error) {
+	filename = filepath.Join(dir, f.SafeName())
+	err = f.SaveAs(filename, perm)
+	return
+}
+
+// UploadedFiles returns the files uploaded under the name, where items[0][image] and items.0.image
+// are the same name; all files (ordered by their names) are returned if name is empty.
+// A file is the same *UploadedFile on every call, so it is sniffed and scanned only once per request.
+func (c *Context) UploadedFiles(name string) (files []*UploadedFile) {
+	if c.Request == nil || c.Request.MultipartForm == nil {
+		return
+	}
+
+	var (
+		form  = c.Request.MultipartForm.File
+		names = make([]string, 0, len(form))
+	)
+
+	name = NormalizeFormKey(name)
+
+	for k := range form {
+		if name == "" || NormalizeFormKey(k) == name {
+			names = append(names, k)
+		}
+	}
+
+	sort.Strings(names)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.uploads == nil {
+		c.uploads = make(map[*multipart.FileHeader]*UploadedFile)
+	}
+
+	for _, k := range names {
+		for _, fh := range form[k] {
+			file, exists := c.uploads[fh]
+			if !exists {
+				file = NewUploadedFile(k, fh)
+				c.uploads[fh] = file
+			}
+			files = append(files, file)
+		}
+	}
+
+	return
+}
+
+func (c *Context) UploadedFile(name string) (*UploadedFile, error) {
+	if files := c.UploadedFiles(name); len(files) > 0 {
+		return files[0], nil
+	}
+	return nil, ErrUploadNoFile
+}
+
+// NormalizeFormKey returns the lower case, dot separated form of a form key,
+// e.g. Items[0][Image] becomes items.0.image and ids[] becomes ids
+func NormalizeFormKey(key string) string {
+	return strings.ToLower(strings.Join(SplitFormKey(key), "."))
+}
+
+// SplitFormKey splits a form key into its parts, where both . and [] separate them,
+// e.g. Items[0][Image] and Items.0.Image both become Items, 0 and Image;
+// empty parts (e.g. of ids[]) are dropped
+func SplitFormKey(key string) (segs []string) {
+	key = strings.Replace(key, "]", "", -1)
+	key = strings.Replace(key, "[", ".", -1)
+
+	for _, seg := range strings.Split(key, ".") {
+		if seg = strings.TrimSpace(seg); seg != "" {
+			segs = append(segs, seg)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,164 @@
+package web
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zaolab/sunnified/util/av"
+)
+
+var pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func multipartContext(t *testing.T, files map[string]string) *Context {
+	var (
+		body bytes.Buffer
+		mw   = multipart.NewWriter(&body)
+	)
+
+	for field, content := range files {
+		name := field + ".png"
+		if !strings.HasPrefix(content, pngHeader) {
+			name = field + ".TXT"
+		}
+
+		w, err := mw.CreateFormFile(field, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/upload", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewContext(httptest.NewRecorder(), r)
+}
+
+func TestFormKey(t *testing.T) {
+	for _, c := range []struct {
+		key  string
+		segs string
+		norm string
+	}{
+		{"Items[0][Image]", "Items 0 Image", "items.0.image"},
+		{"Items.0.Image", "Items 0 Image", "items.0.image"},
+		{"ids[]", "ids", "ids"},
+		{" a [ b ] ", "a b", "a.b"},
+		{"", "", ""},
+	} {
+		if segs := strings.Join(SplitFormKey(c.key), " "); segs != c.segs || NormalizeFormKey(c.key) != c.norm {
+			t.Errorf("%q: got %q %q", c.key, segs, NormalizeFormKey(c.key))
+		}
+	}
+}
+
+func TestUploadedFileCheck(t *testing.T) {
+	ctxt := multipartContext(t, map[string]string{
+		"image": pngHeader + strings.Repeat("x", 100),
+		"doc":   "plain text",
+	})
+
+	image, _ := ctxt.UploadedFile("image")
+	doc, _ := ctxt.UploadedFile("DOC")
+
+	if image == nil || doc == nil {
+		t.Fatal("files not found")
+	}
+	if image.ContentType() != "image/png" || doc.Ext() != ".txt" {
+		t.Errorf("got %s %s", image.ContentType(), doc.Ext())
+	}
+
+	for _, c := range []struct {
+		file    *UploadedFile
+		rules   UploadRules
+		maxsize int64
+		code    string
+	}{
+		{image, UploadRules{}, 0, ""},
+		{image, UploadRules{MaxSize: 10}, 0, UploadCodeSize},
+		{image, UploadRules{}, 10, UploadCodeSize},
+		{image, UploadRules{MaxSize: 1000}, 10, UploadCodeSize},
+		{image, UploadRules{Exts: []string{".PNG"}, Types: []string{"image/*"}}, 0, ""},
+		{doc, UploadRules{Exts: []string{".png", ".jpg"}}, 0, UploadCodeExt},
+		{doc, UploadRules{Exts: []string{".txt"}, Types: []string{"image/png"}}, 0, UploadCodeType},
+		{doc, UploadRules{Types: []string{"text/*"}}, 0, ""},
+	} {
+		err := c.file.Check(c.rules, c.maxsize)
+		if uerr, _ := err.(UploadError); uerr.Code != c.code || (c.code == "") != (err == nil) {
+			t.Errorf("%s %+v %d: got %v", c.file.Field, c.rules, c.maxsize, err)
+		}
+	}
+}
+
+type countingScanner struct {
+	av.Scanner
+	scans int
+	virus string
+}
+
+func (s *countingScanner) ScanStream(r io.Reader) (av.Result, error) {
+	s.scans++
+	b, _ := io.ReadAll(r)
+	if strings.Contains(string(b), "EICAR") {
+		return av.Result{Virus: s.virus}, nil
+	}
+	return av.Result{Status: true}, nil
+}
+
+func TestUploadedFileScan(t *testing.T) {
+	ctxt := multipartContext(t, map[string]string{"clean": "hello", "bad": "EICAR test"})
+	scanner := &countingScanner{virus: "Eicar-Test-Signature"}
+
+	// the files are the same for every call, so each is scanned only once
+	for i := 0; i < 2; i++ {
+		files := ctxt.UploadedFiles("")
+		if len(files) != 2 || files[0].Field != "bad" || files[1].Field != "clean" {
+			t.Fatalf("files: %v", files)
+		}
+
+		if err := files[1].Scan(scanner); err != nil {
+			t.Errorf("clean: %v", err)
+		}
+		if err, _ := files[0].Scan(scanner).(UploadError); err.Code != UploadCodeVirus ||
+			err.Message != "is infected with Eicar-Test-Signature" {
+			t.Errorf("bad: %v", err)
+		}
+	}
+
+	if clean, _ := ctxt.UploadedFile("clean"); scanner.scans != 2 || clean.ScanResult() == nil ||
+		clean.ScanResult().FileName != "clean.TXT" {
+		t.Errorf("scans: %d %v", scanner.scans, clean.ScanResult())
+	}
+}
+
+func TestUploadedFileScanConcurrent(t *testing.T) {
+	ctxt := multipartContext(t, map[string]string{"clean": "hello"})
+	scanner := &countingScanner{}
+	file, _ := ctxt.UploadedFile("clean")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := file.Scan(scanner); err != nil || file.ScanResult() == nil {
+				t.Errorf("scan: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if scanner.scans != 1 {
+		t.Errorf("scans: %d", scanner.scans)
+	}
+}